@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
+)
+
+var addCmd = &cobra.Command{
+	Use:   "add <path>",
+	Short: "Start managing an existing file",
+	Long: `Move an existing file or directory into a config of the repo and link
+it back in its place.
+
+The file keeps its path relative to the config's target root, so
+~/.config/foo/bar.toml added to the terminal config becomes
+configs/terminal/.config/foo/bar.toml. The original is backed up like any
+file an apply replaces.
+
+With --template, your name, email and other personal values in the file are
+replaced with <<dotts:...>> placeholders. With --alternate, the file is
+added as a variant for machines matching the suffix, e.g. os.linux.
+
+If the current profile does not list the config yet, dotts offers to add it.
+
+  dotts add ~/.config/foo/bar.toml --config terminal
+  dotts add ~/.gitconfig --config git --template
+  dotts add ~/.config/kitty/kitty.conf --config terminal --alternate os.linux`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAdd,
+}
+
+func init() {
+	addCmd.Flags().String("config", "", "Config to add the file to (required)")
+	addCmd.Flags().Bool("template", false, "Replace personal values with placeholders")
+	addCmd.Flags().String("alternate", "", "Add as a variant for machines matching this suffix (e.g. os.linux)")
+	addCmd.Flags().String("machine", "", "Machine or profile to link for (defaults to the initialized machine)")
+	addCmd.Flags().BoolP("yes", "y", false, "Add the config to the profile without asking")
+}
+
+func runAdd(cmd *cobra.Command, args []string) error {
+	configName, _ := cmd.Flags().GetString("config")
+	templatize, _ := cmd.Flags().GetBool("template")
+	alternate, _ := cmd.Flags().GetString("alternate")
+	machine, _ := cmd.Flags().GetString("machine")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	if configName == "" {
+		return fmt.Errorf("--config is required")
+	}
+	if strings.ContainsAny(configName, `/\`) || configName == "." || configName == ".." {
+		return fmt.Errorf("invalid config name %q", configName)
+	}
+
+	env, err := loadEnvironment()
+	if err != nil {
+		return err
+	}
+	machineName := env.machineName(machine)
+	if machineName == "" {
+		return fmt.Errorf("no machine recorded in state, use --machine")
+	}
+
+	path, err := originalPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	applier, err := apply.New(env.sysInfo, env.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize applier: %w", err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	result, err := applier.Add(ctx, path, apply.AddOptions{
+		MachineName: machineName,
+		Config:      configName,
+		Template:    templatize,
+		Alternate:   alternate,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", path, err)
+	}
+
+	progress.PrintSuccess(fmt.Sprintf("Added %s to %s", path, configName))
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "Source", result.Source))
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "Linked", pluralize(len(result.Linked), "target")))
+	if templatize {
+		if len(result.Placeholders) == 0 {
+			progress.PrintWarning("No personal values found, the file was added as is")
+		} else {
+			fmt.Println(styles.StatusLine(styles.InfoIcon, "Placeholders", strings.Join(result.Placeholders, ", ")))
+		}
+	}
+
+	if result.InProfile {
+		return nil
+	}
+	if result.Profile == "" {
+		fmt.Println(styles.Mute(fmt.Sprintf("Add %s to the configs of a profile so 'dotts apply' keeps it linked", configName)))
+		return nil
+	}
+
+	if !yes {
+		fmt.Println()
+		ok, err := confirm(fmt.Sprintf("Add %s to profile %s?", configName, result.Profile))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println(styles.Warn(fmt.Sprintf("%s is not in profile %s, the next apply will prune its links", configName, result.Profile)))
+			return nil
+		}
+	}
+
+	if err := applier.AddToProfile(result.Profile, configName); err != nil {
+		return err
+	}
+	progress.PrintSuccess(fmt.Sprintf("Added %s to profile %s", configName, result.Profile))
+	return nil
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
+)
+
+var alternatesCmd = &cobra.Command{
+	Use:   "alternates",
+	Short: "Inspect ##-suffixed alternate files",
+}
+
+var alternatesExplainCmd = &cobra.Command{
+	Use:   "explain <path>",
+	Short: "Show why an alternate variant wins",
+	Long: `List every variant of a file with the predicates it matched, its score
+and the winner for this machine.
+
+The path may point into the config repo (with or without a ## suffix)
+or at the linked target:
+
+  dotts alternates explain ~/.config/kitty/kitty.conf
+  dotts alternates explain configs/terminal/.config/kitty/kitty.conf
+
+Use --as to simulate another machine:
+
+  dotts alternates explain ~/.gitconfig --as hostname=work,os=darwin`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAlternatesExplain,
+}
+
+func init() {
+	alternatesCmd.AddCommand(alternatesExplainCmd)
+
+	alternatesExplainCmd.Flags().StringSlice("as", nil, "Override machine attributes (hostname, os, distro, arch, profile, user, feature, env, setting.<key>)")
+	alternatesExplainCmd.Flags().String("machine", "", "Machine or profile to resolve (defaults to the initialized machine)")
+}
+
+func runAlternatesExplain(cmd *cobra.Command, args []string) error {
+	overrides, _ := cmd.Flags().GetStringSlice("as")
+	machine, _ := cmd.Flags().GetString("machine")
+
+	env, err := loadEnvironment()
+	if err != nil {
+		return err
+	}
+
+	applier, err := apply.NewReadOnly(env.sysInfo, env.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize applier: %w", err)
+	}
+
+	resolved, err := applier.Resolve(env.machineName(machine))
+	if err != nil {
+		return err
+	}
+
+	ctx := applier.AlternateContext(resolved)
+	for _, override := range overrides {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok {
+			return fmt.Errorf("invalid --as value %q, expected key=value", override)
+		}
+		if err := ctx.Override(key, value); err != nil {
+			return err
+		}
+	}
+
+	resolver, err := applier.AlternateResolver(ctx)
+	if err != nil {
+		return err
+	}
+
+	basePath, err := findAlternateBase(env, applier.GetLoader(), args[0])
+	if err != nil {
+		return err
+	}
+
+	explanation, err := resolver.Explain(basePath)
+	if err != nil {
+		return fmt.Errorf("failed to read alternates: %w", err)
+	}
+
+	printAlternateExplanation(env, ctx, explanation)
+	return nil
+}
+
+// findAlternateBase maps a repo path or a linked target to the un-suffixed
+// source path inside the config repo. Targets are looked up below the target
+// root of each config.
+func findAlternateBase(env *environment, loader *config.Loader, arg string) (string, error) {
+	path := arg
+	if !filepath.IsAbs(path) {
+		if repoPath := filepath.Join(env.configPath, path); pathExists(filepath.Dir(repoPath)) {
+			path = repoPath
+		} else if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+
+	base, _, _ := config.SplitAlternate(filepath.Base(path))
+	path = filepath.Join(filepath.Dir(path), base)
+
+	if rel, err := filepath.Rel(env.configPath, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return path, nil
+	}
+
+	configs, err := loader.ListConfigs()
+	if err != nil {
+		return "", err
+	}
+	for _, name := range configs {
+		meta, err := loader.LoadConfigMeta(name)
+		if err != nil {
+			return "", err
+		}
+		targetRoot, err := config.TargetRoot(meta)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(targetRoot, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		candidate := filepath.Join(loader.GetConfigPath(name), rel)
+		if !pathExists(filepath.Dir(candidate)) {
+			continue
+		}
+		if matches, err := config.NewAlternateResolver(config.AlternateContext{}).GetAlternatesForFile(candidate); err == nil && len(matches) > 0 {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("no config provides %s", arg)
+}
+
+func printAlternateExplanation(env *environment, ctx config.AlternateContext, explanation *config.AlternateExplanation) {
+	rel, err := filepath.Rel(env.configPath, explanation.BasePath)
+	if err != nil {
+		rel = explanation.BasePath
+	}
+
+	progress.PrintHeader("Alternates for " + rel)
+	fmt.Println(styles.Mute("Machine: " + describeAlternateContext(ctx)))
+	fmt.Println()
+
+	if len(explanation.Candidates) == 0 {
+		fmt.Println(styles.Warn("No variants found"))
+		return
+	}
+
+	width := 0
+	for _, c := range explanation.Candidates {
+		width = max(width, len(filepath.Base(c.Path)))
+	}
+
+	for i := range explanation.Candidates {
+		c := &explanation.Candidates[i]
+		name := fmt.Sprintf("%-*s", width, filepath.Base(c.Path))
+
+		switch {
+		case c.Err != nil:
+			fmt.Printf("%s %s  %s\n", styles.ErrorIcon, name, styles.ErrorStyle.Render("invalid: "+c.Err.Error()))
+		case !c.Eligible:
+			fmt.Printf("%s %s  %s\n", styles.ErrorIcon, name, styles.Mute("disqualified"))
+		case explanation.Winner == c:
+			fmt.Printf("%s %s  score %d %s\n", styles.SuccessIcon, name, c.Score, styles.SuccessStyle.Render("← winner"))
+		default:
+			fmt.Printf("%s %s  score %d\n", styles.PendingIcon, name, c.Score)
+		}
+
+		if len(c.Suffixes) == 0 {
+			fmt.Println("    " + styles.Mute("base file, no conditions"))
+		}
+		for _, p := range c.Matched {
+			fmt.Println("    " + styles.SuccessIcon + " " + p)
+		}
+		for _, p := range c.Unmatched {
+			fmt.Println("    " + styles.ErrorIcon + " " + p)
+		}
+	}
+
+	fmt.Println()
+	switch {
+	case explanation.Winner == nil:
+		fmt.Println(styles.Warn("No variant matches this machine, nothing will be linked"))
+	case len(explanation.Ties) > 0:
+		names := []string{filepath.Base(explanation.Winner.Path)}
+		for _, t := range explanation.Ties {
+			names = append(names, filepath.Base(t.Path))
+		}
+		fmt.Println(styles.Warn(fmt.Sprintf("Ambiguous: %s all score %d; %s wins by name order",
+			strings.Join(names, ", "), explanation.Winner.Score, names[0])))
+	default:
+		fmt.Println(styles.Success(filepath.Base(explanation.Winner.Path) + " will be linked"))
+	}
+}
+
+func describeAlternateContext(ctx config.AlternateContext) string {
+	parts := []string{
+		"hostname=" + ctx.Hostname,
+		"os=" + ctx.OS,
+		"distro=" + ctx.Distro,
+		"arch=" + ctx.Arch,
+		"profile=" + ctx.Profile,
+		"user=" + ctx.User,
+	}
+	if len(ctx.Features) > 0 {
+		parts = append(parts, "features="+strings.Join(ctx.Features, ","))
+	}
+	if len(ctx.Environments) > 0 {
+		parts = append(parts, "env="+strings.Join(ctx.Environments, ","))
+	}
+
+	keys := make([]string, 0, len(ctx.Settings))
+	for k := range ctx.Settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, nested := ctx.Settings[k].(map[string]any); nested {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("setting.%s=%v", k, ctx.Settings[k]))
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply [config...]",
+	Short: "Apply packages and dotfiles",
+	Long: `Apply the current machine configuration without pulling.
+
+Configs given as arguments (or via --only-config) are linked on their
+own and package installation is skipped, so you can re-link a single
+config after editing it:
+
+  dotts apply shell
+  dotts apply --exclude-config editor
+  dotts apply --only-manager nix,brew
+
+Links whose file or config is no longer part of the machine are removed
+and their originals restored from backup (see 'dotts prune'). Use
+--no-prune to keep them.
+
+Existing files at targets are shown with a diff and you choose whether to
+overwrite them (keeping a backup), keep them, adopt them into the repo or
+merge in $EDITOR; keep and overwrite can be remembered per path, and --ask
+asks again. --backup replaces them with a backup without asking, and
+--force replaces them without a backup.
+
+By default the machine recorded during 'dotts init' is used.`,
+	RunE: runApply,
+}
+
+func init() {
+	applyCmd.Flags().Bool("dry-run", false, "Show what would be done without making changes")
+	applyCmd.Flags().Bool("no-prune", false, "Keep links whose file or config left the repo")
+	addSelectionFlags(applyCmd)
+	addConflictFlags(applyCmd)
+}
+
+// addSelectionFlags registers the flags shared by commands that plan an apply
+func addSelectionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("only-config", nil, "Only link these configs")
+	cmd.Flags().StringSlice("exclude-config", nil, "Do not link these configs")
+	cmd.Flags().StringSlice("only-manager", nil, "Only install packages through these managers (nix, pacman, aur, apt, dnf, brew, cask; brew includes casks)")
+	cmd.Flags().String("machine", "", "Machine or profile to apply (defaults to the initialized machine)")
+	cmd.Flags().Bool("skip-packages", false, "Skip package installation")
+	cmd.Flags().Bool("skip-dotfiles", false, "Skip dotfile linking")
+}
+
+// selectionOptions builds apply options from the selection flags and config arguments
+func selectionOptions(cmd *cobra.Command, args []string, env *environment) (apply.ApplyOptions, error) {
+	onlyConfigs, _ := cmd.Flags().GetStringSlice("only-config")
+	excludeConfigs, _ := cmd.Flags().GetStringSlice("exclude-config")
+	onlyManagers, _ := cmd.Flags().GetStringSlice("only-manager")
+	machine, _ := cmd.Flags().GetString("machine")
+	skipPackages, _ := cmd.Flags().GetBool("skip-packages")
+	skipDotfiles, _ := cmd.Flags().GetBool("skip-dotfiles")
+
+	onlyConfigs = append(onlyConfigs, args...)
+
+	// Selecting configs implies a dotfiles-only run and selecting managers
+	// implies a packages-only run, unless both are given.
+	if len(onlyConfigs) > 0 && len(onlyManagers) == 0 {
+		skipPackages = true
+	}
+	if len(onlyManagers) > 0 && len(onlyConfigs) == 0 && len(excludeConfigs) == 0 {
+		skipDotfiles = true
+	}
+
+	machineName := env.machineName(machine)
+	if machineName == "" {
+		return apply.ApplyOptions{}, fmt.Errorf("no machine recorded in state, use --machine")
+	}
+
+	return apply.ApplyOptions{
+		SkipPackages:   skipPackages,
+		SkipDotfiles:   skipDotfiles,
+		MachineName:    machineName,
+		OnlyConfigs:    onlyConfigs,
+		ExcludeConfigs: excludeConfigs,
+		OnlyManagers:   onlyManagers,
+	}, nil
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	noPrune, _ := cmd.Flags().GetBool("no-prune")
+
+	env, err := loadEnvironment()
+	if err != nil {
+		return err
+	}
+
+	opts, err := selectionOptions(cmd, args, env)
+	if err != nil {
+		return err
+	}
+	opts.DryRun = dryRun
+	opts.NoPrune = noPrune
+	opts.OnModified = modifiedResolver()
+	conflictOptions(cmd, &opts)
+
+	applier, err := apply.New(env.sysInfo, env.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize applier: %w", err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	result, err := applier.Apply(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to apply configuration: %w", err)
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if !printApplyResult(result, "Apply complete!") {
+		return fmt.Errorf("apply finished with %d error(s)", len(result.Errors))
+	}
+
+	env.state.UpdateLastApply()
+	if result.Generation > 0 {
+		env.state.Generation = result.Generation
+	}
+	if err := env.state.Save(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	return nil
+}
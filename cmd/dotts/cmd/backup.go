@@ -0,0 +1,389 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/internal/diff"
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Inspect and restore backups of replaced files",
+	Long: `List, inspect, restore and prune the backups dotts makes of files it
+replaces.
+
+Every backup of a path is kept as a numbered version with a checksum. Old
+versions of paths dotts no longer manages are removed after each apply
+according to the backups section of the repo's config.yaml; the first
+version, the original, always stays:
+
+  backups:
+    keep_last: 5     # versions kept per path
+    max_age: 90d     # remove versions older than this`,
+	Args: cobra.NoArgs,
+	RunE: runBackupList,
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list [path]",
+	Short: "List backups, or the versions of one path",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runBackupList,
+}
+
+var backupShowCmd = &cobra.Command{
+	Use:   "show <path>",
+	Short: "Show a backup version of a path",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBackupShow,
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore <path>",
+	Short: "Put a backup version back in place",
+	Long: `Copy a backup version, the newest by default, back to its original path.
+
+If dotts manages the path, its link is removed and dotts stops managing it
+until the next apply. Anything else at the path is backed up first.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBackupRestore,
+}
+
+var backupPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old backup versions",
+	Long: `Remove backup versions older than --older-than or beyond the newest
+--keep-last of each path. Without flags the policy from the repo's
+config.yaml is used.
+
+The first backup of a path, the original from before dotts, is always
+kept, and so is every backup of a path dotts still manages: prune and
+uninstall restore from them.`,
+	Args: cobra.NoArgs,
+	RunE: runBackupPrune,
+}
+
+func init() {
+	backupShowCmd.Flags().Int("version", 0, "Version to show (defaults to the newest)")
+	backupShowCmd.Flags().Bool("diff", false, "Show the differences from the file now at the path")
+
+	backupRestoreCmd.Flags().Int("version", 0, "Version to restore (defaults to the newest)")
+	backupRestoreCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+
+	backupPruneCmd.Flags().String("older-than", "", "Remove versions older than this (e.g. 30d, 2w, 12h)")
+	backupPruneCmd.Flags().Int("keep-last", 0, "Keep only the newest n versions of each path")
+	backupPruneCmd.Flags().Bool("dry-run", false, "Show what would be removed without deleting anything")
+	backupPruneCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupShowCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+	backupCmd.AddCommand(backupPruneCmd)
+}
+
+// loadApplier opens the applier. Read-only commands leave an interrupted
+// apply for apply or doctor to roll back.
+func loadApplier(readOnly bool) (*apply.Applier, error) {
+	env, err := loadEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	newApplier := apply.New
+	if readOnly {
+		newApplier = apply.NewReadOnly
+	}
+	applier, err := newApplier(env.sysInfo, env.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize applier: %w", err)
+	}
+	return applier, nil
+}
+
+// originalPath turns a path argument into the absolute path backups are
+// indexed by
+func originalPath(arg string) (string, error) {
+	if arg == "~" || strings.HasPrefix(arg, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		arg = filepath.Join(home, arg[1:])
+	}
+	return filepath.Abs(arg)
+}
+
+// backupVersion finds the requested version of path's backups, or the newest
+func backupVersion(backups *linker.BackupManager, arg string, version int) (linker.BackupEntry, error) {
+	path, err := originalPath(arg)
+	if err != nil {
+		return linker.BackupEntry{}, err
+	}
+
+	if version == 0 {
+		if entry, ok := backups.Latest(path); ok {
+			return entry, nil
+		}
+		return linker.BackupEntry{}, fmt.Errorf("no backups of %s", path)
+	}
+	if entry, ok := backups.Version(path, version); ok {
+		return entry, nil
+	}
+	return linker.BackupEntry{}, fmt.Errorf("no backup version %d of %s", version, path)
+}
+
+func runBackupList(cmd *cobra.Command, args []string) error {
+	applier, err := loadApplier(true)
+	if err != nil {
+		return err
+	}
+	backups := applier.Backups()
+
+	if len(args) == 1 {
+		path, err := originalPath(args[0])
+		if err != nil {
+			return err
+		}
+		versions := backups.Versions(path)
+		if len(versions) == 0 {
+			return fmt.Errorf("no backups of %s", path)
+		}
+
+		progress.PrintHeader(path)
+		for i := len(versions) - 1; i >= 0; i-- {
+			printBackupVersion(versions[i], i == len(versions)-1)
+		}
+		return nil
+	}
+
+	entries := backups.List()
+	if len(entries) == 0 {
+		fmt.Println(styles.Mute("No backups"))
+		return nil
+	}
+
+	progress.PrintHeader("Backups")
+	for i := 0; i < len(entries); {
+		path := entries[i].OriginalPath
+		j := i
+		for j < len(entries) && entries[j].OriginalPath == path {
+			j++
+		}
+		latest := entries[j-1]
+		value := fmt.Sprintf("%s · %s", pluralize(j-i, "version"), timeAgo(latest.BackedUpAt))
+		fmt.Println(styles.StatusLine(styles.PendingIcon, path, value))
+		i = j
+	}
+	fmt.Println()
+	fmt.Println(styles.Mute("Run 'dotts backup list <path>' to see the versions of a path"))
+	return nil
+}
+
+func printBackupVersion(entry linker.BackupEntry, latest bool) {
+	icon := styles.PendingIcon
+	if latest {
+		icon = styles.ActiveIcon
+	}
+
+	details := []string{timeAgo(entry.BackedUpAt), entry.BackedUpAt.Format("2006-01-02 15:04")}
+	if entry.IsDir {
+		details = append(details, "directory")
+	}
+	details = append(details, formatSize(entry.Size))
+	if !pathExists(entry.BackupPath) {
+		details = append(details, styles.Warn("missing"))
+	}
+
+	fmt.Println(styles.StatusLine(icon, "v"+strconv.Itoa(entry.Version), strings.Join(details, " · ")))
+}
+
+func runBackupShow(cmd *cobra.Command, args []string) error {
+	version, _ := cmd.Flags().GetInt("version")
+	showDiff, _ := cmd.Flags().GetBool("diff")
+
+	applier, err := loadApplier(true)
+	if err != nil {
+		return err
+	}
+	backups := applier.Backups()
+
+	entry, err := backupVersion(backups, args[0], version)
+	if err != nil {
+		return err
+	}
+
+	progress.PrintHeader(fmt.Sprintf("%s (v%d)", entry.OriginalPath, entry.Version))
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "Backed up", entry.BackedUpAt.Format("2006-01-02 15:04:05")+" · "+timeAgo(entry.BackedUpAt)))
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "Stored at", entry.BackupPath))
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "Size", formatSize(entry.Size)))
+	if entry.Checksum != "" {
+		fmt.Println(styles.StatusLine(styles.InfoIcon, "Checksum", "sha256:"+entry.Checksum))
+	}
+
+	if err := backups.Verify(entry); err != nil {
+		progress.PrintWarning(err.Error())
+	} else if entry.Checksum != "" {
+		progress.PrintSuccess("Checksum verified")
+	}
+
+	if !showDiff {
+		return nil
+	}
+	if entry.IsDir {
+		fmt.Println(styles.Mute("Diffs are only shown for files"))
+		return nil
+	}
+
+	if link, err := os.Readlink(entry.BackupPath); err == nil {
+		fmt.Println(styles.Mute("The backup is a symlink to " + link + ", diffs are only shown for files"))
+		return nil
+	}
+
+	backedUp, err := os.ReadFile(entry.BackupPath)
+	if err != nil {
+		return err
+	}
+	current, err := os.ReadFile(entry.OriginalPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	d := diff.Unified(entry.OriginalPath+" (v"+strconv.Itoa(entry.Version)+")", entry.OriginalPath, string(backedUp), string(current))
+	fmt.Println()
+	if d == "" {
+		fmt.Println(styles.Success("Same as the file now at " + entry.OriginalPath))
+		return nil
+	}
+	apply.PrintDiff(d)
+	return nil
+}
+
+func runBackupRestore(cmd *cobra.Command, args []string) error {
+	version, _ := cmd.Flags().GetInt("version")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	applier, err := loadApplier(false)
+	if err != nil {
+		return err
+	}
+
+	entry, err := backupVersion(applier.Backups(), args[0], version)
+	if err != nil {
+		return err
+	}
+
+	if !yes {
+		ok, err := confirm(fmt.Sprintf("Restore %s from version %d (%s)?", entry.OriginalPath, entry.Version, timeAgo(entry.BackedUpAt)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println(styles.Warn("Restore cancelled."))
+			return nil
+		}
+	}
+
+	replaced, err := applier.RestoreBackup(entry.OriginalPath, entry.Version)
+	if err != nil {
+		return err
+	}
+	if replaced != "" {
+		fmt.Println(styles.Mute("Backed up the replaced file to " + replaced))
+	}
+	progress.PrintSuccess(fmt.Sprintf("Restored %s from version %d", entry.OriginalPath, entry.Version))
+	return nil
+}
+
+func runBackupPrune(cmd *cobra.Command, args []string) error {
+	olderThan, _ := cmd.Flags().GetString("older-than")
+	keepLast, _ := cmd.Flags().GetInt("keep-last")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	if keepLast < 0 {
+		return fmt.Errorf("--keep-last must not be negative")
+	}
+
+	applier, err := loadApplier(dryRun)
+	if err != nil {
+		return err
+	}
+
+	var policy linker.RetentionPolicy
+	if olderThan == "" && keepLast == 0 {
+		if policy, err = applier.BackupPolicy(); err != nil {
+			return err
+		}
+		if policy.IsZero() {
+			fmt.Println(styles.Mute("No retention policy in config.yaml, use --older-than or --keep-last"))
+			return nil
+		}
+	} else {
+		policy.KeepLast = keepLast
+		if olderThan != "" {
+			if policy.MaxAge, err = linker.ParseAge(olderThan); err != nil {
+				return err
+			}
+		}
+	}
+
+	pruned, err := applier.PruneBackups(policy, true)
+	if err != nil {
+		return err
+	}
+	if len(pruned) == 0 {
+		fmt.Println(styles.Success("No backups to prune"))
+		return nil
+	}
+
+	progress.PrintHeader("Backups to Remove")
+	for _, entry := range pruned {
+		fmt.Println(styles.StatusLine(styles.PendingIcon, entry.OriginalPath, fmt.Sprintf("v%d · %s", entry.Version, timeAgo(entry.BackedUpAt))))
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if !yes {
+		fmt.Println()
+		ok, err := confirm(fmt.Sprintf("Remove %s?", pluralize(len(pruned), "backup")))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println(styles.Warn("Prune cancelled."))
+			return nil
+		}
+	}
+
+	if pruned, err = applier.PruneBackups(policy, false); err != nil {
+		return err
+	}
+	fmt.Println()
+	progress.PrintSuccess(fmt.Sprintf("Removed %s", pluralize(len(pruned), "backup")))
+	return nil
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
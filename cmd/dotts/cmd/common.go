@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/state"
+	"github.com/arthur404dev/dotts/internal/system"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
+)
+
+var errNotInitialized = errors.New("dotts is not initialized on this system, run 'dotts init' first")
+
+// environment bundles what most commands need after dotts has been initialized.
+type environment struct {
+	state      *state.State
+	paths      *state.Paths
+	sysInfo    *system.SystemInfo
+	source     *config.Source
+	configPath string
+}
+
+func loadEnvironment() (*environment, error) {
+	st, err := state.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	if !st.IsInitialized() {
+		return nil, errNotInitialized
+	}
+
+	sysInfo, err := system.Detect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect system: %w", err)
+	}
+
+	paths := state.GetPaths()
+
+	configPath := st.ConfigSource.Path
+	if configPath == "" {
+		configPath = paths.ConfigRepo
+	}
+
+	source := &config.Source{
+		URL:     st.ConfigSource.URL,
+		Path:    configPath,
+		Branch:  st.ConfigSource.Branch,
+		IsLocal: st.ConfigSource.Type == state.SourceTypeLocal,
+	}
+
+	return &environment{
+		state:      st,
+		paths:      paths,
+		sysInfo:    sysInfo,
+		source:     source,
+		configPath: configPath,
+	}, nil
+}
+
+// machineName returns the machine (or profile) to resolve, preferring an explicit override.
+func (e *environment) machineName(override string) string {
+	if override != "" {
+		return override
+	}
+	if e.state.Machine.Name != "" {
+		return e.state.Machine.Name
+	}
+	return e.state.Machine.Profile
+}
+
+// interruptContext is cancelled by Ctrl-C or SIGTERM, so an apply in progress
+// can stop and roll back instead of dying halfway
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+func confirm(title string) (bool, error) {
+	var ok bool
+	err := huh.NewConfirm().
+		Title(title).
+		Affirmative("Yes").
+		Negative("No").
+		Value(&ok).
+		WithTheme(styles.GetHuhTheme()).
+		Run()
+	if err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+func timeAgo(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return pluralize(int(d.Minutes()), "minute") + " ago"
+	case d < 24*time.Hour:
+		return pluralize(int(d.Hours()), "hour") + " ago"
+	default:
+		return pluralize(int(d.Hours()/24), "day") + " ago"
+	}
+}
+
+func pluralize(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// pathExists reports whether something is at path; a dangling symlink counts
+func pathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
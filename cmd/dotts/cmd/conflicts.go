@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/internal/diff"
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
+)
+
+// modifiedResolver decides about rendered files that were edited since the
+// last apply. Interactive terminals are asked; otherwise they are left alone
+// and reported by status.
+func modifiedResolver() linker.ConflictResolver {
+	if !isInteractive() {
+		return nil
+	}
+	return promptModified
+}
+
+func isInteractive() bool {
+	return term.IsTerminal(os.Stdin.Fd()) && term.IsTerminal(os.Stdout.Fd())
+}
+
+func promptModified(action linker.LinkAction, local []byte) (linker.Resolution, []byte, error) {
+	rendered := action.Rendered()
+
+	fmt.Println()
+	fmt.Println(styles.Warn(action.Target + " was modified locally"))
+	if action.Diff != "" {
+		apply.PrintDiff(action.Diff)
+	}
+
+	choice := linker.ResolveKeep
+	err := huh.NewSelect[linker.Resolution]().
+		Title("How should dotts handle it?").
+		Options(
+			huh.NewOption("Keep local changes", linker.ResolveKeep),
+			huh.NewOption("Overwrite with the new render", linker.ResolveOverwrite),
+			huh.NewOption("Merge in $EDITOR", linker.ResolveMerge),
+		).
+		Value(&choice).
+		WithTheme(styles.GetHuhTheme()).
+		Run()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if choice != linker.ResolveMerge {
+		return choice, nil, nil
+	}
+
+	merged, err := mergeInEditor(action.Target, local, rendered, "rendered")
+	if err != nil {
+		return "", nil, err
+	}
+	return linker.ResolveMerge, merged, nil
+}
+
+// addConflictFlags registers the policies for existing files at targets
+func addConflictFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("force", false, "Replace existing files without backing them up and overwrite local edits")
+	cmd.Flags().Bool("backup", false, "Back up and replace existing files without asking")
+	cmd.Flags().Bool("ask", false, "Ask again about files with a remembered choice")
+	cmd.MarkFlagsMutuallyExclusive("force", "backup")
+}
+
+// conflictOptions sets how existing files are handled. Without --force or
+// --backup an interactive terminal is asked about each one.
+func conflictOptions(cmd *cobra.Command, opts *apply.ApplyOptions) {
+	force, _ := cmd.Flags().GetBool("force")
+	backup, _ := cmd.Flags().GetBool("backup")
+	ask, _ := cmd.Flags().GetBool("ask")
+
+	opts.Force = force
+	opts.Reask = ask
+	if !force && !backup {
+		opts.OnExisting = existingResolver()
+	}
+}
+
+// existingResolver asks about files dotts would replace when the terminal is
+// interactive; otherwise they are backed up and replaced.
+func existingResolver() linker.ExistingResolver {
+	if !isInteractive() {
+		return nil
+	}
+	return promptExisting
+}
+
+func promptExisting(action linker.LinkAction, local []byte) (linker.Decision, error) {
+	fmt.Println()
+	fmt.Println(styles.Warn(action.Target + " already exists"))
+
+	var incoming []byte
+	if local != nil {
+		var err error
+		if incoming, err = action.Incoming(); err != nil {
+			return linker.Decision{}, err
+		}
+
+		d := action.Diff
+		if d == "" {
+			d = diff.Unified(action.Target, action.Source, string(local), string(incoming))
+		}
+		if d == "" {
+			fmt.Println(styles.Mute("  Same content as the repo"))
+		} else {
+			apply.PrintDiff(d)
+		}
+	}
+
+	options := []huh.Option[linker.Resolution]{
+		huh.NewOption("Overwrite (the local file is backed up)", linker.ResolveOverwrite),
+		huh.NewOption("Keep the local file", linker.ResolveKeep),
+	}
+	if local != nil && action.CanAdopt() {
+		options = append(options, huh.NewOption("Adopt the local file into the repo", linker.ResolveAdopt))
+	}
+	if local != nil {
+		options = append(options, huh.NewOption("Merge in $EDITOR", linker.ResolveMerge))
+	}
+
+	decision := linker.Decision{Resolution: linker.ResolveOverwrite}
+	err := huh.NewSelect[linker.Resolution]().
+		Title("How should dotts handle it?").
+		Options(options...).
+		Value(&decision.Resolution).
+		WithTheme(styles.GetHuhTheme()).
+		Run()
+	if err != nil {
+		return linker.Decision{}, err
+	}
+
+	switch decision.Resolution {
+	case linker.ResolveKeep, linker.ResolveOverwrite:
+		decision.Remember, err = confirm("Remember this for " + action.Target + "?")
+	case linker.ResolveMerge:
+		decision.Merged, err = mergeInEditor(action.Target, local, incoming, "repo")
+	}
+	if err != nil {
+		return linker.Decision{}, err
+	}
+	return decision, nil
+}
+
+// mergeInEditor opens local and incoming content, joined with conflict
+// markers, in $EDITOR and returns the result.
+func mergeInEditor(target string, local, incoming []byte, incomingName string) ([]byte, error) {
+	path, err := diff.WriteMerge(target, local, incoming, incomingName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := runEditor(path); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return diff.ReadMerge(path)
+}
+
+func runEditor(path string) error {
+	cmd := diff.Editor(path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", cmd.Args[0], err)
+	}
+	return nil
+}
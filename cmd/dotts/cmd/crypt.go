@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/internal/crypt"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
+)
+
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt <file>",
+	Short: "Encrypt a file for the config repo",
+	Long: `Encrypt a file with age so it can be committed to the config repo.
+
+The result is written next to the file with a .age extension. Files ending
+in .age under configs/ are decrypted on apply and written to their target
+(without the extension) as 0600 files instead of being symlinked.
+
+Recipients come from encryption.recipients in config.yaml, or the public key
+of the local identity (~/.config/dotts/identity.txt) when none are set.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEncrypt,
+}
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt <file.age>",
+	Short: "Decrypt or edit an encrypted file",
+	Long: `Decrypt an encrypted file from the config repo and print it.
+
+Use --edit to open the plaintext in $EDITOR and re-encrypt it when it
+changes, or --output to write it to a file.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDecrypt,
+}
+
+func init() {
+	encryptCmd.Flags().StringP("output", "o", "", "Write to this path instead of <file>.age")
+	encryptCmd.Flags().Bool("rm", false, "Remove the plaintext file after encrypting")
+	encryptCmd.Flags().BoolP("force", "f", false, "Overwrite an existing encrypted file")
+
+	decryptCmd.Flags().StringP("output", "o", "", "Write the plaintext to this path (mode 0600)")
+	decryptCmd.Flags().BoolP("edit", "e", false, "Edit in $EDITOR and re-encrypt")
+}
+
+// loadCrypt uses the repo's encryption settings when dotts is initialized
+// and the defaults otherwise
+func loadCrypt() (*crypt.Age, error) {
+	env, err := loadEnvironment()
+	if errors.Is(err, errNotInitialized) {
+		return crypt.New("", nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	applier, err := apply.NewReadOnly(env.sysInfo, env.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize applier: %w", err)
+	}
+	return applier.Crypt()
+}
+
+func runEncrypt(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	remove, _ := cmd.Flags().GetBool("rm")
+	force, _ := cmd.Flags().GetBool("force")
+
+	source := args[0]
+	if crypt.IsEncrypted(source) {
+		return fmt.Errorf("%s is already encrypted", source)
+	}
+	if output == "" {
+		output = source + crypt.Ext
+	}
+	if _, err := os.Stat(output); err == nil && !force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", output)
+	}
+
+	plaintext, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	age, err := loadCrypt()
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := age.Encrypt(plaintext)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, ciphertext, 0644); err != nil {
+		return err
+	}
+	fmt.Println(styles.Success("Encrypted " + source + " to " + output))
+
+	if remove {
+		if err := os.Remove(source); err != nil {
+			return err
+		}
+		fmt.Println(styles.Mute("Removed " + source))
+	} else {
+		fmt.Println(styles.Mute("Remember to delete or ignore the plaintext before committing"))
+	}
+	return nil
+}
+
+func runDecrypt(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	edit, _ := cmd.Flags().GetBool("edit")
+
+	if edit && output != "" {
+		return errors.New("--edit and --output cannot be combined")
+	}
+
+	source := args[0]
+	age, err := loadCrypt()
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := age.Decrypt(source)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case edit:
+		return editEncrypted(age, source, plaintext)
+	case output != "":
+		if err := os.WriteFile(output, plaintext, 0600); err != nil {
+			return err
+		}
+		fmt.Println(styles.Success("Decrypted " + source + " to " + output))
+		return nil
+	default:
+		_, err := os.Stdout.Write(plaintext)
+		return err
+	}
+}
+
+// editEncrypted opens the plaintext in $EDITOR and re-encrypts it in place
+// when it changed. The plaintext only lives in a private temp file.
+func editEncrypted(age *crypt.Age, source string, plaintext []byte) error {
+	name := strings.TrimSuffix(filepath.Base(source), crypt.Ext)
+	tmp, err := os.CreateTemp("", "dotts-edit-*-"+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(plaintext); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := runEditor(tmp.Name()); err != nil {
+		return err
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(edited, plaintext) {
+		fmt.Println(styles.Mute("No changes"))
+		return nil
+	}
+
+	ciphertext, err := age.Encrypt(edited)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(source, ciphertext, info.Mode()); err != nil {
+		return err
+	}
+	fmt.Println(styles.Success("Re-encrypted " + source))
+	return nil
+}
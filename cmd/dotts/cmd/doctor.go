@@ -1,9 +1,19 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/doctor"
+	"github.com/arthur404dev/dotts/internal/installer"
+	"github.com/arthur404dev/dotts/internal/state"
+	"github.com/arthur404dev/dotts/internal/system"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
 )
 
 var doctorCmd = &cobra.Command{
@@ -13,10 +23,14 @@ var doctorCmd = &cobra.Command{
 
 Checks:
   • Required tools installed (git, nix, etc.)
-  • Config repo accessible
-  • Dotfiles properly linked
-  • Package managers working
-  • No broken symlinks`,
+  • Package managers detected and usable
+  • Config repo present and valid
+  • personal.yaml complete
+  • No broken or foreign symlinks
+  • Backup index consistent with backup files
+
+With --fix, remediations are applied: links are recreated, stale
+manifest entries are pruned and originals are restored from backup.`,
 	RunE: runDoctor,
 }
 
@@ -25,7 +39,159 @@ func init() {
 }
 
 func runDoctor(cmd *cobra.Command, args []string) error {
-	fmt.Println("Running dotts health checks...")
-	fmt.Println("(Doctor checks will be implemented here)")
+	fix, _ := cmd.Flags().GetBool("fix")
+
+	env, err := loadDoctorEnv()
+	if err != nil {
+		return err
+	}
+
+	registry := doctor.NewRegistry()
+
+	progress.PrintHeader("Running dotts health checks")
+	reports := registry.Run(env)
+	printDoctorReports(reports)
+
+	fixable := 0
+	for _, r := range reports {
+		if r.Result.Status != doctor.StatusPass {
+			fixable += len(r.Result.Fixes)
+		}
+	}
+
+	if !fix {
+		if fixable > 0 {
+			fmt.Println()
+			fmt.Println(styles.Mute(fmt.Sprintf("Run 'dotts doctor --fix' to apply %d fix(es).", fixable)))
+		}
+		return nil
+	}
+
+	if fixable == 0 {
+		fmt.Println()
+		fmt.Println(styles.Mute("Nothing to fix."))
+		return nil
+	}
+
+	progress.PrintHeader("Applying fixes")
+	for _, r := range reports {
+		if r.Result.Status == doctor.StatusPass {
+			continue
+		}
+		for _, f := range r.Result.Fixes {
+			if err := f.Apply(); err != nil {
+				progress.PrintError(fmt.Sprintf("%s: %v", f.Description, err))
+				continue
+			}
+			progress.PrintSuccess(f.Description)
+		}
+	}
+
+	if env.Linker != nil {
+		if err := env.Linker.Save(); err != nil {
+			return fmt.Errorf("failed to save manifest: %w", err)
+		}
+	}
+
+	progress.PrintHeader("Re-checking")
+	printDoctorReports(registry.Run(env))
+
 	return nil
 }
+
+// loadDoctorEnv collects whatever is available; unlike other commands,
+// doctor still runs when dotts is not initialized.
+func loadDoctorEnv() (*doctor.Env, error) {
+	env, err := loadEnvironment()
+	if err != nil && !errors.Is(err, errNotInitialized) {
+		return nil, err
+	}
+
+	if env == nil {
+		sysInfo, err := system.Detect()
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect system: %w", err)
+		}
+		st, _ := state.Load()
+		return &doctor.Env{
+			SysInfo:    sysInfo,
+			State:      st,
+			Paths:      state.GetPaths(),
+			Installers: installer.NewRegistry(sysInfo),
+		}, nil
+	}
+
+	applier, err := apply.New(env.sysInfo, env.configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize applier: %w", err)
+	}
+
+	// A broken machine config is reported by the checks; templates still
+	// render with system and personal values.
+	machineName := env.machineName("")
+	resolved, err := applier.Resolve(machineName)
+	if err != nil {
+		resolved = &config.ResolvedConfig{}
+	}
+
+	templates, err := applier.TemplateEngine(resolved, machineName)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypter, err := applier.Crypt()
+	if err != nil {
+		return nil, err
+	}
+
+	return &doctor.Env{
+		SysInfo:    env.sysInfo,
+		State:      env.state,
+		Paths:      env.paths,
+		Source:     env.source,
+		Linker:     applier.GetLinker(),
+		Installers: installer.NewRegistry(env.sysInfo),
+		Templates:  templates,
+		Decrypter:  decrypter,
+	}, nil
+}
+
+func printDoctorReports(reports []doctor.Report) {
+	counts := make(map[doctor.Status]int)
+
+	for _, r := range reports {
+		counts[r.Result.Status]++
+		fmt.Println(styles.StatusLine(doctorIcon(r.Result.Status), r.Check, r.Result.Message))
+		if r.Result.Status == doctor.StatusPass {
+			continue
+		}
+		for _, detail := range r.Result.Details {
+			progress.PrintMuted("  " + detail)
+		}
+	}
+
+	fmt.Println()
+	summary := fmt.Sprintf("%d passed, %d warning(s), %d failed",
+		counts[doctor.StatusPass], counts[doctor.StatusWarn], counts[doctor.StatusFail])
+	switch {
+	case counts[doctor.StatusFail] > 0:
+		fmt.Println(styles.Err(summary))
+	case counts[doctor.StatusWarn] > 0:
+		fmt.Println(styles.Warn(summary))
+	default:
+		fmt.Println(styles.Success(summary))
+	}
+}
+
+func doctorIcon(status doctor.Status) string {
+	switch status {
+	case doctor.StatusPass:
+		return styles.SuccessIcon
+	case doctor.StatusWarn:
+		return styles.WarningIcon
+	case doctor.StatusFail:
+		return styles.ErrorIcon
+	default:
+		return styles.PendingIcon
+	}
+}
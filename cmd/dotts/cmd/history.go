@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/internal/history"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List apply generations",
+	Long: `List the generations recorded by successful applies, newest first.
+
+Each generation records the links and written files dotts had in place, the
+resolved machine, the config repo commit, the installed packages and the
+backups that apply made. Compare two with 'dotts diff-generation' and go
+back to one with 'dotts rollback'.`,
+	Args: cobra.NoArgs,
+	RunE: runHistory,
+}
+
+var diffGenerationCmd = &cobra.Command{
+	Use:   "diff-generation <from> <to>",
+	Short: "Compare two generations",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runDiffGeneration,
+}
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [generation]",
+	Short: "Restore the links and files of an earlier generation",
+	Long: `Put back the links, rendered templates, copies and decrypted files of an
+earlier generation, the one before the current by default. Links the
+generation did not have are pruned and their originals restored from backup.
+
+Packages are not uninstalled, and symlinks point at the config repo as it is
+now. Decrypted files and templates that use secrets are not kept in history,
+so they are rendered again from the current source. The rollback is recorded as a new generation. Without an argument,
+generations made by earlier rollbacks are skipped, so rolling back twice
+goes two generations back rather than undoing the first rollback.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRollback,
+}
+
+func init() {
+	rollbackCmd.Flags().Bool("dry-run", false, "Show what would be restored without changing anything")
+	rollbackCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+}
+
+// loadHistory opens the applier and its generation store
+func loadHistory(readOnly bool) (*environment, *apply.Applier, *history.Store, error) {
+	env, err := loadEnvironment()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	newApplier := apply.New
+	if readOnly {
+		newApplier = apply.NewReadOnly
+	}
+	applier, err := newApplier(env.sysInfo, env.configPath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to initialize applier: %w", err)
+	}
+	return env, applier, applier.History(), nil
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	env, _, store, err := loadHistory(true)
+	if err != nil {
+		return err
+	}
+
+	generations, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(generations) == 0 {
+		fmt.Println(styles.Mute("No generations yet, they are recorded by 'dotts apply'"))
+		return nil
+	}
+
+	current := env.state.Generation
+	if current == 0 {
+		current = generations[len(generations)-1].Number
+	}
+
+	progress.PrintHeader("Generations")
+	for i := len(generations) - 1; i >= 0; i-- {
+		g := generations[i]
+
+		icon := styles.PendingIcon
+		if g.Number == current {
+			icon = styles.ActiveIcon
+		}
+
+		details := []string{timeAgo(g.CreatedAt), g.Machine}
+		if g.Commit != "" {
+			details = append(details, g.Commit)
+		}
+		details = append(details, pluralize(len(g.Links), "link"))
+		if g.Note != "" {
+			details = append(details, g.Note)
+		}
+
+		fmt.Println(styles.StatusLine(icon, strconv.Itoa(g.Number), strings.Join(details, " · ")))
+	}
+	return nil
+}
+
+func runDiffGeneration(cmd *cobra.Command, args []string) error {
+	_, _, store, err := loadHistory(true)
+	if err != nil {
+		return err
+	}
+
+	var gens [2]*history.Generation
+	for i, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid generation %q", arg)
+		}
+		if gens[i], err = store.Get(n); err != nil {
+			return err
+		}
+	}
+
+	d := history.Compare(gens[0], gens[1])
+
+	progress.PrintHeader(fmt.Sprintf("Generation %d → %d", d.From.Number, d.To.Number))
+	if d.From.Commit != d.To.Commit {
+		fmt.Println(styles.StatusLine(styles.InfoIcon, "Commit", d.From.Commit+" → "+d.To.Commit))
+	}
+	if d.IsEmpty() {
+		fmt.Println(styles.Success("No differences"))
+		return nil
+	}
+
+	printChanges("Configs", d.ConfigsAdded, d.ConfigsRemoved, nil)
+
+	managers := make([]string, 0, len(d.PackagesAdded)+len(d.PackagesRemoved))
+	for m := range d.PackagesAdded {
+		managers = append(managers, m)
+	}
+	for m := range d.PackagesRemoved {
+		if _, ok := d.PackagesAdded[m]; !ok {
+			managers = append(managers, m)
+		}
+	}
+	sort.Strings(managers)
+	for _, m := range managers {
+		printChanges("Packages ("+m+")", d.PackagesAdded[m], d.PackagesRemoved[m], nil)
+	}
+
+	printChanges("Links", d.LinksAdded, d.LinksRemoved, d.LinksChanged)
+	return nil
+}
+
+func printChanges(title string, added, removed, changed []string) {
+	if len(added)+len(removed)+len(changed) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(styles.Info(title + ":"))
+	for _, s := range added {
+		fmt.Println("  " + styles.SuccessStyle.Render("+") + " " + s)
+	}
+	for _, s := range removed {
+		fmt.Println("  " + styles.ErrorStyle.Render("-") + " " + s)
+	}
+	for _, s := range changed {
+		fmt.Println("  " + styles.AccentStyle.Render("~") + " " + s)
+	}
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	env, applier, store, err := loadHistory(dryRun)
+	if err != nil {
+		return err
+	}
+
+	target, err := rollbackTarget(env, store, args)
+	if err != nil {
+		return err
+	}
+
+	actions := applier.PlanRollback(target)
+	plan := &apply.Plan{Machine: target.Machine, Links: actions}
+
+	progress.PrintHeader(fmt.Sprintf("Rollback to generation %d", target.Number))
+	apply.PrintPlan(plan, false)
+
+	if dryRun || plan.IsEmpty() {
+		return nil
+	}
+	if errs := plan.LinkErrors(); len(errs) > 0 {
+		fmt.Println(styles.Mute("Links that cannot be restored are skipped"))
+	}
+
+	if !yes {
+		fmt.Println()
+		ok, err := confirm(fmt.Sprintf("Roll back to generation %d?", target.Number))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println(styles.Warn("Rollback cancelled."))
+			return nil
+		}
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	result, err := applier.Rollback(ctx, target, actions)
+	if err != nil {
+		return err
+	}
+	if !printApplyResult(result, fmt.Sprintf("Rolled back to generation %d", target.Number)) {
+		return fmt.Errorf("rollback failed with %d error(s)", len(result.Errors))
+	}
+
+	env.state.Generation = result.Generation
+	if err := env.state.Save(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+// rollbackTarget is the generation named in args, or the one before the
+// current generation. Generations made by a rollback are skipped, so
+// repeated rollbacks walk back through history instead of toggling.
+func rollbackTarget(env *environment, store *history.Store, args []string) (*history.Generation, error) {
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid generation %q", args[0])
+		}
+		return store.Get(n)
+	}
+
+	generations, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	current := env.state.Generation
+	if current == 0 && len(generations) > 0 {
+		current = generations[len(generations)-1].Number
+	}
+	return history.Previous(generations, current)
+}
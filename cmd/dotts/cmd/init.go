@@ -116,6 +116,8 @@ func runInit(cmd *cobra.Command, args []string) error {
 		SkipPackages: initSkipPackages,
 		SkipDotfiles: initSkipDotfiles,
 		MachineName:  machineName,
+		Lifecycle:    apply.LifecycleInstall,
+		OnExisting:   existingResolver(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to apply configuration: %w", err)
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+)
+
+var planCmd = &cobra.Command{
+	Use:     "plan [config...]",
+	Aliases: []string{"diff"},
+	Short:   "Show what apply would change",
+	Long: `Compute the full change plan for the current machine without applying it.
+
+The plan lists packages to install or skip, links to create, replace,
+back up or leave alone, templates whose rendered output would change
+(with a unified diff), and scripts that would run.
+
+Use --output json for a machine-readable plan.`,
+	RunE: runPlan,
+}
+
+func init() {
+	planCmd.Flags().StringP("output", "o", "text", "Output format (text, json)")
+	planCmd.Flags().Bool("no-diff", false, "Hide template diffs in text output")
+	addSelectionFlags(planCmd)
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	noDiff, _ := cmd.Flags().GetBool("no-diff")
+
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format %q (expected text or json)", output)
+	}
+
+	env, err := loadEnvironment()
+	if err != nil {
+		return err
+	}
+
+	opts, err := selectionOptions(cmd, args, env)
+	if err != nil {
+		return err
+	}
+
+	applier, err := apply.NewReadOnly(env.sysInfo, env.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize applier: %w", err)
+	}
+
+	plan, err := applier.Plan(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+
+	progress.PrintHeader(fmt.Sprintf("Plan for %s", plan.Machine))
+	apply.PrintPlan(plan, !noDiff)
+	return nil
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune [config...]",
+	Short: "Remove links that are no longer in the config repo",
+	Long: `Remove managed links whose config was dropped from the machine's
+profiles or whose file was deleted from configs/.
+
+Each removed link is replaced by the original file when dotts backed one up.
+Copies and rendered templates that were edited locally are left in place and
+only forgotten. 'dotts apply' prunes the same links automatically; this
+command lets you review them first.
+
+Configs given as arguments limit pruning to their links, including configs
+that are no longer applied.`,
+	RunE: runPrune,
+}
+
+func init() {
+	pruneCmd.Flags().Bool("dry-run", false, "Show what would be pruned without changing anything")
+	pruneCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+	pruneCmd.Flags().String("machine", "", "Machine or profile to compare against (defaults to the initialized machine)")
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	yes, _ := cmd.Flags().GetBool("yes")
+	machine, _ := cmd.Flags().GetString("machine")
+
+	env, err := loadEnvironment()
+	if err != nil {
+		return err
+	}
+
+	machineName := env.machineName(machine)
+	if machineName == "" {
+		return fmt.Errorf("no machine recorded in state, use --machine")
+	}
+
+	newApplier := apply.New
+	if dryRun {
+		newApplier = apply.NewReadOnly
+	}
+	applier, err := newApplier(env.sysInfo, env.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize applier: %w", err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	prunes, err := applier.PlanPrune(ctx, machineName, args)
+	if err != nil {
+		return err
+	}
+
+	if len(prunes) == 0 {
+		fmt.Println(styles.Success("No stale links"))
+		return nil
+	}
+
+	progress.PrintHeader("Stale Links")
+	apply.PrintPlan(&apply.Plan{Machine: machineName, Links: prunes}, false)
+
+	if dryRun {
+		return nil
+	}
+
+	if !yes {
+		fmt.Println()
+		ok, err := confirm(fmt.Sprintf("Prune %d link(s)?", len(prunes)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println(styles.Warn("Prune cancelled."))
+			return nil
+		}
+	}
+
+	result, err := applier.Prune(ctx, prunes)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	if !result.Success() {
+		for _, e := range result.Errors {
+			progress.PrintError(e.Error())
+		}
+		progress.PrintWarning("Nothing was pruned, every link was put back")
+		return fmt.Errorf("prune failed with %d error(s)", len(result.Errors))
+	}
+	for _, target := range result.Restored {
+		fmt.Println(styles.Mute("Restored " + target + " from backup"))
+	}
+	progress.PrintSuccess(fmt.Sprintf("Pruned %d link(s)", len(result.Pruned)))
+	return nil
+}
@@ -35,6 +35,7 @@ Get started:
   dotts               Launch interactive TUI
   dotts init          Bootstrap a new system (CLI mode)
   dotts update        Update configs and packages
+  dotts apply         Re-apply the current configuration
   dotts status        Show current state
 
 Documentation: https://dotts.4o4.sh/docs`,
@@ -61,6 +62,19 @@ Built: %s
 
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(addCmd)
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(alternatesCmd)
+	rootCmd.AddCommand(templatesCmd)
+	rootCmd.AddCommand(encryptCmd)
+	rootCmd.AddCommand(decryptCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(diffGenerationCmd)
+	rootCmd.AddCommand(rollbackCmd)
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(uninstallCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(doctorCmd)
 	rootCmd.AddCommand(configCmd)
@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/installer"
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
 )
 
 var statusCmd = &cobra.Command{
@@ -12,17 +20,225 @@ var statusCmd = &cobra.Command{
 	Long: `Display the current dotts configuration state.
 
 Shows:
-  • Config source and last update
+  • Config source, current commit and last update
   • Current machine and profile
   • Enabled features
+  • Link health (healthy, broken and foreign links)
   • Package status
-  • Any pending changes`,
+  • Any drift from the last apply`,
 	RunE: runStatus,
 }
 
+func init() {
+	statusCmd.Flags().Bool("skip-packages", false, "Skip checking installed packages")
+}
+
 func runStatus(cmd *cobra.Command, args []string) error {
-	fmt.Println("dotts status")
-	fmt.Println("============")
-	fmt.Println("(Status display will be implemented here)")
+	skipPackages, _ := cmd.Flags().GetBool("skip-packages")
+
+	env, err := loadEnvironment()
+	if err != nil {
+		if errors.Is(err, errNotInitialized) {
+			fmt.Println(styles.Warn("dotts is not initialized on this system."))
+			fmt.Println(styles.Mute("Run 'dotts init' to get started."))
+			return nil
+		}
+		return err
+	}
+
+	drift := 0
+
+	progress.PrintHeader("dotts status")
+	drift += printSourceStatus(env)
+	printMachineStatus(env)
+
+	drift += printLinkStatus(env)
+
+	if !skipPackages {
+		drift += printPackageStatus(env)
+	}
+
+	fmt.Println()
+	if drift == 0 {
+		fmt.Println(styles.Success("No drift detected"))
+	} else {
+		fmt.Println(styles.Warn(fmt.Sprintf("Drift detected: %d issue(s)", drift)))
+		fmt.Println(styles.Mute("  Run 'dotts update' to re-apply or 'dotts doctor' for details."))
+	}
+
 	return nil
 }
+
+func printSourceStatus(env *environment) int {
+	src := env.state.ConfigSource
+	drift := 0
+
+	fmt.Println()
+	fmt.Println(styles.Title("Config Source"))
+
+	location := src.URL
+	if location == "" {
+		location = env.configPath
+	}
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "Source", fmt.Sprintf("%s (%s)", location, src.Type)))
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "Path", env.configPath))
+
+	commit, err := env.source.GetCurrentCommit()
+	switch {
+	case err != nil:
+		fmt.Println(styles.StatusLine(styles.WarningIcon, "Commit", "unknown ("+err.Error()+")"))
+	case src.LastCommit == "":
+		fmt.Println(styles.StatusLine(styles.WarningIcon, "Commit", commit+" (no recorded pull)"))
+	case commitsMatch(commit, src.LastCommit):
+		fmt.Println(styles.StatusLine(styles.SuccessIcon, "Commit", commit+" (matches last pull)"))
+	default:
+		fmt.Println(styles.StatusLine(styles.WarningIcon, "Commit",
+			fmt.Sprintf("%s (last pulled %s)", commit, src.LastCommit)))
+		drift++
+	}
+
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "Last Pull", timeAgo(src.LastPull)))
+
+	applyIcon := styles.InfoIcon
+	if env.state.LastApply.IsZero() {
+		applyIcon = styles.WarningIcon
+	}
+	fmt.Println(styles.StatusLine(applyIcon, "Last Apply", timeAgo(env.state.LastApply)))
+
+	return drift
+}
+
+func printMachineStatus(env *environment) {
+	m := env.state.Machine
+
+	fmt.Println()
+	fmt.Println(styles.Title("Machine"))
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "Name", m.Name))
+	if m.Profile != "" {
+		fmt.Println(styles.StatusLine(styles.InfoIcon, "Profile", m.Profile))
+	}
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "Hostname", m.Hostname))
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "System", fmt.Sprintf("%s/%s", m.OS, m.Distro)))
+
+	features := "none"
+	if len(env.state.Features) > 0 {
+		features = strings.Join(env.state.Features, ", ")
+	}
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "Features", features))
+}
+
+func printLinkStatus(env *environment) int {
+	fmt.Println()
+	fmt.Println(styles.Title("Links"))
+
+	lnk, err := linker.OpenSymlinkLinker(env.paths.DataDir, env.configPath)
+	if err != nil {
+		fmt.Println(styles.StatusLine(styles.ErrorIcon, "Manifest", err.Error()))
+		return 1
+	}
+
+	status, err := lnk.Status()
+	if err != nil {
+		fmt.Println(styles.StatusLine(styles.ErrorIcon, "Manifest", err.Error()))
+		return 1
+	}
+
+	fmt.Println(styles.StatusLine(styles.SuccessIcon, "Healthy", fmt.Sprintf("%d", len(status.Links))))
+
+	brokenIcon := styles.SuccessIcon
+	if len(status.Broken) > 0 {
+		brokenIcon = styles.ErrorIcon
+	}
+	fmt.Println(styles.StatusLine(brokenIcon, "Broken", fmt.Sprintf("%d", len(status.Broken))))
+	for _, target := range status.Broken {
+		progress.PrintMuted("  " + target)
+	}
+
+	foreignIcon := styles.SuccessIcon
+	if len(status.Foreign) > 0 {
+		foreignIcon = styles.WarningIcon
+	}
+	fmt.Println(styles.StatusLine(foreignIcon, "Foreign", fmt.Sprintf("%d", len(status.Foreign))))
+	for _, target := range status.Foreign {
+		progress.PrintMuted("  " + target)
+	}
+
+	modifiedIcon := styles.SuccessIcon
+	if len(status.Modified) > 0 {
+		modifiedIcon = styles.WarningIcon
+	}
+	fmt.Println(styles.StatusLine(modifiedIcon, "Modified", fmt.Sprintf("%d", len(status.Modified))))
+	for _, target := range status.Modified {
+		progress.PrintMuted("  " + target + " (edited since last apply)")
+	}
+
+	staleIcon := styles.SuccessIcon
+	if len(status.Stale) > 0 {
+		staleIcon = styles.WarningIcon
+	}
+	fmt.Println(styles.StatusLine(staleIcon, "Stale", fmt.Sprintf("%d", len(status.Stale))))
+	for _, target := range status.Stale {
+		progress.PrintMuted("  " + target + " (source changed, run dotts apply)")
+	}
+
+	issues := len(status.Broken) + len(status.Foreign) + len(status.Modified) + len(status.Stale)
+	if lnk.Interrupted() {
+		fmt.Println(styles.StatusLine(styles.ErrorIcon, "Interrupted", "an apply crashed, run dotts apply or dotts doctor to roll it back"))
+		issues++
+	}
+	return issues
+}
+
+func printPackageStatus(env *environment) int {
+	fmt.Println()
+	fmt.Println(styles.Title("Packages"))
+
+	resolver := config.NewResolver(config.NewLoader(env.configPath))
+	resolved, err := resolver.Resolve(env.machineName(""))
+	if err != nil {
+		fmt.Println(styles.StatusLine(styles.ErrorIcon, "Resolve", err.Error()))
+		return 1
+	}
+
+	if resolved.Packages == nil {
+		fmt.Println(styles.Mute("  No packages declared"))
+		return 0
+	}
+
+	registry := installer.NewRegistry(env.sysInfo)
+	plan := registry.CreatePlan(resolved.Packages)
+	if plan.IsEmpty() {
+		fmt.Println(styles.Mute("  No packages for this system"))
+		return 0
+	}
+
+	drift := 0
+	for _, ps := range registry.CheckPlan(plan) {
+		total := len(ps.Installed) + len(ps.Missing)
+
+		switch {
+		case !ps.Available:
+			fmt.Println(styles.StatusLine(styles.ErrorIcon, ps.Name,
+				fmt.Sprintf("%s not available (%d packages)", ps.Installer, total)))
+			drift += len(ps.Missing)
+		case len(ps.Missing) > 0:
+			fmt.Println(styles.StatusLine(styles.WarningIcon, ps.Name,
+				fmt.Sprintf("%d/%d installed", len(ps.Installed), total)))
+			progress.PrintMuted("  missing: " + strings.Join(ps.Missing, ", "))
+			drift += len(ps.Missing)
+		default:
+			fmt.Println(styles.StatusLine(styles.SuccessIcon, ps.Name,
+				fmt.Sprintf("%d/%d installed", len(ps.Installed), total)))
+		}
+	}
+
+	return drift
+}
+
+// commitsMatch compares two commit hashes that may be abbreviated to different lengths.
+func commitsMatch(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
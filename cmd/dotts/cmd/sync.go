@@ -2,8 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
 )
 
 var syncCmd = &cobra.Command{
@@ -11,22 +17,140 @@ var syncCmd = &cobra.Command{
 	Short: "Sync local changes to config repo",
 	Long: `Sync local dotfile edits back to the config repository.
 
-This command detects changes you've made to dotfiles and
-copies them back to the config repo for committing.`,
+Edits to symlinked files already land in the repo. Rendered templates,
+copies and decrypted files are real files, so their edits stay on this
+machine until synced: each one is written back to its source, ready to
+review and commit.
+
+Template edits are carried over line by line. Unchanged lines keep their
+placeholders, and in edited lines your personal values and the values of
+placeholders the template uses become placeholders again. Templates with
+<<% %>> logic, and files whose source changed since the last apply, are
+shown but not synced.
+
+Each file is confirmed separately unless --all is given.`,
+	Args: cobra.NoArgs,
 	RunE: runSync,
 }
 
 func init() {
 	syncCmd.Flags().Bool("dry-run", false, "Show what would be synced")
 	syncCmd.Flags().BoolP("all", "a", false, "Sync all changed files")
+	syncCmd.Flags().String("machine", "", "Machine or profile to render templates for (defaults to the initialized machine)")
 }
 
 func runSync(cmd *cobra.Command, args []string) error {
 	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	all, _ := cmd.Flags().GetBool("all")
+	machine, _ := cmd.Flags().GetString("machine")
+
+	env, err := loadEnvironment()
+	if err != nil {
+		return err
+	}
+	machineName := env.machineName(machine)
+	if machineName == "" {
+		return fmt.Errorf("no machine recorded in state, use --machine")
+	}
+
+	newApplier := apply.New
+	if dryRun {
+		newApplier = apply.NewReadOnly
+	}
+	applier, err := newApplier(env.sysInfo, env.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize applier: %w", err)
+	}
+
+	changes, err := applier.PlanSync(machineName)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		progress.PrintSuccess("Nothing to sync, every template and copy matches its source")
+		return nil
+	}
+
 	if dryRun {
 		fmt.Println("Dry run - files that would be synced:")
 	} else {
 		fmt.Println("Syncing local changes to config repo...")
 	}
+
+	syncable := 0
+	for _, change := range changes {
+		printSyncChange(change, env.configPath)
+		if change.CanSync() {
+			syncable++
+		}
+	}
+
+	fmt.Println()
+	if syncable == 0 {
+		progress.PrintWarning("No edits can be synced")
+		return nil
+	}
+	if dryRun {
+		fmt.Println(styles.Mute(fmt.Sprintf("%s would be synced, run without --dry-run to write them", pluralize(syncable, "file"))))
+		return nil
+	}
+	if !all && !isInteractive() {
+		return fmt.Errorf("not running in a terminal, use --all to sync every file")
+	}
+
+	var selected []apply.SyncChange
+	for _, change := range changes {
+		if !change.CanSync() {
+			continue
+		}
+		if !all {
+			ok, err := confirm(fmt.Sprintf("Sync %s into %s?", change.Entry.Target, repoRelative(change.Entry.Source, env.configPath)))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+		}
+		selected = append(selected, change)
+	}
+	if len(selected) == 0 {
+		fmt.Println(styles.Warn("Nothing synced."))
+		return nil
+	}
+
+	if err := applier.Sync(selected); err != nil {
+		return fmt.Errorf("sync failed: %w", err)
+	}
+	progress.PrintSuccess(fmt.Sprintf("Synced %s into %s", pluralize(len(selected), "file"), env.configPath))
+	fmt.Println(styles.Mute(fmt.Sprintf("Review with 'git -C %s diff' and commit the changes", env.configPath)))
 	return nil
 }
+
+// printSyncChange shows an edited target and the changes to its source
+func printSyncChange(change apply.SyncChange, repo string) {
+	fmt.Println()
+	source := repoRelative(change.Entry.Source, repo)
+	if !change.CanSync() {
+		fmt.Println(styles.StatusLine(styles.PendingIcon, change.Entry.Target, "not synced: "+change.Reason))
+		return
+	}
+
+	fmt.Println(styles.StatusLine(styles.ActiveIcon, change.Entry.Target, "→ "+source))
+	if len(change.Placeholders) > 0 {
+		fmt.Println(styles.Mute("    placeholders kept for " + strings.Join(change.Placeholders, ", ")))
+	}
+	if change.Entry.IsEncrypted {
+		fmt.Println(styles.Mute("    encrypted, diff not shown"))
+		return
+	}
+	apply.PrintDiff(change.Diff)
+}
+
+// repoRelative shortens a source path to its place in the config repo
+func repoRelative(path, repo string) string {
+	if rel, err := filepath.Rel(repo, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
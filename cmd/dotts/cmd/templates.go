@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/template"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
+)
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Inspect config templates",
+}
+
+var templatesCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Report template values that are not set",
+	Long: `Walk every config used by the machine and report each placeholder or
+template field that has no value, with its file and line.
+
+Exits non-zero when anything is missing, so it can run in CI.`,
+	RunE: runTemplatesCheck,
+}
+
+func init() {
+	templatesCmd.AddCommand(templatesCheckCmd)
+
+	templatesCheckCmd.Flags().String("machine", "", "Machine or profile to check (defaults to the initialized machine)")
+}
+
+type templateReport struct {
+	path    string
+	missing []template.MissingKey
+	err     error
+}
+
+func runTemplatesCheck(cmd *cobra.Command, args []string) error {
+	machine, _ := cmd.Flags().GetString("machine")
+
+	env, err := loadEnvironment()
+	if err != nil {
+		return err
+	}
+
+	applier, err := apply.NewReadOnly(env.sysInfo, env.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize applier: %w", err)
+	}
+
+	machineName := env.machineName(machine)
+	resolved, err := applier.Resolve(machineName)
+	if err != nil {
+		return err
+	}
+
+	engine, err := applier.TemplateEngine(resolved, machineName)
+	if err != nil {
+		return err
+	}
+
+	progress.PrintHeader("Template check for " + machineName)
+	fmt.Println(styles.Mute("Missing key policy: " + string(engine.MissingKeyPolicy())))
+	fmt.Println()
+
+	alternates, err := applier.AlternateResolver(applier.AlternateContext(resolved))
+	if err != nil {
+		return err
+	}
+
+	var reports []templateReport
+	for _, name := range resolved.Configs {
+		root := applier.GetLoader().GetConfigPath(name)
+		ignore, err := config.LoadIgnore(env.configPath, root, alternates)
+		if err != nil {
+			return err
+		}
+
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, _ := filepath.Rel(root, path)
+			if info.IsDir() {
+				if _, ignored := ignore.Match(rel, true); ignored && rel != "." {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			base, _, _ := config.SplitAlternate(rel)
+			if _, ignored := ignore.Match(base, false); ignored {
+				return nil
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if !template.IsTemplate(content) {
+				return nil
+			}
+
+			missing, err := engine.Check(path, content)
+			reports = append(reports, templateReport{path: path, missing: missing, err: err})
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to walk config %s: %w", name, err)
+		}
+	}
+
+	problems, files := 0, 0
+	for _, r := range reports {
+		if r.err != nil || len(r.missing) > 0 {
+			files++
+		}
+
+		rel, err := filepath.Rel(env.configPath, r.path)
+		if err != nil {
+			rel = r.path
+		}
+
+		switch {
+		case r.err != nil:
+			problems++
+			fmt.Println(styles.ErrorIcon + " " + rel)
+			fmt.Println("    " + styles.ErrorStyle.Render(r.err.Error()))
+		case len(r.missing) > 0:
+			problems += len(r.missing)
+			fmt.Println(styles.ErrorIcon + " " + rel)
+			for _, m := range r.missing {
+				fmt.Printf("    %s  %s\n", styles.Mute(fmt.Sprintf("%4d", m.Line)), m.Key)
+				if m.Reason != "" {
+					fmt.Println("          " + styles.Mute(m.Reason))
+				}
+			}
+		case isVerbose():
+			fmt.Println(styles.SuccessIcon + " " + rel)
+		}
+	}
+
+	fmt.Println()
+	if problems > 0 {
+		return fmt.Errorf("%d template problem(s) in %d of %d template(s)", problems, files, len(reports))
+	}
+	fmt.Println(styles.Success(fmt.Sprintf("%d template(s) checked, all values set", len(reports))))
+	return nil
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/internal/state"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
+)
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove everything dotts placed and restore the originals",
+	Long: `Remove every link, rendered template, copy and decrypted file dotts
+placed, and put back what was there before.
+
+Originals come from the snapshot taken before the first apply, or from the
+oldest backup of a path the snapshot does not cover. Paths that did not
+exist are left absent, along with the directories created for them. Edited
+copies and files dotts did not write are backed up before they are removed.
+
+With --purge, dotts' state, manifest and cloned config repo are deleted as
+well. Backups are always kept.`,
+	Args: cobra.NoArgs,
+	RunE: runUninstall,
+}
+
+func init() {
+	uninstallCmd.Flags().Bool("purge", false, "Also delete state.json, the manifest and the cloned config repo")
+	uninstallCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+}
+
+func runUninstall(cmd *cobra.Command, args []string) error {
+	purge, _ := cmd.Flags().GetBool("purge")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	env, err := loadEnvironment()
+	if err != nil {
+		return err
+	}
+
+	applier, err := apply.New(env.sysInfo, env.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize applier: %w", err)
+	}
+
+	managed := applier.Manifest().Count()
+	snap, err := applier.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	progress.PrintHeader("Uninstall")
+	fmt.Println(styles.StatusLine(styles.InfoIcon, "Managed", pluralize(managed, "target")))
+	if snap != nil {
+		fmt.Println(styles.StatusLine(styles.InfoIcon, "Snapshot", fmt.Sprintf("%s, taken %s", pluralize(len(snap.Paths), "path"), timeAgo(snap.CreatedAt))))
+	} else {
+		fmt.Println(styles.StatusLine(styles.PendingIcon, "Snapshot", "none, originals come from backups"))
+	}
+	if purge {
+		for _, path := range purgePaths(env) {
+			fmt.Println(styles.StatusLine(styles.PendingIcon, "Delete", path))
+		}
+	}
+
+	if !yes {
+		fmt.Println()
+		ok, err := confirm("Uninstall dotts from this machine?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println(styles.Warn("Uninstall cancelled."))
+			return nil
+		}
+	}
+
+	result, err := applier.Uninstall()
+	if err != nil {
+		progress.PrintWarning("Nothing was changed, every file was put back")
+		return fmt.Errorf("uninstall failed: %w", err)
+	}
+
+	fmt.Println()
+	for _, path := range result.BackedUp {
+		fmt.Println(styles.Mute("Backed up " + path))
+	}
+	progress.PrintSuccess(fmt.Sprintf("Removed %s, restored %s",
+		pluralize(len(result.Removed), "file"), pluralize(len(result.Restored), "original")))
+
+	if !purge {
+		fmt.Println(styles.Mute("Run 'dotts apply' to link everything again"))
+		return nil
+	}
+
+	for _, path := range purgePaths(env) {
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", path, err)
+		}
+	}
+	progress.PrintSuccess("Deleted dotts state")
+	fmt.Println(styles.Mute("Backups are kept in " + env.paths.BackupsDir))
+	return nil
+}
+
+// purgePaths is what --purge deletes. A config repo outside dotts' data
+// directory belongs to the user and is left alone.
+func purgePaths(env *environment) []string {
+	paths := []string{env.paths.StateFile, filepath.Join(env.paths.DataDir, "manifest.json")}
+	if env.state.ConfigSource.Type != state.SourceTypeLocal && strings.HasPrefix(env.configPath, env.paths.DataDir+string(filepath.Separator)) {
+		paths = append(paths, env.configPath)
+	}
+	return paths
+}
@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
 )
 
 var updateCmd = &cobra.Command{
@@ -12,11 +19,11 @@ var updateCmd = &cobra.Command{
 	Long: `Pull the latest configuration and apply changes.
 
 This command will:
-  1. Pull the latest changes from your config repo
-  2. Show a diff of what will change
-  3. Update system packages
-  4. Apply new dotfile configurations
-  5. Run any post-update scripts`,
+  1. Fetch the latest changes from your config repo
+  2. Show what applying them would change, planned from the fetched commit
+  3. Once confirmed, fast-forward to exactly that commit and run the plan
+     you confirmed: pre-update scripts, packages, dotfiles, then
+     post-update scripts`,
 	RunE: runUpdate,
 }
 
@@ -25,14 +32,202 @@ func init() {
 	updateCmd.Flags().Bool("packages-only", false, "Only update packages")
 	updateCmd.Flags().Bool("dotfiles-only", false, "Only update dotfiles")
 	updateCmd.Flags().BoolP("yes", "y", false, "Skip confirmation prompts")
+	addConflictFlags(updateCmd)
 }
 
 func runUpdate(cmd *cobra.Command, args []string) error {
 	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	packagesOnly, _ := cmd.Flags().GetBool("packages-only")
+	dotfilesOnly, _ := cmd.Flags().GetBool("dotfiles-only")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	if packagesOnly && dotfilesOnly {
+		return errors.New("--packages-only and --dotfiles-only are mutually exclusive")
+	}
+
+	env, err := loadEnvironment()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	machineName := env.machineName("")
+
+	applier, err := apply.New(env.sysInfo, env.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize applier: %w", err)
+	}
+
+	progress.PrintHeader("Updating Configuration")
+	progress.PrintInfo("Fetching config source...")
+
+	if err := env.source.Fetch(); err != nil {
+		return err
+	}
+
+	current, _ := env.source.GetCurrentCommit()
+	from := env.state.ConfigSource.LastCommit
+	if from == "" {
+		from = current
+	}
+
+	to, err := env.source.GetUpstreamCommit()
+	if err != nil {
+		verboseLog("no upstream commit: %v", err)
+		to = current
+	}
+
+	var files []string
+	if from != "" && to != "" {
+		files, err = env.source.ChangedFiles(from, to)
+		if err != nil {
+			progress.PrintWarning(fmt.Sprintf("Could not compute changes: %v", err))
+		}
+	}
+
+	// plan from a checkout of the upstream commit: pulling first would change
+	// every symlinked dotfile before the update is confirmed
+	preview := applier
+	if to != "" && to != current {
+		checkout, err := env.source.Checkout(to)
+		if err != nil {
+			return err
+		}
+		defer env.source.RemoveCheckout(checkout)
+
+		preview, err = apply.NewPreview(env.sysInfo, env.configPath, checkout)
+		if err != nil {
+			return fmt.Errorf("failed to initialize applier: %w", err)
+		}
+	}
+
+	resolved, err := preview.Resolve(machineName)
+	if err != nil {
+		return err
+	}
+
+	changes := config.ClassifyChanges(files, resolved, machineName)
+	printChangePreview(from, to, changes)
+
+	opts := apply.ApplyOptions{
+		SkipPackages: dotfilesOnly,
+		SkipDotfiles: packagesOnly,
+		MachineName:  machineName,
+		Lifecycle:    apply.LifecycleUpdate,
+		OnModified:   modifiedResolver(),
+	}
+	conflictOptions(cmd, &opts)
+
+	plan, err := preview.Plan(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to plan update: %w", err)
+	}
+	progress.PrintHeader("Planned Changes")
+	apply.PrintPlan(plan, true)
+
 	if dryRun {
-		fmt.Println("Dry run mode - showing what would change...")
-	} else {
-		fmt.Println("Updating dotts configuration...")
+		return nil
+	}
+
+	if !yes {
+		fmt.Println()
+		ok, err := confirm("Apply these changes?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println(styles.Warn("Update cancelled."))
+			return nil
+		}
+	}
+
+	// move to exactly the previewed commit, so the confirmed plan is the one
+	// that runs even if the upstream has moved on since
+	if to != "" && to != current {
+		if err := env.source.FastForward(to); err != nil {
+			return err
+		}
+	}
+
+	if commit, err := env.source.GetCurrentCommit(); err == nil {
+		env.state.UpdateLastPull(commit)
+		if err := env.state.Save(); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+	}
+
+	result := applier.Execute(ctx, plan, opts)
+
+	if !printApplyResult(result, "Update complete!") {
+		return fmt.Errorf("update finished with %d error(s)", len(result.Errors))
 	}
+
+	env.state.UpdateLastApply()
+	if result.Generation > 0 {
+		env.state.Generation = result.Generation
+	}
+	if err := env.state.Save(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
 	return nil
 }
+
+func printChangePreview(from, to string, changes *config.ChangeSet) {
+	fmt.Println()
+	if from == to || from == "" {
+		fmt.Println(styles.Info(fmt.Sprintf("Config source is at %s", to)))
+	} else {
+		fmt.Println(styles.Info(fmt.Sprintf("Changes %s..%s", from, to)))
+	}
+
+	if !changes.IsRelevant() {
+		progress.PrintMuted("No changes affecting this machine")
+		if len(changes.Other) > 0 {
+			progress.PrintMuted(fmt.Sprintf("(%d file(s) changed for other machines)", len(changes.Other)))
+		}
+		return
+	}
+
+	for _, name := range changes.ConfigNames() {
+		files := changes.Configs[name]
+		fmt.Println(styles.StatusLine(styles.ActiveIcon, "config "+name, fmt.Sprintf("%d file(s)", len(files))))
+		for _, f := range files {
+			progress.PrintMuted("  " + f)
+		}
+	}
+
+	printChangeGroup("profiles", changes.Profiles)
+	printChangeGroup("packages", changes.Packages)
+	printChangeGroup("machine", changes.Machine)
+	printChangeGroup("scripts", changes.Scripts)
+	printChangeGroup("repo", changes.Repo)
+
+	if len(changes.Other) > 0 {
+		progress.PrintMuted(fmt.Sprintf("(%d file(s) changed for other machines)", len(changes.Other)))
+	}
+}
+
+func printChangeGroup(label string, files []string) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Println(styles.StatusLine(styles.ActiveIcon, label, strings.Join(files, ", ")))
+}
+
+// printApplyResult prints the outcome of an apply and reports whether it succeeded.
+func printApplyResult(result *apply.ApplyResult, successMessage string) bool {
+	fmt.Println()
+	if result.Success() {
+		progress.PrintSuccess(successMessage)
+		return true
+	}
+
+	progress.PrintWarning(fmt.Sprintf("Completed with %d error(s)", len(result.Errors)))
+	for _, e := range result.Errors {
+		progress.PrintError(e.Error())
+	}
+	return false
+}
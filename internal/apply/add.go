@@ -0,0 +1,271 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/internal/personal"
+)
+
+// AddOptions describes a file to start managing
+type AddOptions struct {
+	MachineName string
+	Config      string // config under configs/ the file moves into
+	Template    bool   // replace personal values with <<dotts:...>> placeholders
+	Alternate   string // alternate suffix for the source, e.g. "os.linux"
+}
+
+// AddResult describes what Add changed
+type AddResult struct {
+	Source       string             // where the file now lives in the config repo
+	Placeholders []string           // personal values turned into placeholders
+	Linked       []linker.LinkEntry // targets now managed
+	Profile      string             // most specific profile of the machine
+	InProfile    bool               // whether that profile already selects the config
+}
+
+// Add moves an existing file or directory into a config and links it back in
+// its place. The original is backed up like any other replaced target. If
+// linking fails, the target is restored and the source removed from the repo.
+func (a *Applier) Add(ctx context.Context, path string, opts AddOptions) (*AddResult, error) {
+	resolved, err := a.Resolve(opts.MachineName)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if managed := a.managedAt(target); managed == target {
+		return nil, fmt.Errorf("%s is already managed by dotts", target)
+	} else if managed != "" {
+		return nil, fmt.Errorf("%s is inside %s, which dotts already links", target, managed)
+	}
+	info, err := os.Lstat(target)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil, fmt.Errorf("%s is a symlink, add the file it points to instead", target)
+	}
+	if info.IsDir() && (opts.Template || opts.Alternate != "") {
+		return nil, fmt.Errorf("%s is a directory: --template and --alternate apply to files only", target)
+	}
+
+	configDir := filepath.Join(a.configPath, "configs", opts.Config)
+	meta, err := config.LoadConfigMeta(configDir)
+	if err != nil {
+		return nil, err
+	}
+	targetRoot, err := config.TargetRoot(meta)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(targetRoot, target)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%s is outside %s, where config %s is linked", target, targetRoot, opts.Config)
+	}
+
+	alternates, err := a.AlternateResolver(a.AlternateContext(resolved))
+	if err != nil {
+		return nil, err
+	}
+
+	source := filepath.Join(configDir, rel)
+	if opts.Alternate != "" {
+		for _, raw := range strings.Split(opts.Alternate, ",") {
+			p, err := config.ParsePredicate(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid alternate %q: %w", opts.Alternate, err)
+			}
+			if !alternates.Matches(p) {
+				return nil, fmt.Errorf("alternate %q does not match this machine, so the file would not be linked back", raw)
+			}
+		}
+		source += config.AlternateSeparator + opts.Alternate
+	}
+	if _, err := os.Lstat(source); err == nil {
+		return nil, fmt.Errorf("%s already exists in the config repo", source)
+	}
+
+	result := &AddResult{
+		Source:    source,
+		Profile:   mostSpecificProfile(resolved),
+		InProfile: slices.Contains(resolved.Configs, opts.Config),
+	}
+
+	created := linker.TopmostMissing(filepath.Dir(source))
+	if err := a.copyIntoRepo(target, source, info, opts.Template, result); err != nil {
+		removeAdded(source, created)
+		return nil, err
+	}
+
+	linked, err := a.linkAdded(ctx, resolved, alternates, opts, target, source)
+	if err != nil {
+		removeAdded(source, created)
+		return nil, err
+	}
+	result.Linked = linked
+	return result, nil
+}
+
+// managedAt returns the managed target at or above path, if any
+func (a *Applier) managedAt(path string) string {
+	for _, entry := range a.linker.Manifest().Entries() {
+		if entry.Target == path || (entry.IsDir && linker.Within(path, entry.Target)) {
+			return entry.Target
+		}
+	}
+	return ""
+}
+
+// copyIntoRepo copies target to source, templatized when requested
+func (a *Applier) copyIntoRepo(target, source string, info os.FileInfo, templatize bool, result *AddResult) error {
+	if err := os.MkdirAll(filepath.Dir(source), 0755); err != nil {
+		return err
+	}
+	if !templatize {
+		return linker.CopyPath(target, source)
+	}
+
+	content, err := os.ReadFile(target)
+	if err != nil {
+		return err
+	}
+	pc, err := personal.Load()
+	if err != nil {
+		return err
+	}
+	content, result.Placeholders = templatizeContent(content, pc.ToMap())
+	return os.WriteFile(source, content, info.Mode().Perm())
+}
+
+// linkAdded plans the config and executes the actions for target, which
+// replace the original with a link to source
+func (a *Applier) linkAdded(ctx context.Context, resolved *config.ResolvedConfig, alternates *config.AlternateResolver, opts AddOptions, target, source string) ([]linker.LinkEntry, error) {
+	linkOpts, err := a.planLinkOptions(resolved, ApplyOptions{MachineName: opts.MachineName}, alternates)
+	if err != nil {
+		return nil, err
+	}
+	// adding a file overrides an earlier choice to keep it
+	linkOpts.Reask = true
+
+	planned, err := a.linker.PlanConfig(opts.Config, linkOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []linker.LinkAction
+	for _, action := range planned {
+		if action.Action == linker.ActionIgnored && linker.Within(target, action.Target) {
+			// an ignore rule or the config's platform filter covers the file
+			return nil, fmt.Errorf("%s would not be linked: %s", target, action.Reason)
+		}
+		if !linker.Within(action.Target, target) {
+			continue
+		}
+		switch action.Action {
+		case linker.ActionIgnored, linker.ActionSkip:
+			return nil, fmt.Errorf("%s would not be linked: %s", action.Target, action.Reason)
+		case linker.ActionError:
+			return nil, fmt.Errorf("%s: %s", action.Target, action.Reason)
+		}
+		if action.Target == target && action.Source != source {
+			return nil, fmt.Errorf("%s would be linked from %s, which wins over the added file", target, action.Source)
+		}
+		actions = append(actions, action)
+	}
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("config %s does not link anything at %s", opts.Config, target)
+	}
+
+	linkOpts.Context = ctx
+	if err := a.linker.Begin(); err != nil {
+		return nil, err
+	}
+	linkResult := a.linker.Execute(actions, linkOpts)
+	if err := ctx.Err(); err != nil {
+		linkResult.Errors = append(linkResult.Errors, linker.LinkError{Target: target, Err: err})
+	}
+	if len(linkResult.Errors) == 0 {
+		if err := a.linker.Commit(); err != nil {
+			linkResult.Errors = append(linkResult.Errors, linker.LinkError{Target: target, Err: err})
+		}
+	}
+	if len(linkResult.Errors) > 0 {
+		err := linkResult.Errors[0]
+		if rbErr := a.linker.Rollback(); rbErr != nil {
+			return nil, fmt.Errorf("%w (and failed to roll back: %v)", err, rbErr)
+		}
+		return nil, err
+	}
+
+	var linked []linker.LinkEntry
+	for _, action := range actions {
+		if entry, ok := a.linker.Manifest().Get(action.Target); ok {
+			linked = append(linked, entry)
+		}
+	}
+	if _, ok := a.linker.Choices().Get(target); ok {
+		if err := a.linker.Choices().Forget(target); err != nil {
+			return linked, err
+		}
+	}
+	return linked, nil
+}
+
+// AddToProfile lists config in profile so every machine using it links it
+func (a *Applier) AddToProfile(profile, configName string) error {
+	return a.loader.AddConfigToProfile(profile, configName)
+}
+
+// templatizeContent replaces personal values with their placeholders, longest
+// first so an email is not split by a shorter name inside it. Values under
+// three characters are left alone; they match too much by accident. It
+// returns the keys that were replaced.
+func templatizeContent(content []byte, values map[string]string) ([]byte, []string) {
+	keys := make([]string, 0, len(values))
+	for key, value := range values {
+		if len(value) >= 3 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(values[keys[i]]) != len(values[keys[j]]) {
+			return len(values[keys[i]]) > len(values[keys[j]])
+		}
+		return keys[i] < keys[j]
+	})
+
+	pairs := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, values[key], "<<dotts:"+key+">>")
+	}
+	text := strings.NewReplacer(pairs...).Replace(string(content))
+
+	var replaced []string
+	for _, key := range keys {
+		if strings.Contains(text, "<<dotts:"+key+">>") {
+			replaced = append(replaced, key)
+		}
+	}
+	return []byte(text), replaced
+}
+
+// removeAdded deletes a source added to the repo, and the directories that
+// were created for it
+func removeAdded(source, created string) {
+	if created != "" {
+		os.RemoveAll(created)
+		return
+	}
+	os.RemoveAll(source)
+}
@@ -0,0 +1,58 @@
+package apply
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplatizeContent(t *testing.T) {
+	values := map[string]string{
+		"name":  "Arthur",
+		"email": "arthur@example.com",
+		"user":  "ab",
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		want     string
+		wantKeys []string
+	}{
+		{
+			name:     "longest value first",
+			content:  "name = Arthur\nemail = arthur@example.com\n",
+			want:     "name = <<dotts:name>>\nemail = <<dotts:email>>\n",
+			wantKeys: []string{"email", "name"},
+		},
+		{
+			name:     "short values left alone",
+			content:  "ab cd\n",
+			want:     "ab cd\n",
+			wantKeys: nil,
+		},
+		{
+			name:     "no personal values",
+			content:  "set -g mouse on\n",
+			want:     "set -g mouse on\n",
+			wantKeys: nil,
+		},
+		{
+			name:     "every occurrence",
+			content:  "Arthur Arthur",
+			want:     "<<dotts:name>> <<dotts:name>>",
+			wantKeys: []string{"name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, keys := templatizeContent([]byte(tt.content), values)
+			if string(got) != tt.want {
+				t.Errorf("templatizeContent() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("templatizeContent() keys = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
@@ -3,13 +3,19 @@ package apply
 import (
 	"context"
 	"fmt"
+	"os"
+	"slices"
 
 	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/crypt"
 	"github.com/arthur404dev/dotts/internal/installer"
 	"github.com/arthur404dev/dotts/internal/linker"
 	"github.com/arthur404dev/dotts/internal/personal"
+	"github.com/arthur404dev/dotts/internal/secret"
 	"github.com/arthur404dev/dotts/internal/state"
 	"github.com/arthur404dev/dotts/internal/system"
+	"github.com/arthur404dev/dotts/internal/template"
+	"github.com/arthur404dev/dotts/pkg/schema"
 	"github.com/arthur404dev/dotts/pkg/vetru/progress"
 	"github.com/arthur404dev/dotts/pkg/vetru/styles"
 )
@@ -22,18 +28,31 @@ type Applier struct {
 	resolver   *config.Resolver
 	registry   *installer.Registry
 	linker     *linker.SymlinkLinker
+	secrets    *secret.Registry // shared by every render in this run
 }
 
 type ApplyOptions struct {
-	DryRun       bool
-	SkipPackages bool
-	SkipDotfiles bool
-	MachineName  string
+	DryRun         bool
+	SkipPackages   bool
+	SkipDotfiles   bool
+	MachineName    string
+	OnlyConfigs    []string // link only these configs (empty means all)
+	ExcludeConfigs []string // never link these configs
+	OnlyManagers   []string // install only through these package managers (empty means all)
+	NoPrune        bool     // keep links whose source is no longer planned
+	Lifecycle      Lifecycle
+	OnModified     linker.ConflictResolver // decides about locally edited templates; nil leaves them alone
+	OnExisting     linker.ExistingResolver // decides about existing files at targets; nil backs them up
+	Force          bool                    // replace existing files without backup and overwrite local edits
+	Reask          bool                    // ask OnExisting again about targets with a remembered decision
 }
 
 type ApplyResult struct {
+	Plan           *Plan
 	PackageResults []installer.InstallResult
 	LinkResult     *linker.LinkResult
+	RolledBack     bool // link changes failed or were interrupted and were undone
+	Generation     int  // generation recorded for this apply; 0 when nothing changed
 	Errors         []error
 }
 
@@ -44,12 +63,42 @@ func (r *ApplyResult) Success() bool {
 func New(sysInfo *system.SystemInfo, configPath string) (*Applier, error) {
 	paths := state.GetPaths()
 
-	loader := config.NewLoader(configPath)
-
 	lnk, err := linker.NewSymlinkLinker(paths.DataDir, configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize linker: %w", err)
 	}
+	if lnk.Recovered() {
+		warn("Rolled back link changes left by an interrupted apply")
+	}
+
+	return newApplier(sysInfo, paths, configPath, lnk), nil
+}
+
+// NewReadOnly returns an applier for commands that only inspect or plan.
+// Unlike New it never rolls back an interrupted apply; that is left to
+// 'dotts apply' and 'dotts doctor'.
+func NewReadOnly(sysInfo *system.SystemInfo, configPath string) (*Applier, error) {
+	paths := state.GetPaths()
+
+	lnk, err := linker.OpenSymlinkLinker(paths.DataDir, configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize linker: %w", err)
+	}
+	if lnk.Interrupted() {
+		warn("An interrupted apply left link changes behind, run 'dotts apply' or 'dotts doctor' to roll them back")
+	}
+
+	return newApplier(sysInfo, paths, configPath, lnk), nil
+}
+
+// warn prints a warning to stderr, so it never mixes with a JSON plan or a
+// decrypted file that the command writes to stdout
+func warn(message string) {
+	fmt.Fprintln(os.Stderr, styles.Warn("! "+message))
+}
+
+func newApplier(sysInfo *system.SystemInfo, paths *state.Paths, configPath string, lnk *linker.SymlinkLinker) *Applier {
+	loader := config.NewLoader(configPath)
 
 	return &Applier{
 		sysInfo:    sysInfo,
@@ -59,39 +108,70 @@ func New(sysInfo *system.SystemInfo, configPath string) (*Applier, error) {
 		resolver:   config.NewResolver(loader),
 		registry:   installer.NewRegistry(sysInfo),
 		linker:     lnk,
-	}, nil
+	}
 }
 
-func (a *Applier) Apply(ctx context.Context, opts ApplyOptions) (*ApplyResult, error) {
-	result := &ApplyResult{}
+// NewPreview returns an applier for the config repo at configPath that plans
+// from checkout, a checkout of the repo at another commit, to show what
+// applying that commit would do. Its plans are for display only.
+func NewPreview(sysInfo *system.SystemInfo, configPath, checkout string) (*Applier, error) {
+	a, err := NewReadOnly(sysInfo, configPath)
+	if err != nil {
+		return nil, err
+	}
 
-	resolved, err := a.resolver.ResolveMachine(opts.MachineName)
+	a.configPath = checkout
+	a.loader = config.NewLoader(checkout)
+	a.resolver = config.NewResolver(a.loader)
+	a.linker.ReadSourcesFrom(checkout)
+	return a, nil
+}
+
+// Apply plans the requested changes and executes them. With DryRun the plan
+// is only printed and returned in the result.
+func (a *Applier) Apply(ctx context.Context, opts ApplyOptions) (*ApplyResult, error) {
+	plan, err := a.Plan(ctx, opts)
 	if err != nil {
-		resolved, err = a.resolver.ResolveProfile(opts.MachineName)
-		if err != nil {
-			return nil, fmt.Errorf("failed to resolve configuration: %w", err)
-		}
+		return nil, err
+	}
+
+	if opts.DryRun {
+		progress.PrintHeader("Planned Changes")
+		PrintPlan(plan, true)
+		return &ApplyResult{Plan: plan}, nil
 	}
 
+	return a.Execute(ctx, plan, opts), nil
+}
+
+// Execute applies exactly the changes in plan
+func (a *Applier) Execute(ctx context.Context, plan *Plan, opts ApplyOptions) *ApplyResult {
+	result := &ApplyResult{Plan: plan}
+
 	progress.PrintHeader("Applying Configuration")
 
-	if !opts.SkipPackages && resolved.Packages != nil {
+	if err := a.snapshotTargets(plan); err != nil {
+		result.Errors = append(result.Errors, fmt.Errorf("failed to snapshot targets before the first apply: %w", err))
+		return result
+	}
+
+	if err := a.runScripts(ctx, plan.PreScripts); err != nil {
+		result.Errors = append(result.Errors, err)
+		return result
+	}
+
+	if len(plan.Packages) > 0 {
 		fmt.Println()
 		fmt.Println(styles.Info("Installing packages..."))
 
-		plan := a.registry.CreatePlan(resolved.Packages)
-
-		if !plan.IsEmpty() {
-			if opts.DryRun {
-				a.printPlan(plan)
-			} else {
-				results := a.installPackages(ctx, plan)
-				result.PackageResults = results
+		installPlan := plan.installPlan()
+		if !installPlan.IsEmpty() {
+			results := a.installPackages(ctx, installPlan)
+			result.PackageResults = results
 
-				for _, r := range results {
-					if !r.Success() {
-						result.Errors = append(result.Errors, r.Error)
-					}
+			for _, r := range results {
+				if !r.Success() {
+					result.Errors = append(result.Errors, r.Error)
 				}
 			}
 		} else {
@@ -99,44 +179,369 @@ func (a *Applier) Apply(ctx context.Context, opts ApplyOptions) (*ApplyResult, e
 		}
 	}
 
-	if !opts.SkipDotfiles && len(resolved.Configs) > 0 {
+	if len(plan.Links) > 0 {
 		fmt.Println()
 		fmt.Println(styles.Info("Linking dotfiles..."))
 
-		linkOpts := linker.DefaultLinkOptions()
-		linkOpts.DryRun = opts.DryRun
-		linkOpts.TemplateValues = a.loadTemplateValues()
+		result.LinkResult = &linker.LinkResult{}
+		linkOpts := a.linkOptions(opts)
+		linkOpts.Context = ctx
 
-		for _, configName := range resolved.Configs {
-			linkResult, err := a.linker.LinkConfig(configName, linkOpts)
-			if err != nil {
-				result.Errors = append(result.Errors, err)
-				progress.PrintError(fmt.Sprintf("%s: %v", configName, err))
-				continue
+		if err := a.linker.Begin(); err != nil {
+			result.Errors = append(result.Errors, err)
+			return result
+		}
+
+		var changed []string
+		for _, group := range groupByConfig(plan.Links) {
+			if ctx.Err() != nil {
+				break
 			}
+			linkResult := a.linker.Execute(group.actions, linkOpts)
 
-			if result.LinkResult == nil {
-				result.LinkResult = linkResult
-			} else {
-				result.LinkResult.Linked = append(result.LinkResult.Linked, linkResult.Linked...)
-				result.LinkResult.Skipped = append(result.LinkResult.Skipped, linkResult.Skipped...)
-				result.LinkResult.Backed = append(result.LinkResult.Backed, linkResult.Backed...)
-				result.LinkResult.Errors = append(result.LinkResult.Errors, linkResult.Errors...)
+			result.LinkResult.Linked = append(result.LinkResult.Linked, linkResult.Linked...)
+			result.LinkResult.Skipped = append(result.LinkResult.Skipped, linkResult.Skipped...)
+			result.LinkResult.Backed = append(result.LinkResult.Backed, linkResult.Backed...)
+			result.LinkResult.Pruned = append(result.LinkResult.Pruned, linkResult.Pruned...)
+			result.LinkResult.Restored = append(result.LinkResult.Restored, linkResult.Restored...)
+			result.LinkResult.Errors = append(result.LinkResult.Errors, linkResult.Errors...)
+
+			for _, e := range linkResult.Errors {
+				result.Errors = append(result.Errors, e)
 			}
 
 			if len(linkResult.Linked) > 0 {
-				progress.PrintSuccess(fmt.Sprintf("%s: %d files linked", configName, len(linkResult.Linked)))
-			} else if len(linkResult.Skipped) > 0 {
-				fmt.Println(styles.Mute(fmt.Sprintf("  %s: already linked", configName)))
+				progress.PrintSuccess(fmt.Sprintf("%s: %d files linked", group.config, len(linkResult.Linked)))
+				changed = append(changed, group.config)
+			} else if len(linkResult.Errors) == 0 && len(linkResult.Skipped) > 0 {
+				fmt.Println(styles.Mute(fmt.Sprintf("  %s: already linked", group.config)))
+			}
+			if len(linkResult.Pruned) > 0 {
+				progress.PrintSuccess(fmt.Sprintf("%s: %d stale link(s) pruned", group.config, len(linkResult.Pruned)))
 			}
 		}
 
-		if err := a.linker.Save(); err != nil {
+		if len(result.LinkResult.Errors) > 0 || ctx.Err() != nil {
+			a.undoLinks(ctx, result)
+			return result
+		}
+		if err := a.linker.Commit(); err != nil {
 			result.Errors = append(result.Errors, err)
+			a.undoLinks(ctx, result)
+			return result
+		}
+		a.pruneBackups()
+
+		for _, name := range changed {
+			if err := a.runHooks(ctx, name, plan.Hooks); err != nil {
+				result.Errors = append(result.Errors, err)
+			}
+		}
+	}
+
+	if result.Success() {
+		if err := a.runScripts(ctx, plan.PostScripts); err != nil {
+			result.Errors = append(result.Errors, err)
+		}
+	}
+
+	if result.Success() && !plan.IsEmpty() {
+		gen, err := a.recordGeneration(plan, result, 0)
+		if err != nil {
+			progress.PrintWarning("Failed to record generation: " + err.Error())
+		} else {
+			result.Generation = gen.Number
+		}
+	}
+
+	return result
+}
+
+// undoLinks rolls back the link changes of a failed or interrupted run
+func (a *Applier) undoLinks(ctx context.Context, result *ApplyResult) {
+	if ctx.Err() != nil {
+		result.Errors = append(result.Errors, fmt.Errorf("apply interrupted: %w", ctx.Err()))
+	}
+
+	if err := a.linker.Rollback(); err != nil {
+		result.Errors = append(result.Errors, fmt.Errorf("failed to roll back link changes, run dotts again to retry: %w", err))
+		return
+	}
+	result.RolledBack = true
+	progress.PrintWarning("Link changes were rolled back")
+}
+
+type configActions struct {
+	config  string
+	actions []linker.LinkAction
+}
+
+// groupByConfig splits link actions by config, preserving plan order
+func groupByConfig(actions []linker.LinkAction) []configActions {
+	var groups []configActions
+	for _, action := range actions {
+		if len(groups) == 0 || groups[len(groups)-1].config != action.Config {
+			groups = append(groups, configActions{config: action.Config})
+		}
+		last := &groups[len(groups)-1]
+		last.actions = append(last.actions, action)
+	}
+	return groups
+}
+
+// Conflicts returns the links of plan whose existing targets executing it
+// with opts would ask OnExisting about
+func (a *Applier) Conflicts(plan *Plan, opts ApplyOptions) []linker.LinkAction {
+	if opts.Force {
+		return nil
+	}
+	var conflicts []linker.LinkAction
+	for _, action := range plan.Links {
+		if a.linker.Asks(action, opts.Reask) {
+			conflicts = append(conflicts, action)
+		}
+	}
+	return conflicts
+}
+
+func (a *Applier) linkOptions(opts ApplyOptions) linker.LinkOptions {
+	linkOpts := linker.DefaultLinkOptions()
+	linkOpts.OnModified = opts.OnModified
+	linkOpts.OnExisting = opts.OnExisting
+	linkOpts.Reask = opts.Reask
+	if opts.Force {
+		linkOpts.Force = true
+		linkOpts.Backup = false
+		linkOpts.OnExisting = nil
+	}
+	return linkOpts
+}
+
+// machineFacts merges the resolved features and settings with the ones
+// chosen for this machine during init; state wins on conflicts.
+func (a *Applier) machineFacts(resolved *config.ResolvedConfig) ([]string, map[string]any) {
+	features := append([]string{}, resolved.Features...)
+	settings := make(map[string]any, len(resolved.Settings))
+	for k, v := range resolved.Settings {
+		settings[k] = v
+	}
+
+	if st, err := state.Load(); err == nil {
+		for _, f := range st.Features {
+			if !slices.Contains(features, f) {
+				features = append(features, f)
+			}
+		}
+		for k, v := range st.Settings {
+			settings[k] = v
+		}
+	}
+
+	return features, settings
+}
+
+// mostSpecificProfile is the last profile in the inheritance chain
+func mostSpecificProfile(resolved *config.ResolvedConfig) string {
+	if len(resolved.Profiles) == 0 {
+		return ""
+	}
+	return resolved.Profiles[len(resolved.Profiles)-1]
+}
+
+// TemplateEngine builds the template engine for a resolved machine, using the
+// missing key policy from the repo's config.yaml
+func (a *Applier) TemplateEngine(resolved *config.ResolvedConfig, machineName string) (*template.Engine, error) {
+	features, settings := a.machineFacts(resolved)
+
+	ctx := &template.Context{
+		Settings: settings,
+		Features: features,
+		System: template.System{
+			OS:       string(a.sysInfo.OS),
+			Distro:   string(a.sysInfo.Distro),
+			Arch:     string(a.sysInfo.Arch),
+			Hostname: a.sysInfo.Hostname,
+			User:     a.sysInfo.Username,
+			Home:     a.sysInfo.HomeDir,
+		},
+		Machine: template.Machine{
+			Name:     machineName,
+			Profile:  mostSpecificProfile(resolved),
+			Profiles: resolved.Profiles,
+		},
+	}
+
+	if personalConfig, err := personal.Load(); err == nil {
+		ctx.Personal = personalConfig.ToData()
+	}
+
+	engine := template.NewEngine(ctx)
+
+	repoConfig, err := a.loader.LoadRepoConfig()
+	if err != nil {
+		return nil, err
+	}
+	policy, err := template.ParseMissingKeyPolicy(repoConfig.Templates.MissingKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid templates in config.yaml: %w", err)
+	}
+	engine.SetMissingKeyPolicy(policy)
+	engine.SetSecrets(a.secretRegistry(repoConfig.Templates.Secrets))
+
+	return engine, nil
+}
+
+// BackupPolicy returns the backup retention policy from the repo's config.yaml
+func (a *Applier) BackupPolicy() (linker.RetentionPolicy, error) {
+	repoConfig, err := a.loader.LoadRepoConfig()
+	if err != nil {
+		return linker.RetentionPolicy{}, err
+	}
+
+	policy := linker.RetentionPolicy{KeepLast: repoConfig.Backups.KeepLast}
+	if repoConfig.Backups.KeepLast < 0 {
+		return policy, fmt.Errorf("invalid backups.keep_last in config.yaml: %d", repoConfig.Backups.KeepLast)
+	}
+	if repoConfig.Backups.MaxAge != "" {
+		if policy.MaxAge, err = linker.ParseAge(repoConfig.Backups.MaxAge); err != nil {
+			return policy, fmt.Errorf("invalid backups.max_age in config.yaml: %w", err)
 		}
 	}
+	return policy, nil
+}
+
+// pruneBackups applies the retention policy once an apply has committed.
+// Failing to prune never fails the apply.
+func (a *Applier) pruneBackups() {
+	policy, err := a.BackupPolicy()
+	if err != nil {
+		progress.PrintWarning(err.Error())
+		return
+	}
+
+	pruned, err := a.linker.PruneBackups(policy, false)
+	if err != nil {
+		progress.PrintWarning("Failed to prune backups: " + err.Error())
+		return
+	}
+	if len(pruned) > 0 {
+		fmt.Println(styles.Mute(fmt.Sprintf("  %d old backup(s) removed", len(pruned))))
+	}
+}
+
+// Crypt returns the age helper for encrypted files, configured from the
+// repo's config.yaml
+func (a *Applier) Crypt() (*crypt.Age, error) {
+	repoConfig, err := a.loader.LoadRepoConfig()
+	if err != nil {
+		return nil, err
+	}
+	return crypt.New(repoConfig.Encryption.Identity, repoConfig.Encryption.Recipients), nil
+}
+
+// secretRegistry returns the run's secret registry, built on first use so
+// every template shares one cache
+func (a *Applier) secretRegistry(cfg schema.Secrets) *secret.Registry {
+	if a.secrets != nil {
+		return a.secrets
+	}
 
-	return result, nil
+	registry := secret.NewRegistry()
+	if cfg.StoreDir != "" {
+		registry.Register(secret.NewFileProvider(cfg.StoreDir))
+	}
+	for name, argv := range cfg.Commands {
+		registry.Register(secret.NewCommandProvider(name, argv))
+	}
+
+	a.secrets = registry
+	return registry
+}
+
+// AlternateContext describes this machine for matching ##-suffixed files: the
+// detected system, the most specific profile in the machine's inheritance
+// chain and the features and settings chosen for it.
+func (a *Applier) AlternateContext(resolved *config.ResolvedConfig) config.AlternateContext {
+	features, settings := a.machineFacts(resolved)
+
+	ctx := config.AlternateContext{
+		OS:       string(a.sysInfo.OS),
+		Distro:   string(a.sysInfo.Distro),
+		Arch:     string(a.sysInfo.Arch),
+		Profile:  mostSpecificProfile(resolved),
+		Hostname: a.sysInfo.Hostname,
+		User:     a.sysInfo.Username,
+		Features: features,
+		Settings: settings,
+	}
+
+	for _, env := range a.sysInfo.Environments {
+		ctx.Environments = append(ctx.Environments, string(env))
+	}
+
+	return ctx
+}
+
+// AlternateResolver builds a resolver for ctx using the repo's score overrides
+func (a *Applier) AlternateResolver(ctx config.AlternateContext) (*config.AlternateResolver, error) {
+	resolver := config.NewAlternateResolver(ctx)
+
+	repoConfig, err := a.loader.LoadRepoConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := resolver.SetScores(repoConfig.Alternates.Scores); err != nil {
+		return nil, fmt.Errorf("invalid alternates in config.yaml: %w", err)
+	}
+
+	return resolver, nil
+}
+
+// pruneScope reports which configs' stale links an apply may prune: only the
+// selected ones when configs were picked, otherwise all but the excluded
+func pruneScope(opts ApplyOptions) func(string) bool {
+	return func(name string) bool {
+		if len(opts.OnlyConfigs) > 0 {
+			return slices.Contains(opts.OnlyConfigs, name)
+		}
+		return !slices.Contains(opts.ExcludeConfigs, name)
+	}
+}
+
+// filterConfigs narrows the resolved configs to the requested selection,
+// rejecting names that the machine does not use.
+func filterConfigs(configs, only, exclude []string) ([]string, error) {
+	known := make(map[string]bool, len(configs))
+	for _, c := range configs {
+		known[c] = true
+	}
+
+	for _, name := range append(append([]string{}, only...), exclude...) {
+		if !known[name] {
+			return nil, fmt.Errorf("config %q is not part of this machine's configuration", name)
+		}
+	}
+
+	selected := make(map[string]bool)
+	for _, c := range only {
+		selected[c] = true
+	}
+	excluded := make(map[string]bool)
+	for _, c := range exclude {
+		excluded[c] = true
+	}
+
+	var filtered []string
+	for _, c := range configs {
+		if len(only) > 0 && !selected[c] {
+			continue
+		}
+		if excluded[c] {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered, nil
 }
 
 func (a *Applier) installPackages(ctx context.Context, plan *installer.InstallPlan) []installer.InstallResult {
@@ -188,27 +593,13 @@ func (a *Applier) installPackages(ctx context.Context, plan *installer.InstallPl
 	return results
 }
 
-func (a *Applier) printPlan(plan *installer.InstallPlan) {
-	fmt.Println(styles.Mute("  [dry-run] Would install:"))
-
-	if len(plan.Nix) > 0 {
-		fmt.Printf("    nix: %v\n", plan.Nix)
-	}
-	if len(plan.Pacman) > 0 {
-		fmt.Printf("    pacman: %v\n", plan.Pacman)
-	}
-	if len(plan.AUR) > 0 {
-		fmt.Printf("    aur: %v\n", plan.AUR)
-	}
-	if len(plan.Apt) > 0 {
-		fmt.Printf("    apt: %v\n", plan.Apt)
-	}
-	if len(plan.Brew) > 0 {
-		fmt.Printf("    brew: %v\n", plan.Brew)
-	}
-	if len(plan.Cask) > 0 {
-		fmt.Printf("    cask: %v\n", plan.Cask)
+// Resolve resolves the machine (or profile) configuration the applier would apply
+func (a *Applier) Resolve(machineName string) (*config.ResolvedConfig, error) {
+	resolved, err := a.resolver.Resolve(machineName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve configuration: %w", err)
 	}
+	return resolved, nil
 }
 
 func (a *Applier) GetLoader() *config.Loader {
@@ -218,11 +609,3 @@ func (a *Applier) GetLoader() *config.Loader {
 func (a *Applier) GetLinker() *linker.SymlinkLinker {
 	return a.linker
 }
-
-func (a *Applier) loadTemplateValues() map[string]string {
-	personalConfig, err := personal.Load()
-	if err != nil {
-		return nil
-	}
-	return personalConfig.ToMap()
-}
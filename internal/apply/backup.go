@@ -0,0 +1,36 @@
+package apply
+
+import (
+	"fmt"
+
+	"github.com/arthur404dev/dotts/internal/linker"
+)
+
+// Backups returns the backups of replaced files
+func (a *Applier) Backups() *linker.BackupManager {
+	return a.linker.Backups()
+}
+
+// PruneBackups removes the backup versions policy does not keep. The
+// originals, and every backup of a path dotts still manages, stay.
+func (a *Applier) PruneBackups(policy linker.RetentionPolicy, dryRun bool) ([]linker.BackupEntry, error) {
+	return a.linker.PruneBackups(policy, dryRun)
+}
+
+// RestoreBackup puts a backup version back at path and saves the manifest.
+// A managed link there is dropped; anything else is backed up first, and its
+// backup path returned. On failure every change is undone.
+func (a *Applier) RestoreBackup(path string, version int) (string, error) {
+	if err := a.linker.Begin(); err != nil {
+		return "", err
+	}
+
+	backupPath, err := a.linker.RestoreBackup(path, version)
+	if err != nil {
+		if rbErr := a.linker.Rollback(); rbErr != nil {
+			return "", fmt.Errorf("%w (and failed to roll back: %v)", err, rbErr)
+		}
+		return "", err
+	}
+	return backupPath, a.linker.Commit()
+}
@@ -0,0 +1,233 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/history"
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/internal/template"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
+)
+
+// History returns the generations recorded by earlier applies
+func (a *Applier) History() *history.Store {
+	return history.NewStore(a.paths.DataDir)
+}
+
+// HistoryPolicy returns the generation retention policy from the repo's
+// config.yaml
+func (a *Applier) HistoryPolicy() (linker.RetentionPolicy, error) {
+	repoConfig, err := a.loader.LoadRepoConfig()
+	if err != nil {
+		return linker.RetentionPolicy{}, err
+	}
+
+	policy := linker.RetentionPolicy{KeepLast: repoConfig.History.KeepLast}
+	if repoConfig.History.KeepLast < 0 {
+		return policy, fmt.Errorf("invalid history.keep_last in config.yaml: %d", repoConfig.History.KeepLast)
+	}
+	if repoConfig.History.MaxAge != "" {
+		if policy.MaxAge, err = linker.ParseAge(repoConfig.History.MaxAge); err != nil {
+			return policy, fmt.Errorf("invalid history.max_age in config.yaml: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// pruneHistory applies the retention policy once a generation is recorded
+// and drops stored contents no generation refers to any more. Failing to
+// prune never fails the apply.
+func (a *Applier) pruneHistory() {
+	policy, err := a.HistoryPolicy()
+	if err != nil {
+		progress.PrintWarning(err.Error())
+		return
+	}
+
+	pruned, err := a.History().Prune(policy, false)
+	if err != nil {
+		progress.PrintWarning("Failed to prune generations: " + err.Error())
+		return
+	}
+	if len(pruned) > 0 {
+		fmt.Println(styles.Mute(fmt.Sprintf("  %d old generation(s) removed", len(pruned))))
+	}
+}
+
+// recordGeneration snapshots the manifest, the written contents of copies
+// and templates, the resolved machine, the repo commit and the packages the
+// plan found installed. Packages are carried over from the previous
+// generation when the plan skipped them. rollback is the generation a
+// rollback restored, or 0 for an apply.
+func (a *Applier) recordGeneration(plan *Plan, result *ApplyResult, rollback int) (*history.Generation, error) {
+	store := a.History()
+
+	gen := &history.Generation{
+		Machine:  plan.Machine,
+		Contents: make(map[string]string),
+		Rollback: rollback,
+	}
+	if rollback > 0 {
+		gen.Note = fmt.Sprintf("rollback to %d", rollback)
+	}
+
+	if resolved, err := a.Resolve(plan.Machine); err == nil {
+		gen.Profiles = resolved.Profiles
+		gen.Configs = resolved.Configs
+		gen.Features, gen.Settings = a.machineFacts(resolved)
+	}
+
+	source := &config.Source{Path: a.configPath}
+	if commit, err := source.GetCurrentCommit(); err == nil {
+		gen.Commit = commit
+	}
+
+	if len(plan.Packages) > 0 {
+		gen.Packages = make(map[string][]string)
+		for _, pkg := range plan.Packages {
+			if !pkg.Available {
+				continue
+			}
+			installed := append(append([]string{}, pkg.Skip...), pkg.Install...)
+			sort.Strings(installed)
+			gen.Packages[pkg.Manager] = installed
+		}
+	} else if latest, err := store.Latest(); err == nil && latest != nil {
+		gen.Packages = latest.Packages
+	}
+
+	gen.Links = a.linker.Manifest().Entries()
+	sort.Slice(gen.Links, func(i, j int) bool {
+		return gen.Links[i].Target < gen.Links[j].Target
+	})
+	for _, entry := range gen.Links {
+		if !recordsContent(entry) {
+			continue
+		}
+		content, err := os.ReadFile(entry.Target)
+		if err != nil {
+			continue
+		}
+		hash, err := store.SaveContent(content)
+		if err != nil {
+			return nil, err
+		}
+		gen.Contents[entry.Target] = hash
+	}
+
+	if result.LinkResult != nil {
+		gen.Backups = result.LinkResult.Backed
+	}
+
+	if err := store.Record(gen); err != nil {
+		return nil, err
+	}
+	a.pruneHistory()
+	return gen, nil
+}
+
+// PlanRollback plans putting back the links and written files of gen and
+// pruning the links it did not have. Content that was not recorded is
+// rendered again from the current source with the machine of gen.
+func (a *Applier) PlanRollback(gen *history.Generation) []linker.LinkAction {
+	store := a.History()
+
+	var renderOpts *linker.LinkOptions
+	actions := a.linker.PlanRestore(gen.Links, func(target string) ([]byte, error) {
+		if hash, ok := gen.Contents[target]; ok {
+			return store.Content(hash)
+		}
+		entry, _ := gen.Link(target)
+		if !entry.IsTemplate && !entry.IsEncrypted {
+			return nil, fmt.Errorf("generation %d has no content for %s", gen.Number, target)
+		}
+
+		if renderOpts == nil {
+			opts, err := a.renderOptions(gen.Machine)
+			if err != nil {
+				return nil, err
+			}
+			renderOpts = &opts
+		}
+		return a.linker.Render(entry, *renderOpts)
+	})
+
+	all := func(string) bool { return true }
+	return append(actions, a.linker.PlanPrune(actions, all)...)
+}
+
+// renderOptions sets up templates and decryption for machineName
+func (a *Applier) renderOptions(machineName string) (linker.LinkOptions, error) {
+	opts := a.linkOptions(ApplyOptions{MachineName: machineName})
+	resolved, err := a.Resolve(machineName)
+	if err != nil {
+		return opts, err
+	}
+	if opts.Templates, err = a.TemplateEngine(resolved, machineName); err != nil {
+		return opts, err
+	}
+	if opts.Decrypter, err = a.Crypt(); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// recordsContent reports whether the written content of entry is saved in
+// the history store. Decrypted files and templates that resolve secrets are
+// not, so no plaintext secret lands in the store; a rollback renders them
+// again instead.
+func recordsContent(entry linker.LinkEntry) bool {
+	switch {
+	case entry.IsEncrypted:
+		return false
+	case entry.IsTemplate:
+		source, err := os.ReadFile(entry.Source)
+		return err == nil && !template.UsesSecrets(source)
+	}
+	return entry.Mode == linker.ModeCopy
+}
+
+// Rollback applies a PlanRollback as one transaction and records the result
+// as a new generation. Actions that could not be planned are left out.
+func (a *Applier) Rollback(ctx context.Context, gen *history.Generation, actions []linker.LinkAction) (*ApplyResult, error) {
+	plan := &Plan{Machine: gen.Machine}
+	for _, action := range actions {
+		if action.Action != linker.ActionError {
+			plan.Links = append(plan.Links, action)
+		}
+	}
+
+	if err := a.linker.Begin(); err != nil {
+		return nil, err
+	}
+
+	result := &ApplyResult{Plan: plan}
+	opts := a.linkOptions(ApplyOptions{})
+	opts.Context = ctx
+	result.LinkResult = a.linker.Execute(plan.Links, opts)
+	for _, e := range result.LinkResult.Errors {
+		result.Errors = append(result.Errors, e)
+	}
+
+	if !result.Success() || ctx.Err() != nil {
+		a.undoLinks(ctx, result)
+		return result, nil
+	}
+	if err := a.linker.Commit(); err != nil {
+		result.Errors = append(result.Errors, err)
+		a.undoLinks(ctx, result)
+		return result, nil
+	}
+
+	recorded, err := a.recordGeneration(plan, result, gen.Number)
+	if err != nil {
+		return result, fmt.Errorf("rolled back, but failed to record the generation: %w", err)
+	}
+	result.Generation = recorded.Number
+	return result, nil
+}
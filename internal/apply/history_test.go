@@ -0,0 +1,44 @@
+package apply
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arthur404dev/dotts/internal/linker"
+)
+
+func TestRecordsContent(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	plain := write("gitconfig", "name = <<dotts:name>>\n")
+	secret := write("netrc", "password <<dotts:secret:op/github/token>>\n")
+
+	tests := []struct {
+		name  string
+		entry linker.LinkEntry
+		want  bool
+	}{
+		{name: "template", entry: linker.LinkEntry{Source: plain, IsTemplate: true}, want: true},
+		{name: "template with secrets", entry: linker.LinkEntry{Source: secret, IsTemplate: true}, want: false},
+		{name: "template source gone", entry: linker.LinkEntry{Source: filepath.Join(dir, "gone"), IsTemplate: true}, want: false},
+		{name: "decrypted", entry: linker.LinkEntry{Source: plain + ".age", IsEncrypted: true}, want: false},
+		{name: "copy", entry: linker.LinkEntry{Source: plain, Mode: linker.ModeCopy}, want: true},
+		{name: "hard link", entry: linker.LinkEntry{Source: plain, Mode: linker.ModeHardlink}, want: false},
+		{name: "symlink", entry: linker.LinkEntry{Source: plain}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := recordsContent(tt.entry); got != tt.want {
+				t.Errorf("recordsContent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
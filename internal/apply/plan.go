@@ -0,0 +1,413 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/diff"
+	"github.com/arthur404dev/dotts/internal/installer"
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/pkg/schema"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+	"github.com/arthur404dev/dotts/pkg/vetru/styles"
+)
+
+// Lifecycle selects which profile scripts run around an apply
+type Lifecycle string
+
+const (
+	LifecycleNone    Lifecycle = ""
+	LifecycleInstall Lifecycle = "install"
+	LifecycleUpdate  Lifecycle = "update"
+)
+
+// Plan is the complete set of changes an apply would make
+type Plan struct {
+	Machine     string              `json:"machine"`
+	Packages    []PackagePlan       `json:"packages,omitempty"`
+	Links       []linker.LinkAction `json:"links,omitempty"`
+	Hooks       []HookPlan          `json:"hooks,omitempty"`
+	PreScripts  []ScriptPlan        `json:"pre_scripts,omitempty"`
+	PostScripts []ScriptPlan        `json:"post_scripts,omitempty"`
+	Warnings    []string            `json:"warnings,omitempty"`
+}
+
+// PackagePlan is the package work for a single manager
+type PackagePlan struct {
+	Manager   string   `json:"manager"`
+	Installer string   `json:"installer"`
+	Available bool     `json:"available"`
+	Install   []string `json:"install,omitempty"`
+	Skip      []string `json:"skip,omitempty"`
+}
+
+// HookPlan is a config's post-link command. It runs only when one of the
+// config's links changes.
+type HookPlan struct {
+	Config  string `json:"config"`
+	Command string `json:"command"`
+}
+
+// ScriptPlan is a lifecycle script that would run
+type ScriptPlan struct {
+	Phase  string `json:"phase"`
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+}
+
+// PackageCount returns how many packages would be installed
+func (p *Plan) PackageCount() int {
+	n := 0
+	for _, pkg := range p.Packages {
+		if pkg.Available {
+			n += len(pkg.Install)
+		}
+	}
+	return n
+}
+
+// LinkChanges returns the link actions that modify the filesystem
+func (p *Plan) LinkChanges() []linker.LinkAction {
+	var changes []linker.LinkAction
+	for _, a := range p.Links {
+		if a.Changes() {
+			changes = append(changes, a)
+		}
+	}
+	return changes
+}
+
+// LinkErrors returns the link actions that could not be planned
+func (p *Plan) LinkErrors() []linker.LinkAction {
+	var errs []linker.LinkAction
+	for _, a := range p.Links {
+		if a.Action == linker.ActionError {
+			errs = append(errs, a)
+		}
+	}
+	return errs
+}
+
+// IsEmpty returns true if applying the plan would change nothing
+func (p *Plan) IsEmpty() bool {
+	return p.PackageCount() == 0 && len(p.LinkChanges()) == 0 && len(p.LinkErrors()) == 0 &&
+		len(p.PreScripts) == 0 && len(p.PostScripts) == 0
+}
+
+// installPlan converts the package section back into an installer plan
+func (p *Plan) installPlan() *installer.InstallPlan {
+	plan := &installer.InstallPlan{}
+	for _, pkg := range p.Packages {
+		if !pkg.Available || len(pkg.Install) == 0 {
+			continue
+		}
+		switch pkg.Manager {
+		case "nix":
+			plan.Nix = pkg.Install
+		case "pacman":
+			plan.Pacman = pkg.Install
+		case "aur":
+			plan.AUR = pkg.Install
+		case "apt":
+			plan.Apt = pkg.Install
+		case "dnf":
+			plan.Dnf = pkg.Install
+		case "brew":
+			plan.Brew = pkg.Install
+		case "cask":
+			plan.Cask = pkg.Install
+		}
+	}
+	return plan
+}
+
+// Plan resolves the machine and computes every change Apply would make
+func (a *Applier) Plan(ctx context.Context, opts ApplyOptions) (*Plan, error) {
+	resolved, err := a.Resolve(opts.MachineName)
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := filterConfigs(resolved.Configs, opts.OnlyConfigs, opts.ExcludeConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Machine: opts.MachineName}
+
+	alternates, err := a.AlternateResolver(a.AlternateContext(resolved))
+	if err != nil {
+		return nil, err
+	}
+	if err := a.planConfigMeta(plan, resolved, configs, alternates); err != nil {
+		return nil, err
+	}
+
+	if !opts.SkipPackages && resolved.Packages != nil {
+		installPlan := a.registry.CreatePlan(resolved.Packages)
+		if len(opts.OnlyManagers) > 0 {
+			filtered, err := installPlan.Only(opts.OnlyManagers)
+			if err != nil {
+				return nil, err
+			}
+			installPlan = filtered
+		}
+
+		for _, status := range a.registry.CheckPlan(installPlan) {
+			plan.Packages = append(plan.Packages, PackagePlan{
+				Manager:   status.Name,
+				Installer: status.Installer,
+				Available: status.Available,
+				Install:   status.Missing,
+				Skip:      status.Installed,
+			})
+		}
+	}
+
+	if !opts.SkipDotfiles {
+		linkOpts, err := a.planLinkOptions(resolved, opts, alternates)
+		if err != nil {
+			return nil, err
+		}
+		for _, configName := range configs {
+			actions, err := a.linker.PlanConfig(configName, linkOpts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to plan %s: %w", configName, err)
+			}
+			plan.Links = append(plan.Links, actions...)
+		}
+		plan.Links = linker.CheckDirLinks(plan.Links)
+		if !opts.NoPrune {
+			plan.Links = append(plan.Links, a.linker.PlanPrune(plan.Links, pruneScope(opts))...)
+		}
+	}
+
+	var pre, post []string
+	switch opts.Lifecycle {
+	case LifecycleInstall:
+		pre, post = resolved.Scripts.PreInstall, resolved.Scripts.PostInstall
+	case LifecycleUpdate:
+		pre, post = resolved.Scripts.PreUpdate, resolved.Scripts.PostUpdate
+	}
+	plan.PreScripts = a.planScripts("pre_"+string(opts.Lifecycle), pre)
+	plan.PostScripts = a.planScripts("post_"+string(opts.Lifecycle), post)
+
+	return plan, nil
+}
+
+// planLinkOptions sets up templates, alternates, decryption and link modes
+// for planning the configs of resolved
+func (a *Applier) planLinkOptions(resolved *config.ResolvedConfig, opts ApplyOptions, alternates *config.AlternateResolver) (linker.LinkOptions, error) {
+	var err error
+	linkOpts := a.linkOptions(opts)
+	linkOpts.Alternates = alternates
+	if linkOpts.Templates, err = a.TemplateEngine(resolved, opts.MachineName); err != nil {
+		return linkOpts, err
+	}
+	if linkOpts.Decrypter, err = a.Crypt(); err != nil {
+		return linkOpts, err
+	}
+	if linkOpts.Modes, err = linker.ParseModeRules(resolved.LinkModes); err != nil {
+		return linkOpts, err
+	}
+	return linkOpts, nil
+}
+
+// planConfigMeta applies the .dotts.yaml of every selected config that
+// supports this machine: required packages join the install, missing binaries
+// become warnings and post-link hooks are queued.
+func (a *Applier) planConfigMeta(plan *Plan, resolved *config.ResolvedConfig, configs []string, alternates *config.AlternateResolver) error {
+	for _, name := range configs {
+		meta, err := a.loader.LoadConfigMeta(name)
+		if err != nil {
+			return err
+		}
+		if config.Unsupported(meta, alternates) != "" {
+			continue
+		}
+
+		if meta.Requires.Packages != nil {
+			if resolved.Packages == nil {
+				resolved.Packages = &schema.PackageManifest{}
+			}
+			resolved.Packages.Merge(meta.Requires.Packages)
+		}
+		for _, bin := range config.MissingBinaries(meta) {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s requires %s, which is not on PATH", name, bin))
+		}
+		for _, command := range meta.Hooks.PostLink {
+			plan.Hooks = append(plan.Hooks, HookPlan{Config: name, Command: command})
+		}
+	}
+	return nil
+}
+
+func (a *Applier) planScripts(phase string, scripts []string) []ScriptPlan {
+	var plans []ScriptPlan
+	for _, script := range scripts {
+		_, err := os.Stat(a.loader.GetScriptPath(script))
+		plans = append(plans, ScriptPlan{
+			Phase:  phase,
+			Path:   script,
+			Exists: err == nil,
+		})
+	}
+	return plans
+}
+
+// PrintPlan renders a plan as a human-readable diff
+func PrintPlan(plan *Plan, showDiffs bool) {
+	for _, w := range plan.Warnings {
+		fmt.Println(styles.Warn(w))
+	}
+
+	if len(plan.PreScripts) > 0 {
+		fmt.Println()
+		fmt.Println(styles.Info("Scripts before apply:"))
+		printScripts(plan.PreScripts)
+	}
+
+	if len(plan.Packages) > 0 {
+		fmt.Println()
+		fmt.Println(styles.Info("Packages:"))
+		for _, pkg := range plan.Packages {
+			switch {
+			case !pkg.Available:
+				fmt.Println(styles.StatusLine(styles.ErrorIcon, pkg.Manager,
+					fmt.Sprintf("%s unavailable, %d package(s) skipped", pkg.Installer, len(pkg.Install))))
+			case len(pkg.Install) == 0:
+				fmt.Println(styles.StatusLine(styles.SuccessIcon, pkg.Manager,
+					fmt.Sprintf("all %d installed", len(pkg.Skip))))
+			default:
+				fmt.Println(styles.StatusLine(styles.ActiveIcon, pkg.Manager,
+					fmt.Sprintf("%d to install, %d installed", len(pkg.Install), len(pkg.Skip))))
+				progress.PrintMuted("  + " + strings.Join(pkg.Install, ", "))
+			}
+		}
+	}
+
+	if len(plan.Links) > 0 {
+		fmt.Println()
+		fmt.Println(styles.Info("Dotfiles:"))
+
+		unchanged := 0
+		var ignored []linker.LinkAction
+		for _, action := range plan.Links {
+			switch action.Action {
+			case linker.ActionUnchanged:
+				unchanged++
+				continue
+			case linker.ActionIgnored:
+				ignored = append(ignored, action)
+				continue
+			}
+			fmt.Println(formatLinkAction(action))
+			for _, w := range action.Warnings {
+				fmt.Println("      " + styles.WarningStyle.Render("! "+w))
+			}
+			if showDiffs && action.Diff != "" {
+				PrintDiff(action.Diff)
+			}
+		}
+		if unchanged > 0 {
+			progress.PrintMuted(fmt.Sprintf("%d link(s) unchanged", unchanged))
+		}
+		if len(ignored) > 0 {
+			progress.PrintMuted(fmt.Sprintf("%d path(s) ignored:", len(ignored)))
+			for _, action := range ignored {
+				progress.PrintMuted(fmt.Sprintf("  %s — %s", action.Source, action.Reason))
+			}
+		}
+	}
+
+	if len(plan.Hooks) > 0 {
+		fmt.Println()
+		fmt.Println(styles.Info("Hooks when a config changes:"))
+		for _, h := range plan.Hooks {
+			fmt.Println(styles.StatusLine(styles.ActiveIcon, h.Config, h.Command))
+		}
+	}
+
+	if len(plan.PostScripts) > 0 {
+		fmt.Println()
+		fmt.Println(styles.Info("Scripts after apply:"))
+		printScripts(plan.PostScripts)
+	}
+
+	fmt.Println()
+	if plan.IsEmpty() {
+		fmt.Println(styles.Success("Nothing to do"))
+		return
+	}
+	fmt.Println(styles.Mute(fmt.Sprintf("Plan: %d package(s) to install, %d link change(s), %d script(s)",
+		plan.PackageCount(), len(plan.LinkChanges()), len(plan.PreScripts)+len(plan.PostScripts))))
+	if errs := plan.LinkErrors(); len(errs) > 0 {
+		fmt.Println(styles.Err(fmt.Sprintf("%d link(s) cannot be applied", len(errs))))
+	}
+}
+
+func formatLinkAction(action linker.LinkAction) string {
+	symbol := map[linker.ActionKind]string{
+		linker.ActionCreate:   styles.SuccessStyle.Render("+"),
+		linker.ActionReplace:  styles.AccentStyle.Render("~"),
+		linker.ActionSplit:    styles.AccentStyle.Render("~"),
+		linker.ActionPrune:    styles.ErrorStyle.Render("-"),
+		linker.ActionBackup:   styles.WarningStyle.Render("!"),
+		linker.ActionSkip:     styles.MutedStyle.Render("-"),
+		linker.ActionModified: styles.WarningStyle.Render("?"),
+		linker.ActionError:    styles.ErrorStyle.Render("✗"),
+	}[action.Action]
+
+	line := fmt.Sprintf("  %s %-9s %s", symbol, action.Action, action.Target)
+	if action.Variant != "" {
+		line += styles.Mute(" (##" + action.Variant + ")")
+	}
+	if action.IsEncrypted {
+		line += styles.Mute(" (encrypted)")
+	}
+	if action.Mode != "" {
+		line += styles.Mute(" (" + string(action.Mode) + ")")
+	}
+	if action.IsTemplate {
+		if added, removed := diff.Stats(action.Diff); added+removed > 0 {
+			line += styles.Mute(fmt.Sprintf(" (template +%d -%d)", added, removed))
+		} else {
+			line += styles.Mute(" (template)")
+		}
+	}
+	if action.Reason != "" {
+		line += styles.Mute(" — " + action.Reason)
+	}
+	return line
+}
+
+func printScripts(scripts []ScriptPlan) {
+	for _, s := range scripts {
+		if s.Exists {
+			fmt.Println(styles.StatusLine(styles.ActiveIcon, s.Phase, s.Path))
+		} else {
+			fmt.Println(styles.StatusLine(styles.WarningIcon, s.Phase, s.Path+" (missing, will be skipped)"))
+		}
+	}
+}
+
+// PrintDiff prints a unified diff indented and coloured
+func PrintDiff(d string) {
+	for _, line := range strings.Split(strings.TrimRight(d, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			fmt.Println("      " + styles.Mute(line))
+		case strings.HasPrefix(line, "+"):
+			fmt.Println("      " + styles.SuccessStyle.UnsetBold().Render(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Println("      " + styles.ErrorStyle.UnsetBold().Render(line))
+		case strings.HasPrefix(line, "@@"):
+			fmt.Println("      " + styles.AccentStyle.Render(line))
+		default:
+			fmt.Println("      " + line)
+		}
+	}
+}
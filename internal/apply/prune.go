@@ -0,0 +1,51 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/arthur404dev/dotts/internal/linker"
+)
+
+// PlanPrune lists the managed links an apply would prune. Configs narrows the
+// result to those configs, including ones no longer applied; empty means all.
+func (a *Applier) PlanPrune(ctx context.Context, machineName string, configs []string) ([]linker.LinkAction, error) {
+	plan, err := a.Plan(ctx, ApplyOptions{MachineName: machineName, SkipPackages: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var prunes []linker.LinkAction
+	for _, action := range plan.Links {
+		if action.Action != linker.ActionPrune {
+			continue
+		}
+		if len(configs) > 0 && !slices.Contains(configs, action.Config) {
+			continue
+		}
+		prunes = append(prunes, action)
+	}
+	return prunes, nil
+}
+
+// Prune removes the given stale links, restoring originals from backup, and
+// saves the manifest. If any link fails, or ctx is cancelled, all of them are
+// put back.
+func (a *Applier) Prune(ctx context.Context, actions []linker.LinkAction) (*linker.LinkResult, error) {
+	if err := a.linker.Begin(); err != nil {
+		return nil, err
+	}
+
+	opts := a.linkOptions(ApplyOptions{})
+	opts.Context = ctx
+	result := a.linker.Execute(actions, opts)
+
+	if !result.Success() {
+		if err := a.linker.Rollback(); err != nil {
+			return result, fmt.Errorf("failed to roll back prune: %w", err)
+		}
+		return result, nil
+	}
+	return result, a.linker.Commit()
+}
@@ -0,0 +1,69 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+)
+
+// runScripts runs planned lifecycle scripts in order, stopping at the first failure.
+// Scripts missing from the repo are skipped with a warning.
+func (a *Applier) runScripts(ctx context.Context, scripts []ScriptPlan) error {
+	for _, script := range scripts {
+		if !script.Exists {
+			progress.PrintWarning(fmt.Sprintf("Skipping %s script %s: not found", script.Phase, script.Path))
+			continue
+		}
+
+		progress.PrintInfo("Running " + script.Path)
+
+		cmd := exec.CommandContext(ctx, "sh", a.loader.GetScriptPath(script.Path))
+		cmd.Dir = a.configPath
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Stdin = os.Stdin
+		cmd.Env = append(os.Environ(),
+			"DOTTS_CONFIG_PATH="+a.configPath,
+			"DOTTS_OS="+string(a.sysInfo.OS),
+			"DOTTS_DISTRO="+string(a.sysInfo.Distro),
+		)
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s script %s failed: %w", script.Phase, script.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// runHooks runs the post-link hooks of one config from its directory
+func (a *Applier) runHooks(ctx context.Context, configName string, hooks []HookPlan) error {
+	for _, hook := range hooks {
+		if hook.Config != configName {
+			continue
+		}
+
+		progress.PrintInfo("Running " + hook.Command)
+
+		cmd := exec.CommandContext(ctx, "sh", "-c", hook.Command)
+		cmd.Dir = a.loader.GetConfigPath(configName)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Stdin = os.Stdin
+		cmd.Env = append(os.Environ(),
+			"DOTTS_CONFIG_PATH="+a.configPath,
+			"DOTTS_CONFIG="+configName,
+			"DOTTS_OS="+string(a.sysInfo.OS),
+			"DOTTS_DISTRO="+string(a.sysInfo.Distro),
+		)
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s post_link hook %q failed: %w", configName, hook.Command, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package apply
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/arthur404dev/dotts/internal/crypt"
+	"github.com/arthur404dev/dotts/internal/diff"
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/internal/personal"
+	"github.com/arthur404dev/dotts/internal/template"
+)
+
+// errSourceChanged refuses an edit whose source changed since the last apply
+var errSourceChanged = errors.New("the source changed since the last apply, run 'dotts apply' to merge the edit first")
+
+// SyncChange is an edited target and what syncing writes to its source
+type SyncChange struct {
+	Entry        linker.LinkEntry
+	Content      []byte   // new source content; nil when the edit cannot be synced
+	Diff         string   // changes to the source, secrets masked; empty for encrypted files
+	Placeholders []string // keys whose values in the edit became placeholders again
+	Reason       string   // why the edit cannot be synced
+
+	local []byte
+}
+
+// CanSync reports whether the change can be written to the source
+func (c SyncChange) CanSync() bool {
+	return c.Content != nil
+}
+
+// PlanSync finds the rendered templates, copies and decrypted files edited
+// since the last apply and works out the source that produces each edit.
+// Edits are refused when the source changed too, since the last render, as
+// apply has to merge those first; and for templates with <<% %>> logic,
+// whose lines cannot be traced back to the source.
+func (a *Applier) PlanSync(machineName string) ([]SyncChange, error) {
+	edited := a.linker.Edited()
+	if len(edited) == 0 {
+		return nil, nil
+	}
+
+	resolved, err := a.Resolve(machineName)
+	if err != nil {
+		return nil, err
+	}
+	engine, err := a.TemplateEngine(resolved, machineName)
+	if err != nil {
+		return nil, err
+	}
+	decrypter, err := a.Crypt()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []SyncChange
+	for _, entry := range edited {
+		change := SyncChange{Entry: entry}
+		if change.local, err = os.ReadFile(entry.Target); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case entry.IsEncrypted:
+			err = syncEncrypted(&change, decrypter)
+		case entry.IsTemplate:
+			err = syncTemplate(&change, engine)
+		default:
+			err = syncCopy(&change)
+		}
+		if err != nil {
+			change.Content, change.Diff = nil, ""
+			change.Reason = err.Error()
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Sync writes the sources of changes that can be synced and records their
+// targets as up to date
+func (a *Applier) Sync(changes []SyncChange) error {
+	for _, change := range changes {
+		if !change.CanSync() {
+			continue
+		}
+
+		info, err := os.Stat(change.Entry.Source)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(change.Entry.Source, change.Content, info.Mode().Perm()); err != nil {
+			return err
+		}
+		a.linker.Synced(change.Entry.Target, change.local)
+	}
+	return a.linker.Save()
+}
+
+// syncCopy takes a copied file's edit as the new source
+func syncCopy(change *SyncChange) error {
+	source, err := os.ReadFile(change.Entry.Source)
+	if err != nil {
+		return err
+	}
+	if !change.Entry.IsLastRender(source) {
+		return errSourceChanged
+	}
+
+	change.Content = change.local
+	change.Diff = diff.Unified(change.Entry.Source, change.Entry.Source+" (synced)", string(source), string(change.local))
+	return nil
+}
+
+// syncEncrypted encrypts a decrypted file's edit as the new source
+func syncEncrypted(change *SyncChange, decrypter *crypt.Age) error {
+	plaintext, err := decrypter.Decrypt(change.Entry.Source)
+	if err != nil {
+		return err
+	}
+	if !change.Entry.IsLastRender(plaintext) {
+		return errSourceChanged
+	}
+
+	change.Content, err = decrypter.Encrypt(change.local)
+	return err
+}
+
+// syncTemplate carries a rendered file's edit over to its template line by
+// line. Unchanged lines keep their placeholders; in edited lines, values of
+// the placeholders the template uses and personal values become
+// placeholders again. The result must render back to the edit exactly.
+func syncTemplate(change *SyncChange, engine *template.Engine) error {
+	source, err := os.ReadFile(change.Entry.Source)
+	if err != nil {
+		return err
+	}
+	if template.HasActions(source) {
+		return fmt.Errorf("uses template logic, edit the source by hand")
+	}
+
+	rendered, err := engine.Render(change.Entry.Source, source)
+	if err != nil {
+		return err
+	}
+	if !change.Entry.IsLastRender(rendered) {
+		return errSourceChanged
+	}
+
+	values := templateValues(source, engine)
+	seen := make(map[string]bool)
+	synced, err := diff.Rebase(string(source), string(rendered), string(change.local), func(line string) string {
+		out, keys := templatizeContent([]byte(line), values)
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				change.Placeholders = append(change.Placeholders, key)
+			}
+		}
+		return string(out)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot map the edit to the template: %w", err)
+	}
+
+	if engine.Redact(synced) != synced {
+		return fmt.Errorf("the edit contains a secret value, edit the source by hand")
+	}
+	check, err := engine.Render(change.Entry.Source, []byte(synced))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(check, change.local) {
+		return fmt.Errorf("the synced template would not render to the edit, edit the source by hand")
+	}
+
+	change.Content = []byte(synced)
+	change.Diff = engine.Redact(diff.Unified(change.Entry.Source, change.Entry.Source+" (synced)", string(source), synced))
+	return nil
+}
+
+// templateValues are the values an edit may hold that belong in a
+// placeholder: those of the placeholders the template already uses, and
+// the personal ones
+func templateValues(source []byte, engine *template.Engine) map[string]string {
+	values := make(map[string]string)
+	if pc, err := personal.Load(); err == nil {
+		for key, value := range pc.ToMap() {
+			values[key] = value
+		}
+	}
+	for _, key := range template.ExtractPlaceholders(string(source)) {
+		if value, ok := engine.Values()[key]; ok && value != "" {
+			values[key] = value
+		}
+	}
+	return values
+}
@@ -0,0 +1,134 @@
+package apply
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/internal/secret"
+	"github.com/arthur404dev/dotts/internal/template"
+)
+
+func TestSyncTemplate(t *testing.T) {
+	// no personal.yaml, so only the template's own placeholders count
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	engine := template.NewEngine(&template.Context{
+		Settings: map[string]any{"theme": "dark"},
+		Personal: map[string]any{"user": map[string]any{"name": "Ada Lovelace", "email": "ada@example.com"}},
+	})
+	secrets := secret.NewRegistry()
+	secrets.Register(secret.NewCommandProvider("test", []string{"echo"}))
+	engine.SetSecrets(secrets)
+
+	tests := []struct {
+		name             string
+		source           string
+		edit             func(rendered string) string
+		sourceChanged    bool
+		want             string
+		wantPlaceholders []string
+		wantErr          bool
+	}{
+		{
+			name:   "edit to a plain line",
+			source: "[user]\n\tname = <<dotts:user.name>>\n\tcolor = auto\n",
+			edit: func(string) string {
+				return "[user]\n\tname = Ada Lovelace\n\tcolor = always\n"
+			},
+			want: "[user]\n\tname = <<dotts:user.name>>\n\tcolor = always\n",
+		},
+		{
+			name:   "values in edited lines become placeholders",
+			source: "[user]\n\tname = <<dotts:user.name>>\n\temail = <<dotts:user.email>>\n",
+			edit: func(string) string {
+				return "[user]\n\tname = Ada Lovelace\n\temail = ada@example.com\n# maintained by Ada Lovelace <ada@example.com>\n"
+			},
+			want:             "[user]\n\tname = <<dotts:user.name>>\n\temail = <<dotts:user.email>>\n# maintained by <<dotts:user.name>> <<<dotts:user.email>>>\n",
+			wantPlaceholders: []string{"user.email", "user.name"},
+		},
+		{
+			name:   "setting placeholders",
+			source: "theme=<<dotts:settings.theme>>\n",
+			edit: func(string) string {
+				return "theme=dark\nprompt=dark\n"
+			},
+			want:             "theme=<<dotts:settings.theme>>\nprompt=<<dotts:settings.theme>>\n",
+			wantPlaceholders: []string{"settings.theme"},
+		},
+		{
+			name:    "template logic",
+			source:  "<<% if true %>>x<<% end %>>\n",
+			edit:    func(rendered string) string { return rendered + "y\n" },
+			wantErr: true,
+		},
+		{
+			name:    "secret value in the edit",
+			source:  "token=<<dotts:secret:test/hunter2>>\n",
+			edit:    func(string) string { return "token=hunter2\nbackup=hunter2\n" },
+			wantErr: true,
+		},
+		{
+			name:          "source changed since the last apply",
+			source:        "name = <<dotts:user.name>>\n",
+			edit:          func(rendered string) string { return rendered + "x\n" },
+			sourceChanged: true,
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gitconfig")
+			if err := os.WriteFile(path, []byte(tt.source), 0644); err != nil {
+				t.Fatal(err)
+			}
+			rendered, err := engine.Render(path, []byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lastRender := rendered
+			if tt.sourceChanged {
+				lastRender = []byte("before\n")
+			}
+			sum := sha256.Sum256(lastRender)
+			change := &SyncChange{
+				Entry: linker.LinkEntry{Source: path, IsTemplate: true, RenderedHash: hex.EncodeToString(sum[:])},
+				local: []byte(tt.edit(string(rendered))),
+			}
+
+			err = syncTemplate(change, engine)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("syncTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.sourceChanged && !errors.Is(err, errSourceChanged) {
+				t.Errorf("syncTemplate() error = %v, want errSourceChanged", err)
+			}
+			if err != nil {
+				return
+			}
+
+			if string(change.Content) != tt.want {
+				t.Errorf("syncTemplate() source = %q, want %q", change.Content, tt.want)
+			}
+			if !reflect.DeepEqual(change.Placeholders, tt.wantPlaceholders) {
+				t.Errorf("syncTemplate() placeholders = %v, want %v", change.Placeholders, tt.wantPlaceholders)
+			}
+
+			// the synced template renders back to the edit
+			again, err := engine.Render(path, change.Content)
+			if err != nil {
+				t.Fatalf("Render() of the synced template error = %v", err)
+			}
+			if !reflect.DeepEqual(again, change.local) {
+				t.Errorf("synced template renders %q, want the edit %q", again, change.local)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package apply
+
+import (
+	"fmt"
+
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/pkg/vetru/progress"
+)
+
+// snapshotTargets archives every target the plan changes before the first
+// apply, while dotts has not touched any of them yet
+func (a *Applier) snapshotTargets(plan *Plan) error {
+	if a.linker.HasSnapshot() || a.linker.Manifest().Count() > 0 {
+		return nil
+	}
+
+	var targets []string
+	for _, action := range plan.Links {
+		if action.Changes() {
+			targets = append(targets, action.Target)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	snap, err := a.linker.TakeSnapshot(targets)
+	if err != nil {
+		return err
+	}
+	progress.PrintInfo(fmt.Sprintf("Snapshot of %d target(s) saved, 'dotts uninstall' restores them", len(snap.Paths)))
+	return nil
+}
+
+// Manifest returns the links and files dotts manages
+func (a *Applier) Manifest() *linker.Manifest {
+	return a.linker.Manifest()
+}
+
+// Snapshot returns the snapshot taken before the first apply, or nil
+func (a *Applier) Snapshot() (*linker.Snapshot, error) {
+	return a.linker.Snapshot()
+}
+
+// Uninstall removes every link and file dotts placed and restores what was
+// there before, then discards the snapshot. If anything fails, every change
+// is undone.
+func (a *Applier) Uninstall() (*linker.UninstallResult, error) {
+	if err := a.linker.Begin(); err != nil {
+		return nil, err
+	}
+
+	result, err := a.linker.Uninstall()
+	if err == nil {
+		err = a.linker.Commit()
+	}
+	if err != nil {
+		if rbErr := a.linker.Rollback(); rbErr != nil {
+			return result, fmt.Errorf("%w (and failed to roll back: %v)", err, rbErr)
+		}
+		return result, err
+	}
+
+	snap, err := a.linker.Snapshot()
+	if err != nil || snap == nil {
+		return result, err
+	}
+	return result, snap.Discard()
+}
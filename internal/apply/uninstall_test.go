@@ -0,0 +1,89 @@
+package apply
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arthur404dev/dotts/internal/linker"
+)
+
+// linkedApplier links configs/shell/.zshrc over an existing ~/.zshrc, after
+// taking the first-apply snapshot. It returns the applier, the target and
+// the data directory.
+func linkedApplier(t *testing.T) (*Applier, string, string) {
+	t.Helper()
+	home, root, dataDir := t.TempDir(), t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+
+	target := filepath.Join(home, ".zshrc")
+	source := filepath.Join(root, "configs", "shell", ".zshrc")
+	for path, content := range map[string]string{target: "original", source: "zshrc"} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lnk, err := linker.NewSymlinkLinker(dataDir, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Applier{linker: lnk}
+	if _, err := lnk.TakeSnapshot([]string{target}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lnk.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := lnk.LinkConfig("shell", linker.DefaultLinkOptions()); err != nil || len(result.Errors) > 0 {
+		t.Fatalf("LinkConfig() error = %v, %v", err, result.Errors)
+	}
+	if err := lnk.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return a, target, dataDir
+}
+
+func TestUninstall(t *testing.T) {
+	a, target, _ := linkedApplier(t)
+
+	result, err := a.Uninstall()
+	if err != nil {
+		t.Fatalf("Uninstall() error = %v", err)
+	}
+	if len(result.Restored) != 1 {
+		t.Errorf("Restored = %v, want %s", result.Restored, target)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil || string(data) != "original" {
+		t.Errorf("%s = %q (%v), want the original", target, data, err)
+	}
+	if a.linker.HasSnapshot() {
+		t.Error("Uninstall() kept the snapshot")
+	}
+}
+
+func TestUninstallRollsBackOnFailure(t *testing.T) {
+	a, target, dataDir := linkedApplier(t)
+
+	// a snapshot without its archive cannot restore the original
+	if err := os.Remove(filepath.Join(dataDir, "snapshot", "home.tar.gz")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Uninstall(); err == nil {
+		t.Fatal("Uninstall() succeeded without the snapshot archive")
+	}
+	if !a.linker.IsOurLink(target) {
+		t.Error("Uninstall() did not put the link back")
+	}
+	if !a.linker.Manifest().HasEntry(target) {
+		t.Error("Uninstall() did not restore the manifest")
+	}
+	if !a.linker.HasSnapshot() {
+		t.Error("Uninstall() discarded the snapshot of a failed uninstall")
+	}
+}
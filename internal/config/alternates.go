@@ -1,39 +1,194 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 )
 
+// AlternateSeparator separates a file's base name from its alternate suffixes
+const AlternateSeparator = "##"
+
+// SplitAlternate splits "kitty.conf##os.darwin" into "kitty.conf" and "os.darwin".
+// ok is false for names without alternate suffixes.
+func SplitAlternate(name string) (base, suffix string, ok bool) {
+	idx := strings.Index(name, AlternateSeparator)
+	if idx < 0 {
+		return name, "", false
+	}
+	return name[:idx], name[idx+len(AlternateSeparator):], true
+}
+
 type AlternateMatch struct {
-	Path     string
-	Score    int
-	Suffixes []string
+	Path      string
+	Score     int
+	Suffixes  []string
+	Matched   []string // predicates that hold on this machine
+	Unmatched []string // predicates that disqualify the file
+	Eligible  bool
+	Err       error // set when a suffix cannot be parsed
+}
+
+// AlternateExplanation is the scoring of every variant of a single file
+type AlternateExplanation struct {
+	BasePath   string
+	Candidates []AlternateMatch // eligible candidates first, highest score first
+	Winner     *AlternateMatch
+	Ties       []AlternateMatch // other eligible candidates with the winner's score
+}
+
+// AlternateContext describes the machine that alternate suffixes are matched against
+type AlternateContext struct {
+	OS           string
+	Distro       string
+	Arch         string
+	Profile      string
+	Hostname     string
+	User         string
+	Features     []string
+	Settings     map[string]any
+	Environments []string // detected environment classes: wsl, container, vm
+}
+
+// Override sets a single context value, as given to "--as key=value".
+// feature and env add to the existing list; setting.<key> sets a setting.
+func (c *AlternateContext) Override(key, value string) error {
+	switch k := strings.ToLower(key); {
+	case k == "hostname":
+		c.Hostname = value
+	case k == "os":
+		c.OS = value
+	case k == "distro":
+		c.Distro = value
+	case k == "arch":
+		c.Arch = value
+	case k == "profile":
+		c.Profile = value
+	case k == "user":
+		c.User = value
+	case k == "feature":
+		if !containsFold(c.Features, value) {
+			c.Features = append(c.Features, value)
+		}
+	case k == "env":
+		if !containsFold(c.Environments, value) {
+			c.Environments = append(c.Environments, value)
+		}
+	case strings.HasPrefix(k, "setting."):
+		if c.Settings == nil {
+			c.Settings = make(map[string]any)
+		}
+		c.Settings[key[len("setting."):]] = value
+	default:
+		return fmt.Errorf("unknown machine attribute %q", key)
+	}
+	return nil
+}
+
+// DefaultAlternateScores weighs each predicate key; more specific keys win
+var DefaultAlternateScores = map[string]int{
+	"hostname": 1000,
+	"user":     500,
+	"profile":  100,
+	"setting":  75,
+	"feature":  75,
+	"distro":   50,
+	"env":      30,
+	"arch":     20,
+	"os":       10,
+	"default":  1,
+}
+
+// environmentClasses may be used as bare suffixes, e.g. kitty.conf##wsl
+var environmentClasses = []string{"wsl", "container", "vm"}
+
+// AlternatePredicate is a single parsed suffix such as "os.linux" or "!feature.gaming"
+type AlternatePredicate struct {
+	Raw     string
+	Key     string
+	Value   string
+	Setting string // setting key for setting.<key>=<value>
+	Negated bool
+}
+
+// ParsePredicate parses one comma-separated alternate suffix
+func ParsePredicate(raw string) (AlternatePredicate, error) {
+	p := AlternatePredicate{Raw: raw}
+
+	s := strings.TrimSpace(raw)
+	if strings.HasPrefix(s, "!") {
+		p.Negated = true
+		s = s[1:]
+	}
+
+	key, value, hasValue := strings.Cut(s, ".")
+	p.Key = strings.ToLower(key)
+
+	switch {
+	case p.Key == "default":
+		if hasValue || p.Negated {
+			return p, fmt.Errorf("invalid alternate %q: default takes no value and cannot be negated", raw)
+		}
+		return p, nil
+	case !hasValue && contains(environmentClasses, p.Key):
+		p.Key, p.Value = "env", key
+		return p, nil
+	case !hasValue:
+		return p, fmt.Errorf("unknown alternate %q", raw)
+	case value == "":
+		return p, fmt.Errorf("invalid alternate %q: missing value", raw)
+	}
+
+	switch p.Key {
+	case "hostname", "user", "profile", "feature", "distro", "arch", "os":
+		p.Value = value
+	case "env":
+		if !contains(environmentClasses, strings.ToLower(value)) {
+			return p, fmt.Errorf("unknown environment %q in alternate %q (expected %s)",
+				value, raw, strings.Join(environmentClasses, ", "))
+		}
+		p.Value = value
+	case "setting":
+		settingKey, settingValue, ok := strings.Cut(value, "=")
+		if !ok || settingKey == "" {
+			return p, fmt.Errorf("invalid alternate %q: expected setting.<key>=<value>", raw)
+		}
+		p.Setting, p.Value = settingKey, settingValue
+	default:
+		return p, fmt.Errorf("unknown alternate key %q in %q", key, raw)
+	}
+
+	return p, nil
 }
 
 type AlternateResolver struct {
-	os       string
-	distro   string
-	profile  string
-	hostname string
+	ctx    AlternateContext
+	scores map[string]int
 }
 
-func NewAlternateResolver(osName, distro, profile, hostname string) *AlternateResolver {
-	return &AlternateResolver{
-		os:       osName,
-		distro:   distro,
-		profile:  profile,
-		hostname: hostname,
+func NewAlternateResolver(ctx AlternateContext) *AlternateResolver {
+	scores := make(map[string]int, len(DefaultAlternateScores))
+	for k, v := range DefaultAlternateScores {
+		scores[k] = v
 	}
+	return &AlternateResolver{ctx: ctx, scores: scores}
 }
 
-func (r *AlternateResolver) ResolveFile(basePath string) (string, error) {
-	dir := filepath.Dir(basePath)
-	base := filepath.Base(basePath)
+// SetScores overrides the weight of individual predicate keys
+func (r *AlternateResolver) SetScores(scores map[string]int) error {
+	for key, score := range scores {
+		if _, ok := DefaultAlternateScores[key]; !ok {
+			return fmt.Errorf("unknown alternate score key %q", key)
+		}
+		r.scores[key] = score
+	}
+	return nil
+}
 
-	entries, err := os.ReadDir(dir)
+func (r *AlternateResolver) ResolveFile(basePath string) (string, error) {
+	explanation, err := r.Explain(basePath)
 	if err != nil {
 		if _, statErr := os.Stat(basePath); statErr == nil {
 			return basePath, nil
@@ -41,94 +196,146 @@ func (r *AlternateResolver) ResolveFile(basePath string) (string, error) {
 		return "", err
 	}
 
-	var candidates []AlternateMatch
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+	for _, c := range explanation.Candidates {
+		if c.Err != nil {
+			return "", fmt.Errorf("%s: %w", c.Path, c.Err)
 		}
+	}
 
-		name := entry.Name()
+	if explanation.Winner == nil {
+		return basePath, nil
+	}
+	return explanation.Winner.Path, nil
+}
 
-		if name == base {
-			candidates = append(candidates, AlternateMatch{
-				Path:  filepath.Join(dir, name),
-				Score: 0,
-			})
-			continue
+// Explain scores every variant of basePath. Ties are broken by file name so
+// the result is stable, and reported in Ties.
+func (r *AlternateResolver) Explain(basePath string) (*AlternateExplanation, error) {
+	alternates, err := r.GetAlternatesForFile(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &AlternateExplanation{BasePath: basePath}
+
+	for _, candidate := range alternates {
+		candidate.Eligible = true
+		if len(candidate.Suffixes) > 0 {
+			candidate.Score, candidate.Matched, candidate.Unmatched, candidate.Err = r.calculateScore(candidate.Suffixes)
+			candidate.Eligible = candidate.Err == nil && len(candidate.Unmatched) == 0
 		}
+		explanation.Candidates = append(explanation.Candidates, candidate)
+	}
 
-		if !strings.HasPrefix(name, base+"##") {
-			continue
+	sort.SliceStable(explanation.Candidates, func(i, j int) bool {
+		a, b := explanation.Candidates[i], explanation.Candidates[j]
+		if a.Eligible != b.Eligible {
+			return a.Eligible
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Path < b.Path
+	})
+
+	if len(explanation.Candidates) > 0 && explanation.Candidates[0].Eligible {
+		explanation.Winner = &explanation.Candidates[0]
+		for _, c := range explanation.Candidates[1:] {
+			if c.Eligible && c.Score == explanation.Winner.Score {
+				explanation.Ties = append(explanation.Ties, c)
+			}
 		}
+	}
+
+	return explanation, nil
+}
 
-		suffix := strings.TrimPrefix(name, base+"##")
-		suffixes := strings.Split(suffix, ",")
+// calculateScore sums the weights of the predicates that hold. Any unmatched
+// predicate disqualifies the file and an invalid one is an error.
+func (r *AlternateResolver) calculateScore(suffixes []string) (score int, matched, unmatched []string, err error) {
+	for _, suffix := range suffixes {
+		p, err := ParsePredicate(suffix)
+		if err != nil {
+			return 0, nil, nil, err
+		}
 
-		score, matches := r.calculateScore(suffixes)
-		if matches {
-			candidates = append(candidates, AlternateMatch{
-				Path:     filepath.Join(dir, name),
-				Score:    score,
-				Suffixes: suffixes,
-			})
+		if r.Matches(p) {
+			score += r.scores[p.Key]
+			matched = append(matched, suffix)
+		} else {
+			unmatched = append(unmatched, suffix)
 		}
 	}
 
-	if len(candidates) == 0 {
-		return basePath, nil
+	if len(unmatched) > 0 {
+		score = 0
 	}
+	return score, matched, unmatched, nil
+}
 
-	sort.Slice(candidates, func(i, j int) bool {
-		return candidates[i].Score > candidates[j].Score
-	})
+// Matches reports whether a predicate holds for the resolver's machine
+func (r *AlternateResolver) Matches(p AlternatePredicate) bool {
+	var matched bool
+
+	switch p.Key {
+	case "default":
+		return true
+	case "hostname":
+		matched = strings.EqualFold(p.Value, r.ctx.Hostname)
+	case "user":
+		matched = strings.EqualFold(p.Value, r.ctx.User)
+	case "profile":
+		matched = strings.EqualFold(p.Value, r.ctx.Profile)
+	case "distro":
+		matched = strings.EqualFold(p.Value, r.ctx.Distro)
+	case "arch":
+		matched = strings.EqualFold(p.Value, r.ctx.Arch)
+	case "os":
+		matched = strings.EqualFold(p.Value, r.ctx.OS)
+	case "feature":
+		matched = containsFold(r.ctx.Features, p.Value)
+	case "env":
+		matched = containsFold(r.ctx.Environments, p.Value)
+	case "setting":
+		value, ok := LookupSetting(r.ctx.Settings, p.Setting)
+		matched = ok && strings.EqualFold(fmt.Sprint(value), p.Value)
+	}
 
-	return candidates[0].Path, nil
+	return matched != p.Negated
 }
 
-func (r *AlternateResolver) calculateScore(suffixes []string) (int, bool) {
-	score := 0
-
-	for _, suffix := range suffixes {
-		parts := strings.SplitN(suffix, ".", 2)
-		if len(parts) != 2 {
-			continue
-		}
+// LookupSetting finds a setting by its flat key, such as "theme.variant",
+// or by walking nested maps
+func LookupSetting(settings map[string]any, key string) (any, bool) {
+	if v, ok := settings[key]; ok {
+		return v, true
+	}
 
-		key := strings.ToLower(parts[0])
-		value := strings.ToLower(parts[1])
+	head, rest, ok := strings.Cut(key, ".")
+	if !ok {
+		return nil, false
+	}
 
-		switch key {
-		case "hostname":
-			if value == strings.ToLower(r.hostname) {
-				score += 1000
-			} else {
-				return 0, false
-			}
-		case "profile":
-			if value == strings.ToLower(r.profile) {
-				score += 100
-			} else {
-				return 0, false
-			}
-		case "distro":
-			if value == strings.ToLower(r.distro) {
-				score += 50
-			} else {
-				return 0, false
-			}
-		case "os":
-			if value == strings.ToLower(r.os) {
-				score += 10
-			} else {
-				return 0, false
-			}
-		case "default":
-			score += 1
+	switch nested := settings[head].(type) {
+	case map[string]any:
+		return LookupSetting(nested, rest)
+	case map[any]any:
+		converted := make(map[string]any, len(nested))
+		for k, v := range nested {
+			converted[fmt.Sprint(k)] = v
 		}
+		return LookupSetting(converted, rest)
 	}
+	return nil, false
+}
 
-	return score, true
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *AlternateResolver) ResolveDirectory(dir string) (map[string]string, error) {
@@ -183,13 +390,13 @@ func (r *AlternateResolver) GetAlternatesForFile(basePath string) ([]AlternateMa
 
 		name := entry.Name()
 
-		if !strings.HasPrefix(name, base+"##") && name != base {
+		if !strings.HasPrefix(name, base+AlternateSeparator) && name != base {
 			continue
 		}
 
 		var suffixes []string
-		if strings.Contains(name, "##") {
-			suffix := strings.TrimPrefix(name, base+"##")
+		if name != base {
+			suffix := strings.TrimPrefix(name, base+AlternateSeparator)
 			suffixes = strings.Split(suffix, ",")
 		}
 
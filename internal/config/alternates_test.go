@@ -0,0 +1,239 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testAlternateContext = AlternateContext{
+	OS:           "linux",
+	Distro:       "arch",
+	Arch:         "amd64",
+	Profile:      "desktop",
+	Hostname:     "workstation",
+	User:         "me",
+	Features:     []string{"gaming"},
+	Settings:     map[string]any{"theme": "dark", "editor": map[string]any{"name": "nvim"}},
+	Environments: []string{"wsl"},
+}
+
+func TestParsePredicate(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    AlternatePredicate
+		wantErr bool
+	}{
+		{raw: "os.linux", want: AlternatePredicate{Raw: "os.linux", Key: "os", Value: "linux"}},
+		{raw: "!feature.gaming", want: AlternatePredicate{Raw: "!feature.gaming", Key: "feature", Value: "gaming", Negated: true}},
+		{raw: "OS.Linux", want: AlternatePredicate{Raw: "OS.Linux", Key: "os", Value: "Linux"}},
+		{raw: "wsl", want: AlternatePredicate{Raw: "wsl", Key: "env", Value: "wsl"}},
+		{raw: "!container", want: AlternatePredicate{Raw: "!container", Key: "env", Value: "container", Negated: true}},
+		{raw: "env.vm", want: AlternatePredicate{Raw: "env.vm", Key: "env", Value: "vm"}},
+		{raw: "setting.theme=dark", want: AlternatePredicate{Raw: "setting.theme=dark", Key: "setting", Setting: "theme", Value: "dark"}},
+		{raw: "setting.editor.name=", want: AlternatePredicate{Raw: "setting.editor.name=", Key: "setting", Setting: "editor.name"}},
+		{raw: "default", want: AlternatePredicate{Raw: "default", Key: "default"}},
+		{raw: "!default", wantErr: true},
+		{raw: "default.x", wantErr: true},
+		{raw: "env.cloud", wantErr: true},
+		{raw: "setting.theme", wantErr: true},
+		{raw: "setting.=dark", wantErr: true},
+		{raw: "os.", wantErr: true},
+		{raw: "color.blue", wantErr: true},
+		{raw: "linux", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := ParsePredicate(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePredicate(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParsePredicate(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlternateResolverMatches(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{raw: "os.linux", want: true},
+		{raw: "os.LINUX", want: true},
+		{raw: "os.darwin", want: false},
+		{raw: "!os.darwin", want: true},
+		{raw: "!os.linux", want: false},
+		{raw: "hostname.workstation", want: true},
+		{raw: "user.me", want: true},
+		{raw: "profile.desktop", want: true},
+		{raw: "distro.ubuntu", want: false},
+		{raw: "arch.amd64", want: true},
+		{raw: "feature.gaming", want: true},
+		{raw: "!feature.gaming", want: false},
+		{raw: "feature.work", want: false},
+		{raw: "!feature.work", want: true},
+		{raw: "wsl", want: true},
+		{raw: "!wsl", want: false},
+		{raw: "container", want: false},
+		{raw: "setting.theme=dark", want: true},
+		{raw: "setting.theme=light", want: false},
+		{raw: "!setting.theme=light", want: true},
+		{raw: "setting.editor.name=nvim", want: true},
+		{raw: "setting.missing=", want: false},
+		{raw: "!setting.missing=x", want: true},
+		{raw: "default", want: true},
+	}
+
+	r := NewAlternateResolver(testAlternateContext)
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			p, err := ParsePredicate(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.Matches(p); got != tt.want {
+				t.Errorf("Matches(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlternateResolverResolveFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		scores  map[string]int
+		want    string
+		wantErr bool
+	}{
+		{name: "plain file", files: []string{"rc"}, want: "rc"},
+		{name: "only the base", files: []string{"rc", "rc##os.darwin"}, want: "rc"},
+		{name: "matching variant beats the base", files: []string{"rc", "rc##os.linux"}, want: "rc##os.linux"},
+		{name: "default beats the base", files: []string{"rc", "rc##default"}, want: "rc##default"},
+		{name: "more specific key wins", files: []string{"rc##os.linux", "rc##hostname.workstation", "rc##feature.gaming"}, want: "rc##hostname.workstation"},
+		{name: "predicates add up", files: []string{"rc##distro.arch", "rc##os.linux,arch.amd64,env.wsl"}, want: "rc##os.linux,arch.amd64,env.wsl"},
+		{name: "one unmatched predicate disqualifies", files: []string{"rc##os.linux", "rc##hostname.workstation,os.darwin"}, want: "rc##os.linux"},
+		{name: "negation matches other machines", files: []string{"rc##default", "rc##!feature.work"}, want: "rc##!feature.work"},
+		{name: "negation excludes this machine", files: []string{"rc##os.linux", "rc##hostname.workstation,!feature.gaming"}, want: "rc##os.linux"},
+		{name: "ties go to the first name", files: []string{"rc##os.linux", "rc##env.wsl,default"}, scores: map[string]int{"env": 9}, want: "rc##env.wsl,default"},
+		{name: "custom scores", files: []string{"rc##os.linux", "rc##user.me"}, scores: map[string]int{"os": 600}, want: "rc##os.linux"},
+		{name: "no eligible variant and no base", files: []string{"rc##os.darwin"}, want: "rc"},
+		{name: "invalid suffix", files: []string{"rc##os.linux", "rc##bogus.x"}, wantErr: true},
+		{name: "other files are ignored", files: []string{"rc", "rc.bak##os.linux", "rcfile##os.linux"}, want: "rc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r := NewAlternateResolver(testAlternateContext)
+			if err := r.SetScores(tt.scores); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := r.ResolveFile(filepath.Join(dir, "rc"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != filepath.Join(dir, tt.want) {
+				t.Errorf("ResolveFile() = %s, want %s", filepath.Base(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestAlternateResolverExplainTies(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"rc##os.linux", "rc##arch.amd64", "rc##os.darwin"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewAlternateResolver(testAlternateContext)
+	if err := r.SetScores(map[string]int{"arch": 10}); err != nil {
+		t.Fatal(err)
+	}
+	explanation, err := r.Explain(filepath.Join(dir, "rc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if explanation.Winner == nil || filepath.Base(explanation.Winner.Path) != "rc##arch.amd64" {
+		t.Fatalf("Winner = %+v, want rc##arch.amd64", explanation.Winner)
+	}
+	if len(explanation.Ties) != 1 || filepath.Base(explanation.Ties[0].Path) != "rc##os.linux" {
+		t.Errorf("Ties = %+v, want rc##os.linux", explanation.Ties)
+	}
+	last := explanation.Candidates[len(explanation.Candidates)-1]
+	if last.Eligible || last.Score != 0 || len(last.Unmatched) != 1 {
+		t.Errorf("ineligible candidate = %+v, want it last with score 0", last)
+	}
+}
+
+func TestAlternateResolverSetScores(t *testing.T) {
+	tests := []struct {
+		name    string
+		scores  map[string]int
+		wantErr bool
+	}{
+		{name: "known keys", scores: map[string]int{"os": 5, "default": 0}},
+		{name: "unknown key", scores: map[string]int{"colour": 5}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewAlternateResolver(AlternateContext{})
+			if err := r.SetScores(tt.scores); (err != nil) != tt.wantErr {
+				t.Errorf("SetScores() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	r := NewAlternateResolver(AlternateContext{})
+	r.SetScores(map[string]int{"os": 5})
+	if DefaultAlternateScores["os"] != 10 {
+		t.Error("SetScores changed the default scores")
+	}
+}
+
+func TestAlternateContextOverride(t *testing.T) {
+	tests := []struct {
+		key, value string
+		check      func(AlternateContext) bool
+		wantErr    bool
+	}{
+		{key: "hostname", value: "laptop", check: func(c AlternateContext) bool { return c.Hostname == "laptop" }},
+		{key: "OS", value: "darwin", check: func(c AlternateContext) bool { return c.OS == "darwin" }},
+		{key: "feature", value: "work", check: func(c AlternateContext) bool { return len(c.Features) == 2 }},
+		{key: "feature", value: "GAMING", check: func(c AlternateContext) bool { return len(c.Features) == 1 }},
+		{key: "env", value: "vm", check: func(c AlternateContext) bool { return len(c.Environments) == 2 }},
+		{key: "setting.font.size", value: "12", check: func(c AlternateContext) bool { return c.Settings["font.size"] == "12" }},
+		{key: "colour", value: "blue", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			ctx := testAlternateContext
+			ctx.Features = append([]string(nil), ctx.Features...)
+			ctx.Environments = append([]string(nil), ctx.Environments...)
+			ctx.Settings = map[string]any{}
+
+			err := ctx.Override(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Override() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !tt.check(ctx) {
+				t.Errorf("Override(%q, %q) gave %+v", tt.key, tt.value, ctx)
+			}
+		})
+	}
+}
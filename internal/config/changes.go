@@ -0,0 +1,78 @@
+package config
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChangeSet classifies changed repo files by how they affect a resolved machine
+type ChangeSet struct {
+	Configs  map[string][]string // config name -> changed files within it
+	Profiles []string
+	Packages []string
+	Machine  []string
+	Scripts  []string
+	Repo     []string // repo-wide files such as config.yaml
+	Other    []string // changes that don't affect this machine
+}
+
+// ClassifyChanges sorts repo-relative paths into the parts of resolved they touch
+func ClassifyChanges(files []string, resolved *ResolvedConfig, machineName string) *ChangeSet {
+	cs := &ChangeSet{Configs: make(map[string][]string)}
+
+	scripts := make(map[string]bool)
+	for _, list := range [][]string{
+		resolved.Scripts.PreInstall,
+		resolved.Scripts.PostInstall,
+		resolved.Scripts.PreUpdate,
+		resolved.Scripts.PostUpdate,
+	} {
+		for _, script := range list {
+			if !filepath.IsAbs(script) {
+				scripts[ScriptRepoPath(script)] = true
+			}
+		}
+	}
+
+	for _, file := range files {
+		file = filepath.ToSlash(file)
+		parts := strings.SplitN(file, "/", 3)
+		stem := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(file), ".yaml"), ".yml")
+
+		switch {
+		case parts[0] == "configs" && len(parts) == 3 && contains(resolved.Configs, parts[1]):
+			cs.Configs[parts[1]] = append(cs.Configs[parts[1]], parts[2])
+		case parts[0] == "profiles" && len(parts) == 2 && contains(resolved.Profiles, stem):
+			cs.Profiles = append(cs.Profiles, file)
+		case parts[0] == "packages" && len(parts) == 2 && contains(resolved.PackageGroups, stem):
+			cs.Packages = append(cs.Packages, file)
+		case parts[0] == "machines" && len(parts) == 2 && stem == machineName:
+			cs.Machine = append(cs.Machine, file)
+		case parts[0] == "scripts" && scripts[file]:
+			cs.Scripts = append(cs.Scripts, file)
+		case file == "config.yaml":
+			cs.Repo = append(cs.Repo, file)
+		default:
+			cs.Other = append(cs.Other, file)
+		}
+	}
+
+	return cs
+}
+
+// ConfigNames returns the names of changed configs in sorted order
+func (c *ChangeSet) ConfigNames() []string {
+	names := make([]string, 0, len(c.Configs))
+	for name := range c.Configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsRelevant returns true if any change affects the machine
+func (c *ChangeSet) IsRelevant() bool {
+	return len(c.Configs) > 0 || len(c.Profiles) > 0 || len(c.Packages) > 0 ||
+		len(c.Machine) > 0 || len(c.Scripts) > 0 || len(c.Repo) > 0
+}
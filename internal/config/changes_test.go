@@ -0,0 +1,66 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/arthur404dev/dotts/pkg/schema"
+)
+
+func TestClassifyChanges(t *testing.T) {
+	resolved := &ResolvedConfig{
+		Configs:       []string{"shell"},
+		Profiles:      []string{"base"},
+		PackageGroups: []string{"cli"},
+		Scripts: schema.ProfileScripts{
+			PreInstall: []string{"setup.sh"},
+			PostUpdate: []string{"scripts/post/reload.sh", "/usr/local/bin/outside.sh"},
+		},
+	}
+
+	files := []string{
+		"configs/shell/.zshrc",
+		"configs/editor/init.lua",
+		"profiles/base.yaml",
+		"profiles/work.yaml",
+		"packages/cli.yaml",
+		"machines/laptop.yaml",
+		"machines/desktop.yaml",
+		"scripts/setup.sh",
+		"scripts/post/reload.sh",
+		"scripts/unused.sh",
+		"config.yaml",
+		"README.md",
+	}
+
+	got := ClassifyChanges(files, resolved, "laptop")
+	want := &ChangeSet{
+		Configs:  map[string][]string{"shell": {".zshrc"}},
+		Profiles: []string{"profiles/base.yaml"},
+		Packages: []string{"packages/cli.yaml"},
+		Machine:  []string{"machines/laptop.yaml"},
+		Scripts:  []string{"scripts/setup.sh", "scripts/post/reload.sh"},
+		Repo:     []string{"config.yaml"},
+		Other:    []string{"configs/editor/init.lua", "profiles/work.yaml", "machines/desktop.yaml", "scripts/unused.sh", "README.md"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ClassifyChanges() = %+v, want %+v", got, want)
+	}
+}
+
+func TestScriptRepoPath(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "setup.sh", want: "scripts/setup.sh"},
+		{in: "scripts/setup.sh", want: "scripts/setup.sh"},
+		{in: "./post/reload.sh", want: "scripts/post/reload.sh"},
+		{in: "scripts/../scripts/a.sh", want: "scripts/a.sh"},
+	}
+
+	for _, tt := range tests {
+		if got := ScriptRepoPath(tt.in); got != tt.want {
+			t.Errorf("ScriptRepoPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile lists paths that are never linked. It may live at the repo root,
+// applying to every config, and in each configs/<name>/ directory.
+const IgnoreFile = ".dottsignore"
+
+// DefaultIgnores are applied before any .dottsignore
+var DefaultIgnores = []string{
+	IgnoreFile,
+	"/" + MetaFile,
+	LinkDirMarker,
+	".git/",
+	".DS_Store",
+	"Thumbs.db",
+	"/README",
+	"/README.*",
+	"*.swp",
+	"*.swo",
+	"*~",
+	".#*",
+}
+
+// IgnoreRule is a single gitignore-style pattern, optionally limited to
+// machines by alternate predicates: "Library/##!os.darwin"
+type IgnoreRule struct {
+	Pattern    string
+	Negated    bool // re-includes paths matched by earlier rules
+	DirOnly    bool
+	Predicates []AlternatePredicate
+	Source     string // file and line the rule came from, or "default"
+
+	re *regexp.Regexp
+}
+
+func (r IgnoreRule) String() string {
+	return r.Source + ": " + r.raw()
+}
+
+func (r IgnoreRule) raw() string {
+	s := r.Pattern
+	if r.Negated {
+		s = "!" + s
+	}
+	if r.DirOnly {
+		s += "/"
+	}
+	if len(r.Predicates) > 0 {
+		preds := make([]string, len(r.Predicates))
+		for i, p := range r.Predicates {
+			preds[i] = p.Raw
+		}
+		s += AlternateSeparator + strings.Join(preds, ",")
+	}
+	return s
+}
+
+// ParseIgnoreRule parses one line of an ignore file. ok is false for blank
+// lines and comments.
+func ParseIgnoreRule(line, source string) (rule IgnoreRule, ok bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false, nil
+	}
+
+	rule.Source = source
+	pattern, suffix, hasPreds := SplitAlternate(line)
+	if hasPreds {
+		for _, raw := range strings.Split(suffix, ",") {
+			p, err := ParsePredicate(raw)
+			if err != nil {
+				return rule, false, fmt.Errorf("%s: %w", source, err)
+			}
+			rule.Predicates = append(rule.Predicates, p)
+		}
+	}
+
+	pattern = strings.TrimSpace(pattern)
+	if strings.HasPrefix(pattern, "!") {
+		rule.Negated = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.DirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return rule, false, fmt.Errorf("%s: empty pattern", source)
+	}
+	rule.Pattern = pattern
+
+	rule.re, err = compileIgnore(pattern)
+	if err != nil {
+		return rule, false, fmt.Errorf("%s: invalid pattern %q: %w", source, pattern, err)
+	}
+	return rule, true, nil
+}
+
+// compileIgnore turns a gitignore-style glob into a regexp. Patterns without
+// a slash match a name at any depth; others are anchored to the config root.
+func compileIgnore(pattern string) (*regexp.Regexp, error) {
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	return regexp.Compile(b.String())
+}
+
+// Ignorer decides which paths of a config are never linked
+type Ignorer struct {
+	rules    []IgnoreRule
+	resolver *AlternateResolver
+}
+
+// LoadIgnore reads the default rules, then <repo>/.dottsignore, then
+// <configDir>/.dottsignore; later rules win. Rule predicates are matched with
+// resolver; nil matches them against an empty machine context.
+func LoadIgnore(repoRoot, configDir string, resolver *AlternateResolver) (*Ignorer, error) {
+	if resolver == nil {
+		resolver = NewAlternateResolver(AlternateContext{})
+	}
+
+	ig := &Ignorer{resolver: resolver}
+	for _, line := range DefaultIgnores {
+		rule, _, err := ParseIgnoreRule(line, "default")
+		if err != nil {
+			return nil, err
+		}
+		ig.rules = append(ig.rules, rule)
+	}
+
+	for _, dir := range []string{repoRoot, configDir} {
+		if err := ig.load(filepath.Join(dir, IgnoreFile), repoRoot); err != nil {
+			return nil, err
+		}
+	}
+	return ig, nil
+}
+
+func (ig *Ignorer) load(path, repoRoot string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	name := path
+	if rel, err := filepath.Rel(repoRoot, path); err == nil {
+		name = rel
+	}
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		rule, ok, err := ParseIgnoreRule(scanner.Text(), fmt.Sprintf("%s:%d", name, line))
+		if err != nil {
+			return err
+		}
+		if ok {
+			ig.rules = append(ig.rules, rule)
+		}
+	}
+	return scanner.Err()
+}
+
+// Rules returns every loaded rule in evaluation order
+func (ig *Ignorer) Rules() []IgnoreRule {
+	return ig.rules
+}
+
+// Match reports whether rel (relative to the config directory) is ignored,
+// and by which rule. The last matching rule whose predicates hold wins.
+func (ig *Ignorer) Match(rel string, isDir bool) (*IgnoreRule, bool) {
+	if ig == nil {
+		return nil, false
+	}
+	rel = filepath.ToSlash(rel)
+
+	var matched *IgnoreRule
+	for i := range ig.rules {
+		rule := &ig.rules[i]
+		if rule.DirOnly && !isDir {
+			continue
+		}
+		if !rule.re.MatchString(rel) || !ig.applies(rule) {
+			continue
+		}
+		matched = rule
+	}
+
+	if matched == nil || matched.Negated {
+		return nil, false
+	}
+	return matched, true
+}
+
+func (ig *Ignorer) applies(rule *IgnoreRule) bool {
+	for _, p := range rule.Predicates {
+		if !ig.resolver.Matches(p) {
+			return false
+		}
+	}
+	return true
+}
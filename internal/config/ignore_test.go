@@ -0,0 +1,190 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileIgnore(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{pattern: "*.swp", path: "a.swp", want: true},
+		{pattern: "*.swp", path: "deep/dir/a.swp", want: true},
+		{pattern: "*.swp", path: "a.swp.bak", want: false},
+		{pattern: "*.swp", path: "dir.swp/a", want: false},
+		{pattern: "cache", path: "cache", want: true},
+		{pattern: "cache", path: "x/cache", want: true},
+		{pattern: "cache", path: "cachedir", want: false},
+		{pattern: "/README", path: "README", want: true},
+		{pattern: "/README", path: "docs/README", want: false},
+		{pattern: "docs/README", path: "docs/README", want: true},
+		{pattern: "docs/README", path: "x/docs/README", want: false},
+		{pattern: "docs/*.md", path: "docs/a.md", want: true},
+		{pattern: "docs/*.md", path: "docs/sub/a.md", want: false},
+		{pattern: "a/**/b", path: "a/b", want: true},
+		{pattern: "a/**/b", path: "a/x/y/b", want: true},
+		{pattern: "a/**/b", path: "c/a/b", want: false},
+		{pattern: "**/logs", path: "logs", want: true},
+		{pattern: "**/logs", path: "x/y/logs", want: true},
+		{pattern: "logs/**", path: "logs/a/b", want: true},
+		{pattern: "logs/**", path: "logs", want: false},
+		{pattern: "file?.txt", path: "file1.txt", want: true},
+		{pattern: "file?.txt", path: "file12.txt", want: false},
+		{pattern: "file?", path: "file/", want: false},
+		{pattern: "[ab].conf", path: "a.conf", want: true},
+		{pattern: "[ab].conf", path: "c.conf", want: false},
+		{pattern: "[!ab].conf", path: "c.conf", want: true},
+		{pattern: "[!ab].conf", path: "a.conf", want: false},
+		{pattern: "[0-9]*.log", path: "1-run.log", want: true},
+		{pattern: "a+b(c).txt", path: "a+b(c).txt", want: true},
+		{pattern: "a.b", path: "axb", want: false},
+		{pattern: ".#*", path: ".#lock", want: true},
+		{pattern: "*~", path: "notes~", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			re, err := compileIgnore(tt.pattern)
+			if err != nil {
+				t.Fatalf("compileIgnore(%q) error = %v", tt.pattern, err)
+			}
+			if got := re.MatchString(tt.path); got != tt.want {
+				t.Errorf("compileIgnore(%q) matches %q = %v, want %v (regexp %s)", tt.pattern, tt.path, got, tt.want, re)
+			}
+		})
+	}
+}
+
+func TestCompileIgnoreErrors(t *testing.T) {
+	for _, pattern := range []string{"[abc", "a[", "[z-a]"} {
+		if _, err := compileIgnore(pattern); err == nil {
+			t.Errorf("compileIgnore(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestParseIgnoreRule(t *testing.T) {
+	tests := []struct {
+		line     string
+		ok       bool
+		wantErr  bool
+		pattern  string
+		negated  bool
+		dirOnly  bool
+		preds    int
+		rendered string
+	}{
+		{line: "", ok: false},
+		{line: "   ", ok: false},
+		{line: "# comment", ok: false},
+		{line: "*.log", ok: true, pattern: "*.log", rendered: "*.log"},
+		{line: "  build/  ", ok: true, pattern: "build", dirOnly: true, rendered: "build/"},
+		{line: "!keep.log", ok: true, pattern: "keep.log", negated: true, rendered: "!keep.log"},
+		{line: "Library/##!os.darwin", ok: true, pattern: "Library", dirOnly: true, preds: 1, rendered: "Library/##!os.darwin"},
+		{line: "*.ps1##os.linux,!wsl", ok: true, pattern: "*.ps1", preds: 2, rendered: "*.ps1##os.linux,!wsl"},
+		{line: "/", wantErr: true},
+		{line: "!", wantErr: true},
+		{line: "x##bogus.y", wantErr: true},
+		{line: "[oops", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			rule, ok, err := ParseIgnoreRule(tt.line, "test:1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseIgnoreRule(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if ok != tt.ok {
+				t.Fatalf("ParseIgnoreRule(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if rule.Pattern != tt.pattern || rule.Negated != tt.negated || rule.DirOnly != tt.dirOnly || len(rule.Predicates) != tt.preds {
+				t.Errorf("ParseIgnoreRule(%q) = %+v", tt.line, rule)
+			}
+			if got := rule.String(); got != "test:1: "+tt.rendered {
+				t.Errorf("String() = %q, want %q", got, "test:1: "+tt.rendered)
+			}
+		})
+	}
+}
+
+func TestIgnorerMatch(t *testing.T) {
+	repo := t.TempDir()
+	configDir := filepath.Join(repo, "configs", "shell")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	repoRules := "*.log\nbuild/\nLibrary/##!os.darwin\n"
+	configRules := "# config rules\n!keep.log\nREADME.md\n"
+	if err := os.WriteFile(filepath.Join(repo, IgnoreFile), []byte(repoRules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, IgnoreFile), []byte(configRules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		isDir  bool
+		os     string
+		want   bool
+		source string
+	}{
+		{path: "a.log", want: true, source: ".dottsignore:1"},
+		{path: "keep.log", want: false},
+		{path: "sub/keep.log", want: false},
+		{path: "build", isDir: true, want: true, source: ".dottsignore:2"},
+		{path: "build", isDir: false, want: false},
+		{path: "Library", isDir: true, os: "linux", want: true, source: ".dottsignore:3"},
+		{path: "Library", isDir: true, os: "darwin", want: false},
+		{path: "README.md", want: true, source: "configs/shell/.dottsignore:3"},
+		{path: "docs/README.md", want: true, source: "configs/shell/.dottsignore:3"},
+		{path: "README", want: true, source: "default"},
+		{path: "docs/README", want: false},
+		{path: ".dotts.yaml", want: true, source: "default"},
+		{path: "sub/.dotts.yaml", want: false},
+		{path: ".git", isDir: true, want: true, source: "default"},
+		{path: ".zshrc", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.os, func(t *testing.T) {
+			ig, err := LoadIgnore(repo, configDir, NewAlternateResolver(AlternateContext{OS: tt.os}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rule, got := ig.Match(tt.path, tt.isDir)
+			if got != tt.want {
+				t.Fatalf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+			if got && rule.Source != tt.source {
+				t.Errorf("Match(%q) rule from %s, want %s", tt.path, rule.Source, tt.source)
+			}
+		})
+	}
+}
+
+func TestLoadIgnoreInvalidRule(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, IgnoreFile), []byte("ok\n[bad\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadIgnore(repo, filepath.Join(repo, "configs", "x"), nil)
+	if err == nil {
+		t.Fatal("LoadIgnore() succeeded, want an error")
+	}
+	if want := ".dottsignore:2"; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("LoadIgnore() error = %v, want it to name %s", err, want)
+	}
+}
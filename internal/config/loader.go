@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -103,6 +104,20 @@ func (l *Loader) ListPackageManifests() ([]string, error) {
 }
 
 func (l *Loader) ListConfigs() ([]string, error) {
+	infos, err := l.ListConfigInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]string, len(infos))
+	for i, info := range infos {
+		configs[i] = info.Name
+	}
+	return configs, nil
+}
+
+// ListConfigInfo lists config directories with their .dotts.yaml metadata
+func (l *Loader) ListConfigInfo() ([]ConfigInfo, error) {
 	configsPath := filepath.Join(l.basePath, "configs")
 
 	entries, err := os.ReadDir(configsPath)
@@ -110,22 +125,48 @@ func (l *Loader) ListConfigs() ([]string, error) {
 		return nil, fmt.Errorf("failed to read configs directory: %w", err)
 	}
 
-	var configs []string
+	var configs []ConfigInfo
 	for _, entry := range entries {
-		if entry.IsDir() {
-			configs = append(configs, entry.Name())
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
 		}
+
+		meta, err := l.LoadConfigMeta(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, ConfigInfo{Name: entry.Name(), Meta: meta})
 	}
 
 	return configs, nil
 }
 
+// LoadConfigMeta reads configs/<name>/.dotts.yaml
+func (l *Loader) LoadConfigMeta(name string) (*schema.ConfigMeta, error) {
+	return LoadConfigMeta(l.GetConfigPath(name))
+}
+
 func (l *Loader) GetConfigPath(name string) string {
 	return filepath.Join(l.basePath, "configs", name)
 }
 
+// GetScriptPath resolves a script named in a profile. Names are relative to
+// scripts/ and may repeat that prefix; absolute paths are used as they are.
 func (l *Loader) GetScriptPath(name string) string {
-	return filepath.Join(l.basePath, "scripts", name)
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(l.basePath, filepath.FromSlash(ScriptRepoPath(name)))
+}
+
+// ScriptRepoPath returns the slash-separated repo path of a relative script
+// name, so "setup.sh" and "scripts/setup.sh" both give "scripts/setup.sh"
+func ScriptRepoPath(name string) string {
+	name = path.Clean(filepath.ToSlash(name))
+	if strings.HasPrefix(name, "scripts/") {
+		return name
+	}
+	return path.Join("scripts", name)
 }
 
 func (l *Loader) GetAssetsPath() string {
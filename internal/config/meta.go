@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/arthur404dev/dotts/pkg/schema"
+)
+
+// MetaFile is the optional per-config metadata file, configs/<name>/.dotts.yaml
+const MetaFile = ".dotts.yaml"
+
+// LinkDirMarker placed in a config subdirectory links that directory whole
+// instead of file by file
+const LinkDirMarker = ".dottslinkdir"
+
+// ConfigInfo is a config directory and its metadata
+type ConfigInfo struct {
+	Name string
+	Meta *schema.ConfigMeta
+}
+
+// LoadConfigMeta reads dir/.dotts.yaml; a missing file yields empty metadata
+func LoadConfigMeta(dir string) (*schema.ConfigMeta, error) {
+	meta := &schema.ConfigMeta{}
+
+	data, err := os.ReadFile(filepath.Join(dir, MetaFile))
+	if os.IsNotExist(err) {
+		return meta, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(filepath.Base(dir), MetaFile), err)
+	}
+	return meta, nil
+}
+
+// Unsupported returns why the config does not apply to the resolver's
+// machine, or "" when it does
+func Unsupported(meta *schema.ConfigMeta, resolver *AlternateResolver) string {
+	if len(meta.OS) > 0 && !anyMatches(resolver, "os", meta.OS) {
+		return "only for os " + strings.Join(meta.OS, ", ")
+	}
+	if len(meta.Distro) > 0 && !anyMatches(resolver, "distro", meta.Distro) {
+		return "only for distro " + strings.Join(meta.Distro, ", ")
+	}
+	return ""
+}
+
+func anyMatches(resolver *AlternateResolver, key string, values []string) bool {
+	for _, v := range values {
+		if resolver.Matches(AlternatePredicate{Key: key, Value: v}) {
+			return true
+		}
+	}
+	return false
+}
+
+// MissingBinaries lists required binaries that are not on PATH
+func MissingBinaries(meta *schema.ConfigMeta) []string {
+	var missing []string
+	for _, bin := range meta.Requires.Binaries {
+		if _, err := exec.LookPath(bin); err != nil {
+			missing = append(missing, bin)
+		}
+	}
+	return missing
+}
+
+// TargetRoot resolves the directory a config is linked into. Environment
+// variables are expanded, with $XDG_CONFIG_HOME defaulting to ~/.config.
+func TargetRoot(meta *schema.ConfigMeta) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if meta.Target == "" {
+		return home, nil
+	}
+
+	target := os.Expand(meta.Target, func(name string) string {
+		value := os.Getenv(name)
+		switch {
+		case value != "":
+			return value
+		case name == "HOME":
+			return home
+		case name == "XDG_CONFIG_HOME":
+			return filepath.Join(home, ".config")
+		}
+		return ""
+	})
+
+	switch {
+	case target == "~" || strings.HasPrefix(target, "~/"):
+		target = filepath.Join(home, strings.TrimPrefix(target, "~"))
+	case !filepath.IsAbs(target):
+		target = filepath.Join(home, target)
+	}
+	return filepath.Clean(target), nil
+}
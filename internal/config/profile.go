@@ -0,0 +1,67 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AddConfigToProfile appends configName to the configs list of a profile,
+// keeping the file's comments. Nothing changes when it is already listed.
+func (l *Loader) AddConfigToProfile(profile, configName string) error {
+	path := filepath.Join(l.basePath, "profiles", profile+".yaml")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read profile %s: %w", profile, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse profile %s: %w", profile, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("profile %s is not a mapping", profile)
+	}
+	root := doc.Content[0]
+
+	var configs *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "configs" {
+			configs = root.Content[i+1]
+			break
+		}
+	}
+	if configs == nil {
+		configs = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "configs"}, configs)
+	}
+	if configs.Kind == yaml.ScalarNode && configs.Tag == "!!null" {
+		// "configs:" with nothing under it
+		configs.Kind, configs.Tag, configs.Value = yaml.SequenceNode, "!!seq", ""
+	}
+	if configs.Kind != yaml.SequenceNode {
+		return fmt.Errorf("configs in profile %s is not a list", profile)
+	}
+
+	for _, item := range configs.Content {
+		if item.Value == configName {
+			return nil
+		}
+	}
+	configs.Content = append(configs.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: configName})
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -7,11 +7,14 @@ import (
 )
 
 type ResolvedConfig struct {
-	Configs  []string
-	Packages *schema.PackageManifest
-	Settings map[string]any
-	Features []string
-	Scripts  schema.ProfileScripts
+	Configs       []string
+	Packages      *schema.PackageManifest
+	Settings      map[string]any
+	Features      []string
+	Scripts       schema.ProfileScripts
+	Profiles      []string
+	PackageGroups []string
+	LinkModes     map[string]string // config or config/path -> link mode; later profiles win
 }
 
 type Resolver struct {
@@ -32,6 +35,7 @@ func (r *Resolver) ResolveMachine(machineName string) (*ResolvedConfig, error) {
 		return nil, err
 	}
 
+	r.resolved = make(map[string]*schema.Profile)
 	result := &ResolvedConfig{
 		Configs:  []string{},
 		Packages: &schema.PackageManifest{},
@@ -52,7 +56,22 @@ func (r *Resolver) ResolveMachine(machineName string) (*ResolvedConfig, error) {
 	return result, nil
 }
 
+// Resolve resolves name as a machine, falling back to a profile of the same name.
+func (r *Resolver) Resolve(name string) (*ResolvedConfig, error) {
+	resolved, err := r.ResolveMachine(name)
+	if err == nil {
+		return resolved, nil
+	}
+
+	resolved, err = r.ResolveProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
 func (r *Resolver) ResolveProfile(profileName string) (*ResolvedConfig, error) {
+	r.resolved = make(map[string]*schema.Profile)
 	result := &ResolvedConfig{
 		Configs:  []string{},
 		Packages: &schema.PackageManifest{},
@@ -85,6 +104,8 @@ func (r *Resolver) resolveProfile(name string, result *ResolvedConfig) error {
 		}
 	}
 
+	result.Profiles = append(result.Profiles, name)
+
 	for _, cfg := range profile.Configs {
 		if !contains(result.Configs, cfg) {
 			result.Configs = append(result.Configs, cfg)
@@ -98,6 +119,9 @@ func (r *Resolver) resolveProfile(name string, result *ResolvedConfig) error {
 				return fmt.Errorf("failed to load package group %s: %w", pkgGroup, err)
 			}
 			result.Packages.Merge(pkgManifest)
+			if !contains(result.PackageGroups, pkgGroup) {
+				result.PackageGroups = append(result.PackageGroups, pkgGroup)
+			}
 		}
 	}
 
@@ -107,6 +131,13 @@ func (r *Resolver) resolveProfile(name string, result *ResolvedConfig) error {
 		}
 	}
 
+	for k, v := range profile.LinkModes {
+		if result.LinkModes == nil {
+			result.LinkModes = make(map[string]string)
+		}
+		result.LinkModes[k] = v
+	}
+
 	result.Scripts.PreInstall = append(result.Scripts.PreInstall, profile.Scripts.PreInstall...)
 	result.Scripts.PostInstall = append(result.Scripts.PostInstall, profile.Scripts.PostInstall...)
 	result.Scripts.PreUpdate = append(result.Scripts.PreUpdate, profile.Scripts.PreUpdate...)
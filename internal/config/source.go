@@ -92,6 +92,29 @@ func (s *Source) Pull() error {
 	return nil
 }
 
+// FastForward moves the current branch to commit, which must be ahead of it.
+// Unlike Pull it never goes past commit, even when the upstream has moved on.
+func (s *Source) FastForward(commit string) error {
+	if s.IsLocal {
+		return nil
+	}
+
+	if s.Path == "" {
+		return fmt.Errorf("source path not set")
+	}
+
+	cmd := exec.Command("git", "merge", "--ff-only", "--quiet", commit)
+	cmd.Dir = s.Path
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to fast-forward to %s: %w", commit, err)
+	}
+
+	return nil
+}
+
 func (s *Source) GetCurrentCommit() (string, error) {
 	if s.Path == "" {
 		return "", fmt.Errorf("source path not set")
@@ -108,6 +131,114 @@ func (s *Source) GetCurrentCommit() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// Fetch updates remote-tracking refs without touching the working tree
+func (s *Source) Fetch() error {
+	if s.IsLocal {
+		return nil
+	}
+
+	if s.Path == "" {
+		return fmt.Errorf("source path not set")
+	}
+
+	cmd := exec.Command("git", "fetch", "--quiet")
+	cmd.Dir = s.Path
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git fetch failed: %w", err)
+	}
+
+	return nil
+}
+
+// GetUpstreamCommit returns the commit the current branch would fast-forward to.
+// Local sources have no upstream and return the current commit.
+func (s *Source) GetUpstreamCommit() (string, error) {
+	if s.IsLocal {
+		return s.GetCurrentCommit()
+	}
+
+	if s.Path == "" {
+		return "", fmt.Errorf("source path not set")
+	}
+
+	cmd := exec.Command("git", "rev-parse", "--short", "@{upstream}")
+	cmd.Dir = s.Path
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get upstream commit: %w", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ChangedFiles lists repo-relative paths that differ between two commits
+func (s *Source) ChangedFiles(from, to string) ([]string, error) {
+	if s.Path == "" {
+		return nil, fmt.Errorf("source path not set")
+	}
+
+	if from == to {
+		return nil, nil
+	}
+
+	cmd := exec.Command("git", "diff", "--name-only", from, to)
+	cmd.Dir = s.Path
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s..%s: %w", from, to, err)
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+
+	return files, nil
+}
+
+// Checkout checks commit out into a new temporary worktree of the repo,
+// leaving the working tree in place. RemoveCheckout deletes it again.
+func (s *Source) Checkout(commit string) (string, error) {
+	if s.Path == "" {
+		return "", fmt.Errorf("source path not set")
+	}
+
+	dir, err := os.MkdirTemp("", "dotts-checkout-")
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("git", "worktree", "add", "--quiet", "--detach", dir, commit)
+	cmd.Dir = s.Path
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to check out %s: %w", commit, err)
+	}
+
+	return dir, nil
+}
+
+// RemoveCheckout deletes a worktree made by Checkout
+func (s *Source) RemoveCheckout(dir string) error {
+	cmd := exec.Command("git", "worktree", "remove", "--force", dir)
+	cmd.Dir = s.Path
+
+	if err := cmd.Run(); err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("failed to remove checkout %s: %w", dir, err)
+	}
+
+	return nil
+}
+
 func (s *Source) Validate() error {
 	path := s.Path
 	if path == "" {
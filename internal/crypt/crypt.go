@@ -0,0 +1,137 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/arthur404dev/dotts/internal/state"
+)
+
+// Ext marks a file in the config repo as age-encrypted
+const Ext = ".age"
+
+// IsEncrypted reports whether a file name carries the encrypted extension
+func IsEncrypted(name string) bool {
+	return strings.HasSuffix(name, Ext) && len(name) > len(Ext)
+}
+
+// DefaultIdentityPath is the local age identity, next to personal.yaml
+func DefaultIdentityPath() string {
+	return filepath.Join(state.GetPaths().ConfigDir, "identity.txt")
+}
+
+// Age encrypts and decrypts files with the age CLI
+type Age struct {
+	identity   string
+	recipients []string
+}
+
+// New uses identity for decryption ("" selects the default) and encrypts
+// to recipients, or to the identity's own public key when there are none
+func New(identity string, recipients []string) *Age {
+	if identity == "" {
+		identity = DefaultIdentityPath()
+	}
+	return &Age{identity: expandHome(identity), recipients: recipients}
+}
+
+// Identity returns the identity file used for decryption
+func (a *Age) Identity() string {
+	return a.identity
+}
+
+// Decrypt returns the plaintext of an encrypted file
+func (a *Age) Decrypt(path string) ([]byte, error) {
+	if err := a.checkIdentity(); err != nil {
+		return nil, err
+	}
+	out, err := run(nil, "age", "--decrypt", "--identity", a.identity, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", filepath.Base(path), err)
+	}
+	return out, nil
+}
+
+// Encrypt returns plaintext encrypted to the configured recipients
+func (a *Age) Encrypt(plaintext []byte) ([]byte, error) {
+	recipients, err := a.Recipients()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"--encrypt", "--armor"}
+	for _, r := range recipients {
+		args = append(args, "--recipient", r)
+	}
+
+	out, err := run(plaintext, "age", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return out, nil
+}
+
+// Recipients returns the configured recipients, or the public key of the
+// identity when none are configured
+func (a *Age) Recipients() ([]string, error) {
+	if len(a.recipients) > 0 {
+		return a.recipients, nil
+	}
+	if err := a.checkIdentity(); err != nil {
+		return nil, err
+	}
+
+	out, err := run(nil, "age-keygen", "-y", a.identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key from %s: %w", a.identity, err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+func (a *Age) checkIdentity() error {
+	if _, err := os.Stat(a.identity); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no age identity at %s, create one with: age-keygen -o %s", a.identity, a.identity)
+		}
+		return err
+	}
+	return nil
+}
+
+// run executes name with stdin and returns stdout. Output is never included
+// in errors since it may be plaintext.
+func run(stdin []byte, name string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return nil, fmt.Errorf("%s is not installed", name)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
@@ -0,0 +1,282 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change
+const contextLines = 3
+
+// maxCells bounds the LCS table; larger inputs are shown as a full replacement
+const maxCells = 4_000_000
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns a unified diff turning a into b, or "" if they are equal.
+func Unified(aName, bName, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	ops := lineOps(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+	for _, h := range hunks(ops) {
+		sb.WriteString(h)
+	}
+	return sb.String()
+}
+
+// Stats counts inserted and deleted lines in a unified diff
+func Stats(unified string) (added, removed int) {
+	inHunk := false
+	for _, line := range strings.Split(unified, "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case !inHunk:
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Merge combines a and b line by line, wrapping every difference in
+// git-style conflict markers labelled aName and bName.
+func Merge(aName, bName, a, b string) string {
+	ops := lineOps(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == opEqual {
+			sb.WriteString(ops[i].line)
+			i++
+			continue
+		}
+
+		var ours, theirs strings.Builder
+		for ; i < len(ops) && ops[i].kind != opEqual; i++ {
+			if ops[i].kind == opDelete {
+				ours.WriteString(withNewline(ops[i].line))
+			} else {
+				theirs.WriteString(withNewline(ops[i].line))
+			}
+		}
+		fmt.Fprintf(&sb, "<<<<<<< %s\n%s=======\n%s>>>>>>> %s\n", aName, ours.String(), theirs.String(), bName)
+	}
+	return sb.String()
+}
+
+// Rebase carries the changes that turn a into b over to base, which has one
+// line for every line of a, such as the template a was rendered from. Lines
+// a and b share keep base's version; lines only in b are passed through
+// insert first.
+func Rebase(base, a, b string, insert func(line string) string) (string, error) {
+	baseLines, aLines := splitLines(base), splitLines(a)
+	if len(baseLines) != len(aLines) {
+		return "", fmt.Errorf("base has %d lines, expected %d", len(baseLines), len(aLines))
+	}
+
+	var sb strings.Builder
+	i := 0
+	for _, o := range lineOps(aLines, splitLines(b)) {
+		switch o.kind {
+		case opEqual:
+			sb.WriteString(baseLines[i])
+			i++
+		case opDelete:
+			i++
+		case opInsert:
+			sb.WriteString(insert(o.line))
+		}
+	}
+	return sb.String(), nil
+}
+
+// HasConflictMarkers reports whether s still contains unresolved Merge markers
+func HasConflictMarkers(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(line, "<<<<<<< ") || strings.HasPrefix(line, ">>>>>>> ") {
+			return true
+		}
+	}
+	return false
+}
+
+func withNewline(line string) string {
+	if strings.HasSuffix(line, "\n") {
+		return line
+	}
+	return line + "\n"
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func lineOps(a, b []string) []op {
+	n, m := len(a), len(b)
+
+	if n*m > maxCells {
+		ops := make([]op, 0, n+m)
+		for _, l := range a {
+			ops = append(ops, op{opDelete, l})
+		}
+		for _, l := range b {
+			ops = append(ops, op{opInsert, l})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]op, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, op{opDelete, a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, op{opInsert, b[j]})
+	}
+	return ops
+}
+
+func hunks(ops []op) []string {
+	var result []string
+
+	for start := 0; start < len(ops); {
+		// find the next change
+		for start < len(ops) && ops[start].kind == opEqual {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		from := start - contextLines
+		if from < 0 {
+			from = 0
+		}
+
+		// extend until a run of unchanged lines is long enough to split hunks
+		end := start
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*contextLines {
+				break
+			}
+			end = run
+		}
+
+		to := end + contextLines
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		result = append(result, formatHunk(ops, from, to))
+		start = to
+	}
+
+	return result
+}
+
+func formatHunk(ops []op, from, to int) string {
+	aStart, bStart := 1, 1
+	for _, o := range ops[:from] {
+		if o.kind != opInsert {
+			aStart++
+		}
+		if o.kind != opDelete {
+			bStart++
+		}
+	}
+
+	var body strings.Builder
+	aLen, bLen := 0, 0
+	for _, o := range ops[from:to] {
+		line := o.line
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n\\ No newline at end of file\n"
+		}
+		switch o.kind {
+		case opEqual:
+			body.WriteString(" " + line)
+			aLen++
+			bLen++
+		case opDelete:
+			body.WriteString("-" + line)
+			aLen++
+		case opInsert:
+			body.WriteString("+" + line)
+			bLen++
+		}
+	}
+
+	if aLen == 0 {
+		aStart--
+	}
+	if bLen == 0 {
+		bStart--
+	}
+
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@\n%s", aStart, aLen, bStart, bLen, body.String())
+}
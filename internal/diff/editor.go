@@ -0,0 +1,60 @@
+package diff
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Editor returns the command that opens path in $VISUAL, $EDITOR or vi.
+// The variable may carry arguments, e.g. "code --wait".
+func Editor(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	fields := strings.Fields(editor)
+	return exec.Command(fields[0], append(fields[1:], path)...)
+}
+
+// WriteMerge writes local and incoming, joined with conflict markers, to a
+// temporary file named after target and returns its path, for editing and
+// reading back with ReadMerge.
+func WriteMerge(target string, local, incoming []byte, incomingName string) (string, error) {
+	tmp, err := os.CreateTemp("", "dotts-merge-*-"+filepath.Base(target))
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tmp.WriteString(Merge("local", incomingName, string(local), string(incoming))); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// ReadMerge reads back a file written by WriteMerge and removes it. A merge
+// with unresolved conflict markers is an error.
+func ReadMerge(path string) ([]byte, error) {
+	defer os.Remove(path)
+
+	merged, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if HasConflictMarkers(string(merged)) {
+		return nil, errors.New("merge left unresolved conflict markers, nothing was written")
+	}
+	return merged, nil
+}
@@ -0,0 +1,331 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/internal/personal"
+	"github.com/arthur404dev/dotts/internal/system"
+)
+
+type stateCheck struct{}
+
+func (stateCheck) Name() string { return "state" }
+
+func (stateCheck) Run(env *Env) Result {
+	if env.State == nil || !env.State.IsInitialized() {
+		return Result{
+			Status:  StatusFail,
+			Message: "dotts is not initialized",
+			Details: []string{"run 'dotts init' to bootstrap this system"},
+		}
+	}
+	return pass(fmt.Sprintf("initialized (machine %s)", env.State.Machine.Name))
+}
+
+type toolsCheck struct{}
+
+func (toolsCheck) Name() string { return "tools" }
+
+func (toolsCheck) Run(env *Env) Result {
+	if env.SysInfo == nil {
+		return skip("system not detected")
+	}
+
+	if !env.SysInfo.HasGit {
+		return Result{
+			Status:  StatusFail,
+			Message: "git is not installed",
+			Details: []string{"git is required to clone and update the config repo"},
+		}
+	}
+
+	if !env.SysInfo.HasNix {
+		return Result{
+			Status:  StatusWarn,
+			Message: "nix is not installed",
+			Details: []string{"nix packages in your manifests will be skipped"},
+		}
+	}
+
+	return pass("git and nix available")
+}
+
+type packageManagersCheck struct{}
+
+func (packageManagersCheck) Name() string { return "package-managers" }
+
+func (packageManagersCheck) Run(env *Env) Result {
+	if env.SysInfo == nil || env.Installers == nil {
+		return skip("system not detected")
+	}
+
+	pm := env.SysInfo.PackageManager
+	if pm == system.PMUnknown {
+		return Result{
+			Status:  StatusWarn,
+			Message: "no supported system package manager detected",
+		}
+	}
+
+	name := installerName(pm)
+	inst, ok := env.Installers.Get(name)
+	if !ok {
+		return Result{
+			Status:  StatusWarn,
+			Message: fmt.Sprintf("%s detected but dotts has no installer for it", pm),
+		}
+	}
+
+	if !inst.Available() {
+		return Result{
+			Status:  StatusFail,
+			Message: fmt.Sprintf("%s detected but not usable", pm),
+			Details: []string{fmt.Sprintf("%s is expected on %s but was not found in PATH", name, env.SysInfo.Distro)},
+		}
+	}
+
+	if inst.NeedsSudo() {
+		if _, err := exec.LookPath("sudo"); err != nil {
+			return Result{
+				Status:  StatusFail,
+				Message: fmt.Sprintf("%s requires sudo, which is not installed", pm),
+			}
+		}
+	}
+
+	return pass(fmt.Sprintf("%s usable", pm))
+}
+
+func installerName(pm system.PackageManager) string {
+	switch pm {
+	case system.PMYay, system.PMParu:
+		return "yay"
+	default:
+		return string(pm)
+	}
+}
+
+type configRepoCheck struct{}
+
+func (configRepoCheck) Name() string { return "config-repo" }
+
+func (configRepoCheck) Run(env *Env) Result {
+	if env.Source == nil {
+		return skip("no config source recorded")
+	}
+
+	src := env.Source
+	if _, err := os.Stat(src.Path); os.IsNotExist(err) {
+		result := Result{
+			Status:  StatusFail,
+			Message: "config repo is missing",
+			Details: []string{src.Path},
+		}
+		if !src.IsLocal && src.URL != "" {
+			result.Fixes = append(result.Fixes, Fix{
+				Description: fmt.Sprintf("clone %s into %s", src.URL, src.Path),
+				Apply: func() error {
+					return src.Clone(src.Path)
+				},
+			})
+		}
+		return result
+	}
+
+	if err := src.Validate(); err != nil {
+		return Result{
+			Status:  StatusFail,
+			Message: "config repo is invalid",
+			Details: []string{err.Error()},
+		}
+	}
+
+	return pass(src.Path)
+}
+
+type personalCheck struct{}
+
+func (personalCheck) Name() string { return "personal" }
+
+func (personalCheck) Run(env *Env) Result {
+	if !personal.Exists() {
+		return Result{
+			Status:  StatusWarn,
+			Message: "personal.yaml not found",
+			Details: []string{personal.GetPath()},
+		}
+	}
+
+	cfg, err := personal.Load()
+	if err != nil {
+		return Result{
+			Status:  StatusFail,
+			Message: "personal.yaml is unreadable",
+			Details: []string{err.Error()},
+		}
+	}
+
+	if !cfg.IsComplete() {
+		return Result{
+			Status:  StatusWarn,
+			Message: "personal.yaml is incomplete",
+			Details: []string{"user.name and user.email are required for templates"},
+		}
+	}
+
+	return pass(fmt.Sprintf("%s <%s>", cfg.User.Name, cfg.User.Email))
+}
+
+type brokenLinksCheck struct{}
+
+func (brokenLinksCheck) Name() string { return "broken-links" }
+
+func (brokenLinksCheck) Run(env *Env) Result {
+	if env.Linker == nil {
+		return skip("linker unavailable")
+	}
+
+	status, err := env.Linker.Status()
+	if err != nil {
+		return Result{Status: StatusFail, Message: err.Error()}
+	}
+
+	if len(status.Broken) == 0 {
+		return pass("no broken links")
+	}
+
+	result := Result{
+		Status:  StatusFail,
+		Message: fmt.Sprintf("%d broken link(s)", len(status.Broken)),
+		Details: status.Broken,
+	}
+
+	for _, target := range status.Broken {
+		target := target
+		entry, ok := env.Linker.Manifest().Get(target)
+		if !ok {
+			continue
+		}
+
+		if _, err := os.Stat(entry.Source); err == nil {
+			result.Fixes = append(result.Fixes, Fix{
+				Description: "relink " + target,
+				Apply: func() error {
+					return env.Linker.Relink(target, linkOptions(env))
+				},
+			})
+			continue
+		}
+
+		description := "prune " + target + " from manifest"
+		if env.Linker.Backups().HasBackup(target) {
+			description = "restore " + target + " from backup"
+		}
+		result.Fixes = append(result.Fixes, Fix{
+			Description: description,
+			Apply: func() error {
+				_, err := env.Linker.Prune(target)
+				return err
+			},
+		})
+	}
+
+	return result
+}
+
+type foreignLinksCheck struct{}
+
+func (foreignLinksCheck) Name() string { return "foreign-links" }
+
+func (foreignLinksCheck) Run(env *Env) Result {
+	if env.Linker == nil {
+		return skip("linker unavailable")
+	}
+
+	status, err := env.Linker.Status()
+	if err != nil {
+		return Result{Status: StatusFail, Message: err.Error()}
+	}
+
+	if len(status.Foreign) == 0 {
+		return pass("no foreign links")
+	}
+
+	result := Result{
+		Status:  StatusWarn,
+		Message: fmt.Sprintf("%d managed path(s) replaced outside dotts", len(status.Foreign)),
+		Details: status.Foreign,
+	}
+
+	for _, target := range status.Foreign {
+		target := target
+		result.Fixes = append(result.Fixes, Fix{
+			Description: "back up and relink " + target,
+			Apply: func() error {
+				return env.Linker.Relink(target, linkOptions(env))
+			},
+		})
+	}
+
+	return result
+}
+
+type backupIndexCheck struct{}
+
+func (backupIndexCheck) Name() string { return "backups" }
+
+func (backupIndexCheck) Run(env *Env) Result {
+	if env.Linker == nil {
+		return skip("linker unavailable")
+	}
+
+	backups := env.Linker.Backups()
+	missing := backups.Missing()
+
+	var corrupt []linker.BackupEntry
+	for _, entry := range backups.List() {
+		if err := backups.Verify(entry); err != nil && !os.IsNotExist(err) {
+			corrupt = append(corrupt, entry)
+		}
+	}
+
+	if len(missing) == 0 && len(corrupt) == 0 {
+		return pass(fmt.Sprintf("%d backup(s) intact", len(backups.List())))
+	}
+
+	result := Result{Status: StatusWarn}
+	switch {
+	case len(corrupt) == 0:
+		result.Message = fmt.Sprintf("%d backup(s) point to missing files", len(missing))
+	case len(missing) == 0:
+		result.Message = fmt.Sprintf("%d backup(s) fail their checksum", len(corrupt))
+	default:
+		result.Message = fmt.Sprintf("%d backup(s) missing, %d failing their checksum", len(missing), len(corrupt))
+	}
+
+	for _, entry := range missing {
+		entry := entry
+		result.Details = append(result.Details, fmt.Sprintf("%s (v%d) -> %s", entry.OriginalPath, entry.Version, entry.BackupPath))
+		result.Fixes = append(result.Fixes, Fix{
+			Description: fmt.Sprintf("drop backup index entry for %s (v%d)", entry.OriginalPath, entry.Version),
+			Apply: func() error {
+				return backups.ForgetVersion(entry.OriginalPath, entry.Version)
+			},
+		})
+	}
+	for _, entry := range corrupt {
+		result.Details = append(result.Details, fmt.Sprintf("%s (v%d) -> %s: checksum mismatch", entry.OriginalPath, entry.Version, entry.BackupPath))
+	}
+
+	return result
+}
+
+func linkOptions(env *Env) linker.LinkOptions {
+	opts := linker.DefaultLinkOptions()
+	opts.Templates = env.Templates
+	opts.Decrypter = env.Decrypter
+	return opts
+}
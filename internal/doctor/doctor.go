@@ -0,0 +1,124 @@
+package doctor
+
+import (
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/crypt"
+	"github.com/arthur404dev/dotts/internal/installer"
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/internal/state"
+	"github.com/arthur404dev/dotts/internal/system"
+	"github.com/arthur404dev/dotts/internal/template"
+)
+
+// Status is the outcome of a single health check
+type Status int
+
+const (
+	StatusPass Status = iota
+	StatusWarn
+	StatusFail
+	StatusSkip
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusPass:
+		return "pass"
+	case StatusWarn:
+		return "warn"
+	case StatusFail:
+		return "fail"
+	case StatusSkip:
+		return "skip"
+	default:
+		return "unknown"
+	}
+}
+
+// Env is everything a check may inspect. Fields are nil when unavailable
+// (e.g. Linker before dotts is initialized), and checks must tolerate that.
+type Env struct {
+	SysInfo    *system.SystemInfo
+	State      *state.State
+	Paths      *state.Paths
+	Source     *config.Source
+	Linker     *linker.SymlinkLinker
+	Installers *installer.Registry
+	Templates  *template.Engine
+	Decrypter  *crypt.Age
+}
+
+// Fix is a remediation for a failed or warning check
+type Fix struct {
+	Description string
+	Apply       func() error
+}
+
+// Result is what a check reports
+type Result struct {
+	Status  Status
+	Message string
+	Details []string
+	Fixes   []Fix
+}
+
+// Check is a single pluggable health check
+type Check interface {
+	// Name returns a short identifier shown in reports (e.g., "tools")
+	Name() string
+
+	// Run inspects the environment and reports its findings
+	Run(env *Env) Result
+}
+
+// Report pairs a check with its result
+type Report struct {
+	Check  string
+	Result Result
+}
+
+// Registry holds the checks to run, in registration order
+type Registry struct {
+	checks []Check
+}
+
+func NewRegistry() *Registry {
+	r := &Registry{}
+	r.registerDefaults()
+	return r
+}
+
+func (r *Registry) registerDefaults() {
+	r.Register(stateCheck{})
+	r.Register(toolsCheck{})
+	r.Register(packageManagersCheck{})
+	r.Register(configRepoCheck{})
+	r.Register(personalCheck{})
+	r.Register(brokenLinksCheck{})
+	r.Register(foreignLinksCheck{})
+	r.Register(backupIndexCheck{})
+}
+
+func (r *Registry) Register(c Check) {
+	r.checks = append(r.checks, c)
+}
+
+func (r *Registry) Checks() []Check {
+	return r.checks
+}
+
+func (r *Registry) Run(env *Env) []Report {
+	reports := make([]Report, 0, len(r.checks))
+	for _, c := range r.checks {
+		reports = append(reports, Report{Check: c.Name(), Result: c.Run(env)})
+	}
+	return reports
+}
+
+func pass(message string) Result {
+	return Result{Status: StatusPass, Message: message}
+}
+
+func skip(message string) Result {
+	return Result{Status: StatusSkip, Message: message}
+}
@@ -0,0 +1,92 @@
+package history
+
+import (
+	"slices"
+	"sort"
+)
+
+// Diff is what changed from one generation to another
+type Diff struct {
+	From, To *Generation
+
+	ConfigsAdded    []string
+	ConfigsRemoved  []string
+	PackagesAdded   map[string][]string // manager -> packages
+	PackagesRemoved map[string][]string
+	LinksAdded      []string // targets
+	LinksRemoved    []string
+	LinksChanged    []string // different source, variant, mode or content
+}
+
+// IsEmpty reports whether the generations are the same apart from metadata
+func (d *Diff) IsEmpty() bool {
+	return len(d.ConfigsAdded)+len(d.ConfigsRemoved)+len(d.PackagesAdded)+len(d.PackagesRemoved)+
+		len(d.LinksAdded)+len(d.LinksRemoved)+len(d.LinksChanged) == 0
+}
+
+// Compare lists the changes that take from to to
+func Compare(from, to *Generation) *Diff {
+	d := &Diff{
+		From:            from,
+		To:              to,
+		PackagesAdded:   make(map[string][]string),
+		PackagesRemoved: make(map[string][]string),
+	}
+
+	d.ConfigsAdded, d.ConfigsRemoved = setDiff(from.Configs, to.Configs)
+
+	managers := make(map[string]bool)
+	for m := range from.Packages {
+		managers[m] = true
+	}
+	for m := range to.Packages {
+		managers[m] = true
+	}
+	for m := range managers {
+		added, removed := setDiff(from.Packages[m], to.Packages[m])
+		if len(added) > 0 {
+			d.PackagesAdded[m] = added
+		}
+		if len(removed) > 0 {
+			d.PackagesRemoved[m] = removed
+		}
+	}
+
+	for _, entry := range to.Links {
+		old, ok := from.Link(entry.Target)
+		switch {
+		case !ok:
+			d.LinksAdded = append(d.LinksAdded, entry.Target)
+		case old.Source != entry.Source || old.Variant != entry.Variant || old.Mode != entry.Mode ||
+			old.TargetHash != entry.TargetHash || from.Contents[entry.Target] != to.Contents[entry.Target]:
+			d.LinksChanged = append(d.LinksChanged, entry.Target)
+		}
+	}
+	for _, entry := range from.Links {
+		if _, ok := to.Link(entry.Target); !ok {
+			d.LinksRemoved = append(d.LinksRemoved, entry.Target)
+		}
+	}
+
+	sort.Strings(d.LinksAdded)
+	sort.Strings(d.LinksRemoved)
+	sort.Strings(d.LinksChanged)
+	return d
+}
+
+// setDiff returns the items only in b and the items only in a, sorted
+func setDiff(a, b []string) (added, removed []string) {
+	for _, s := range b {
+		if !slices.Contains(a, s) {
+			added = append(added, s)
+		}
+	}
+	for _, s := range a {
+		if !slices.Contains(b, s) {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
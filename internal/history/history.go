@@ -0,0 +1,263 @@
+// Package history keeps numbered generations: snapshots of what dotts had
+// put in place after each successful apply, so earlier ones can be compared
+// and restored.
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arthur404dev/dotts/internal/linker"
+)
+
+// Generation is the state of the machine after one apply
+type Generation struct {
+	Number    int                 `json:"number"`
+	CreatedAt time.Time           `json:"created_at"`
+	Machine   string              `json:"machine"`
+	Commit    string              `json:"commit,omitempty"` // config repo HEAD
+	Profiles  []string            `json:"profiles,omitempty"`
+	Configs   []string            `json:"configs,omitempty"`
+	Features  []string            `json:"features,omitempty"`
+	Settings  map[string]any      `json:"settings,omitempty"`
+	Packages  map[string][]string `json:"packages,omitempty"` // manager -> installed packages
+	Links     []linker.LinkEntry  `json:"links"`
+	Contents  map[string]string   `json:"contents,omitempty"` // target -> hash of the written content
+	Backups   []string            `json:"backups,omitempty"`  // backups this apply made
+	Note      string              `json:"note,omitempty"`
+	Rollback  int                 `json:"rollback,omitempty"` // generation a rollback restored
+}
+
+// Link returns the entry for target, if the generation managed it
+func (g *Generation) Link(target string) (linker.LinkEntry, bool) {
+	for _, entry := range g.Links {
+		if entry.Target == target {
+			return entry, true
+		}
+	}
+	return linker.LinkEntry{}, false
+}
+
+// Previous returns the newest generation before current that is not itself
+// a rollback. A rollback stands for the generation it restored, so rolling
+// back again keeps going back instead of returning to where the last
+// rollback started.
+func Previous(generations []Generation, current int) (*Generation, error) {
+	for {
+		g := find(generations, current)
+		if g == nil || g.Rollback == 0 || g.Rollback >= current {
+			break
+		}
+		current = g.Rollback
+	}
+
+	for i := len(generations) - 1; i >= 0; i-- {
+		if generations[i].Number < current && generations[i].Rollback == 0 {
+			return &generations[i], nil
+		}
+	}
+	return nil, fmt.Errorf("there is no generation before %d", current)
+}
+
+func find(generations []Generation, n int) *Generation {
+	for i := range generations {
+		if generations[i].Number == n {
+			return &generations[i]
+		}
+	}
+	return nil
+}
+
+// Store keeps generations as <dataDir>/generations/<n>.json. Written file
+// contents are shared between generations in store/, named by hash.
+type Store struct {
+	dir string
+}
+
+func NewStore(dataDir string) *Store {
+	return &Store{dir: filepath.Join(dataDir, "generations")}
+}
+
+// List returns every generation, oldest first
+func (s *Store) List() ([]Generation, error) {
+	files, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var generations []Generation
+	for _, f := range files {
+		n, ok := generationNumber(f.Name())
+		if !ok {
+			continue
+		}
+		g, err := s.Get(n)
+		if err != nil {
+			return nil, err
+		}
+		generations = append(generations, *g)
+	}
+
+	sort.Slice(generations, func(i, j int) bool {
+		return generations[i].Number < generations[j].Number
+	})
+	return generations, nil
+}
+
+// Get loads generation n
+func (s *Store) Get(n int) (*Generation, error) {
+	data, err := os.ReadFile(s.path(n))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("generation %d does not exist", n)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Generation{}
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, fmt.Errorf("failed to parse generation %d: %w", n, err)
+	}
+	return g, nil
+}
+
+// Latest returns the newest generation, or nil when there is none
+func (s *Store) Latest() (*Generation, error) {
+	generations, err := s.List()
+	if err != nil || len(generations) == 0 {
+		return nil, err
+	}
+	return &generations[len(generations)-1], nil
+}
+
+// Record numbers g after the newest generation and saves it
+func (s *Store) Record(g *Generation) error {
+	latest, err := s.Latest()
+	if err != nil {
+		return err
+	}
+
+	g.Number = 1
+	if latest != nil {
+		g.Number = latest.Number + 1
+	}
+	if g.CreatedAt.IsZero() {
+		g.CreatedAt = time.Now()
+	}
+
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(g.Number), data, 0600)
+}
+
+// SaveContent stores content and returns its hash. Callers keep decrypted
+// files and resolved secrets out of the store, but contents are still
+// private to the user.
+func (s *Store) SaveContent(content []byte) (string, error) {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	path := filepath.Join(s.dir, "store", hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	return hash, os.WriteFile(path, content, 0600)
+}
+
+// Content returns content saved with SaveContent
+func (s *Store) Content(hash string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.dir, "store", hash))
+}
+
+// Prune deletes the generations policy does not keep, then the stored
+// contents that no remaining generation refers to, and returns the deleted
+// generations. The newest generation is always kept. With dryRun nothing is
+// deleted.
+func (s *Store) Prune(policy linker.RetentionPolicy, dryRun bool) ([]Generation, error) {
+	generations, err := s.List()
+	if err != nil || len(generations) == 0 {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-policy.MaxAge)
+
+	var pruned, kept []Generation
+	for i, g := range generations {
+		newest := i == len(generations)-1
+		expired := policy.MaxAge > 0 && g.CreatedAt.Before(cutoff)
+		surplus := policy.KeepLast > 0 && i < len(generations)-policy.KeepLast
+		if !newest && (expired || surplus) {
+			pruned = append(pruned, g)
+		} else {
+			kept = append(kept, g)
+		}
+	}
+
+	if dryRun {
+		return pruned, nil
+	}
+	for _, g := range pruned {
+		if err := os.Remove(s.path(g.Number)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return pruned, s.collect(kept)
+}
+
+// collect deletes stored contents that none of generations refers to
+func (s *Store) collect(generations []Generation) error {
+	files, err := os.ReadDir(filepath.Join(s.dir, "store"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	used := make(map[string]bool)
+	for _, g := range generations {
+		for _, hash := range g.Contents {
+			used[hash] = true
+		}
+	}
+	for _, f := range files {
+		if used[f.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, "store", f.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) path(n int) string {
+	return filepath.Join(s.dir, strconv.Itoa(n)+".json")
+}
+
+func generationNumber(name string) (int, bool) {
+	base, ok := strings.CutSuffix(name, ".json")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(base)
+	return n, err == nil && n > 0
+}
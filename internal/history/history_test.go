@@ -0,0 +1,157 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/arthur404dev/dotts/internal/linker"
+)
+
+func TestPrevious(t *testing.T) {
+	applied := []Generation{{Number: 1}, {Number: 2}, {Number: 3}}
+
+	tests := []struct {
+		name        string
+		generations []Generation
+		current     int
+		want        int
+		wantErr     bool
+	}{
+		{name: "apply", generations: applied, current: 3, want: 2},
+		{name: "older current", generations: applied, current: 2, want: 1},
+		{name: "first generation", generations: applied, current: 1, wantErr: true},
+		{
+			name:        "after a rollback",
+			generations: append(applied, Generation{Number: 4, Rollback: 2}),
+			current:     4,
+			want:        1,
+		},
+		{
+			name: "rollback of a rollback",
+			generations: append(applied,
+				Generation{Number: 4, Rollback: 3},
+				Generation{Number: 5, Rollback: 4}),
+			current: 5,
+			want:    2,
+		},
+		{
+			name:        "rollbacks are never targets",
+			generations: append(applied, Generation{Number: 4, Rollback: 1}, Generation{Number: 5}),
+			current:     5,
+			want:        3,
+		},
+		{
+			name:        "back to the start",
+			generations: append(applied, Generation{Number: 4, Rollback: 2}, Generation{Number: 5, Rollback: 1}),
+			current:     5,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Previous(tt.generations, tt.current)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Previous() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Number != tt.want {
+				t.Errorf("Previous() = %d, want %d", got.Number, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy linker.RetentionPolicy
+		want   []int // generations left
+	}{
+		{name: "no policy", want: []int{1, 2, 3, 4}},
+		{name: "keep last", policy: linker.RetentionPolicy{KeepLast: 2}, want: []int{3, 4}},
+		{name: "max age", policy: linker.RetentionPolicy{MaxAge: 36 * time.Hour}, want: []int{3, 4}},
+		{name: "newest always kept", policy: linker.RetentionPolicy{MaxAge: time.Hour}, want: []int{4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore(t.TempDir())
+			old := saveTestContent(t, store, "old")
+			shared := saveTestContent(t, store, "shared")
+			newest := saveTestContent(t, store, "newest")
+			orphan := saveTestContent(t, store, "orphan")
+
+			for i, contents := range []map[string]string{
+				{"a": old},
+				{"a": old, "b": shared},
+				{"b": shared},
+				{"b": shared, "c": newest},
+			} {
+				g := &Generation{CreatedAt: time.Now().Add(-time.Duration(3-i) * 24 * time.Hour), Contents: contents}
+				if err := store.Record(g); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := store.Prune(tt.policy, false); err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+
+			generations, err := store.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			used := make(map[string]bool)
+			for _, g := range generations {
+				got = append(got, g.Number)
+				for _, hash := range g.Contents {
+					used[hash] = true
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Prune() left generations %v, want %v", got, tt.want)
+			}
+
+			for _, hash := range []string{old, shared, newest, orphan} {
+				if _, err := store.Content(hash); (err == nil) != used[hash] {
+					t.Errorf("content %s stored = %v, want %v", hash[:8], err == nil, used[hash])
+				}
+			}
+		})
+	}
+}
+
+func TestStorePruneDryRun(t *testing.T) {
+	store := NewStore(t.TempDir())
+	hash := saveTestContent(t, store, "old")
+	for _, contents := range []map[string]string{{"a": hash}, nil} {
+		if err := store.Record(&Generation{Contents: contents}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruned, err := store.Prune(linker.RetentionPolicy{KeepLast: 1}, true)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(pruned) != 1 || pruned[0].Number != 1 {
+		t.Errorf("Prune() = %+v, want generation 1", pruned)
+	}
+	if _, err := store.Get(1); err != nil {
+		t.Errorf("dry run deleted generation 1: %v", err)
+	}
+	if _, err := store.Content(hash); err != nil {
+		t.Errorf("dry run deleted stored content: %v", err)
+	}
+}
+
+func saveTestContent(t *testing.T, store *Store, content string) string {
+	t.Helper()
+	hash, err := store.SaveContent([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
@@ -59,6 +59,84 @@ func (p *InstallPlan) Total() int {
 		len(p.Apt) + len(p.Dnf) + len(p.Brew) + len(p.Cask)
 }
 
+// PlanGroup is the slice of a plan handled by a single installer
+type PlanGroup struct {
+	Name      string   // Plan section (e.g., "aur", "cask")
+	Installer string   // Registry name of the installer handling it
+	Packages  []string // Packages in this section
+}
+
+// Groups returns the non-empty plan sections in installation order
+func (p *InstallPlan) Groups() []PlanGroup {
+	all := []PlanGroup{
+		{Name: "nix", Installer: "nix", Packages: p.Nix},
+		{Name: "pacman", Installer: "pacman", Packages: p.Pacman},
+		{Name: "aur", Installer: "yay", Packages: p.AUR},
+		{Name: "apt", Installer: "apt", Packages: p.Apt},
+		{Name: "dnf", Installer: "dnf", Packages: p.Dnf},
+		{Name: "brew", Installer: "brew", Packages: p.Brew},
+		{Name: "cask", Installer: "brew", Packages: p.Cask},
+	}
+
+	var groups []PlanGroup
+	for _, g := range all {
+		if len(g.Packages) > 0 {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// managerSections lists the names accepted by Only in installation order
+var managerSections = []string{"nix", "pacman", "aur", "apt", "dnf", "brew", "cask"}
+
+// Only returns a copy of the plan restricted to the named managers.
+// Names match plan sections ("nix", "pacman", "aur", "apt", "dnf", "brew", "cask");
+// "yay" and "paru" are accepted as aliases for "aur". Casks are installed by
+// Homebrew, so "brew" selects both formulae and casks while "cask" selects
+// casks alone. Unknown names are an error.
+func (p *InstallPlan) Only(managers []string) (*InstallPlan, error) {
+	keep := make(map[string]bool)
+	for _, m := range managers {
+		switch m = strings.ToLower(strings.TrimSpace(m)); m {
+		case "yay", "paru":
+			keep["aur"] = true
+		case "brew":
+			keep["brew"] = true
+			keep["cask"] = true
+		case "nix", "pacman", "aur", "apt", "dnf", "cask":
+			keep[m] = true
+		default:
+			return nil, fmt.Errorf("unknown package manager %q (expected one of %s, yay, paru)",
+				m, strings.Join(managerSections, ", "))
+		}
+	}
+
+	filtered := &InstallPlan{}
+	if keep["nix"] {
+		filtered.Nix = p.Nix
+	}
+	if keep["pacman"] {
+		filtered.Pacman = p.Pacman
+	}
+	if keep["aur"] {
+		filtered.AUR = p.AUR
+	}
+	if keep["apt"] {
+		filtered.Apt = p.Apt
+	}
+	if keep["dnf"] {
+		filtered.Dnf = p.Dnf
+	}
+	if keep["brew"] {
+		filtered.Brew = p.Brew
+	}
+	if keep["cask"] {
+		filtered.Cask = p.Cask
+	}
+	return filtered, nil
+}
+
 // InstallResult tracks the outcome of an installation
 type InstallResult struct {
 	Installer string
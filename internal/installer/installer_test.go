@@ -0,0 +1,62 @@
+package installer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInstallPlanOnly(t *testing.T) {
+	plan := &InstallPlan{
+		Nix:    []string{"ripgrep"},
+		Pacman: []string{"git"},
+		AUR:    []string{"yay-bin"},
+		Apt:    []string{"curl"},
+		Dnf:    []string{"wget"},
+		Brew:   []string{"fd"},
+		Cask:   []string{"kitty"},
+	}
+
+	tests := []struct {
+		name     string
+		managers []string
+		want     *InstallPlan
+		wantErr  bool
+	}{
+		{name: "single", managers: []string{"nix"}, want: &InstallPlan{Nix: plan.Nix}},
+		{name: "case and spaces", managers: []string{" Pacman "}, want: &InstallPlan{Pacman: plan.Pacman}},
+		{name: "aur aliases", managers: []string{"paru"}, want: &InstallPlan{AUR: plan.AUR}},
+		{name: "brew includes casks", managers: []string{"brew"}, want: &InstallPlan{Brew: plan.Brew, Cask: plan.Cask}},
+		{name: "cask alone", managers: []string{"cask"}, want: &InstallPlan{Cask: plan.Cask}},
+		{name: "several", managers: []string{"apt", "dnf"}, want: &InstallPlan{Apt: plan.Apt, Dnf: plan.Dnf}},
+		{name: "unknown", managers: []string{"nix", "bew"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := plan.Only(tt.managers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Only(%q) error = %v, wantErr %v", tt.managers, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Only(%q) = %+v, want %+v", tt.managers, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallPlanGroups(t *testing.T) {
+	plan := &InstallPlan{
+		Pacman: []string{"git"},
+		AUR:    []string{"yay-bin"},
+		Cask:   []string{"kitty"},
+	}
+
+	want := []PlanGroup{
+		{Name: "pacman", Installer: "pacman", Packages: plan.Pacman},
+		{Name: "aur", Installer: "yay", Packages: plan.AUR},
+		{Name: "cask", Installer: "brew", Packages: plan.Cask},
+	}
+	if got := plan.Groups(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Groups() = %+v, want %+v", got, want)
+	}
+}
@@ -86,73 +86,66 @@ func (r *Registry) CreatePlan(manifest *schema.PackageManifest) *InstallPlan {
 	return plan
 }
 
-type Orchestrator struct {
-	registry *Registry
-	progress ProgressCallback
+// PlanStatus compares one plan section against what is installed
+type PlanStatus struct {
+	Name      string
+	Installer string
+	Available bool
+	Installed []string
+	Missing   []string
 }
 
-func NewOrchestrator(registry *Registry, progress ProgressCallback) *Orchestrator {
-	return &Orchestrator{
-		registry: registry,
-		progress: progress,
-	}
+// IsSatisfied returns true if every package in the section is installed
+func (s *PlanStatus) IsSatisfied() bool {
+	return s.Available && len(s.Missing) == 0
 }
 
-func (o *Orchestrator) Execute(ctx context.Context, plan *InstallPlan) []InstallResult {
-	var results []InstallResult
+// CheckPlan reports, per installer, which planned packages are installed.
+// Sections whose installer is not usable report every package as missing.
+func (r *Registry) CheckPlan(plan *InstallPlan) []PlanStatus {
+	var statuses []PlanStatus
 
-	type installJob struct {
-		name      string
-		installer Installer
-		packages  []string
-	}
-
-	var jobs []installJob
-
-	if len(plan.Nix) > 0 {
-		if i, ok := o.registry.Get("nix"); ok && i.Available() {
-			jobs = append(jobs, installJob{"nix", i, plan.Nix})
+	for _, group := range plan.Groups() {
+		status := PlanStatus{
+			Name:      group.Name,
+			Installer: group.Installer,
 		}
-	}
 
-	if len(plan.Pacman) > 0 {
-		if i, ok := o.registry.Get("pacman"); ok && i.Available() {
-			jobs = append(jobs, installJob{"pacman", i, plan.Pacman})
+		inst, ok := r.Get(group.Installer)
+		if ok && inst.Available() {
+			status.Available = true
+			status.Missing, status.Installed = filterInstalled(inst, group.Packages)
+		} else {
+			status.Missing = group.Packages
 		}
-	}
 
-	if len(plan.AUR) > 0 {
-		if i, ok := o.registry.Get("yay"); ok && i.Available() {
-			jobs = append(jobs, installJob{"yay", i, plan.AUR})
-		}
+		statuses = append(statuses, status)
 	}
 
-	if len(plan.Apt) > 0 {
-		if i, ok := o.registry.Get("apt"); ok && i.Available() {
-			jobs = append(jobs, installJob{"apt", i, plan.Apt})
-		}
-	}
+	return statuses
+}
 
-	if len(plan.Dnf) > 0 {
-		if i, ok := o.registry.Get("dnf"); ok && i.Available() {
-			jobs = append(jobs, installJob{"dnf", i, plan.Dnf})
-		}
-	}
+type Orchestrator struct {
+	registry *Registry
+	progress ProgressCallback
+}
 
-	if len(plan.Brew) > 0 {
-		if i, ok := o.registry.Get("brew"); ok && i.Available() {
-			jobs = append(jobs, installJob{"brew", i, plan.Brew})
-		}
+func NewOrchestrator(registry *Registry, progress ProgressCallback) *Orchestrator {
+	return &Orchestrator{
+		registry: registry,
+		progress: progress,
 	}
+}
 
-	if len(plan.Cask) > 0 {
-		if i, ok := o.registry.Get("brew"); ok && i.Available() {
-			jobs = append(jobs, installJob{"cask", i, plan.Cask})
-		}
-	}
+func (o *Orchestrator) Execute(ctx context.Context, plan *InstallPlan) []InstallResult {
+	var results []InstallResult
 
-	for _, job := range jobs {
-		result := o.runInstall(ctx, job.name, job.installer, job.packages)
+	for _, group := range plan.Groups() {
+		i, ok := o.registry.Get(group.Installer)
+		if !ok || !i.Available() {
+			continue
+		}
+		result := o.runInstall(ctx, group.Name, i, group.Packages)
 		results = append(results, result)
 	}
 
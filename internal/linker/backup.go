@@ -1,11 +1,16 @@
 package linker
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,9 +19,12 @@ type BackupManager struct {
 	index     *BackupIndex
 }
 
+// BackupIndex lists every backup version per original path, oldest first.
+// Entries is the older one-backup-per-path layout, migrated on load.
 type BackupIndex struct {
-	path    string
-	Entries map[string]BackupEntry `json:"entries"`
+	path     string
+	Versions map[string][]BackupEntry `json:"versions"`
+	Entries  map[string]BackupEntry   `json:"entries,omitempty"`
 }
 
 type BackupEntry struct {
@@ -24,6 +32,22 @@ type BackupEntry struct {
 	BackupPath   string    `json:"backup_path"`
 	BackedUpAt   time.Time `json:"backed_up_at"`
 	IsDir        bool      `json:"is_dir"`
+	Version      int       `json:"version"`
+	Checksum     string    `json:"checksum,omitempty"` // sha256 of the content, or of a directory's files
+	Size         int64     `json:"size,omitempty"`
+}
+
+// RetentionPolicy decides which backup versions Prune removes. A version
+// goes when it is older than MaxAge or not among the KeepLast newest of its
+// path; zero values disable a rule. The first version of a path is never
+// removed.
+type RetentionPolicy struct {
+	MaxAge   time.Duration
+	KeepLast int
+}
+
+func (p RetentionPolicy) IsZero() bool {
+	return p.MaxAge == 0 && p.KeepLast == 0
 }
 
 func NewBackupManager(dataDir string) (*BackupManager, error) {
@@ -46,8 +70,8 @@ func NewBackupManager(dataDir string) (*BackupManager, error) {
 func loadBackupIndex(dataDir string) (*BackupIndex, error) {
 	path := filepath.Join(dataDir, "backup-index.json")
 	index := &BackupIndex{
-		path:    path,
-		Entries: make(map[string]BackupEntry),
+		path:     path,
+		Versions: make(map[string][]BackupEntry),
 	}
 
 	data, err := os.ReadFile(path)
@@ -61,10 +85,22 @@ func loadBackupIndex(dataDir string) (*BackupIndex, error) {
 	if err := json.Unmarshal(data, index); err != nil {
 		return nil, err
 	}
+	if index.Versions == nil {
+		index.Versions = make(map[string][]BackupEntry)
+	}
+
+	for original, entry := range index.Entries {
+		if len(index.Versions[original]) == 0 {
+			entry.Version = 1
+			index.Versions[original] = []BackupEntry{entry}
+		}
+	}
+	index.Entries = nil
 
 	return index, nil
 }
 
+// Backup copies path into a new version and returns where it was stored
 func (b *BackupManager) Backup(path string) (string, error) {
 	path = expandPath(path)
 
@@ -73,30 +109,36 @@ func (b *BackupManager) Backup(path string) (string, error) {
 		return "", err
 	}
 
-	timestamp := time.Now().Format("2006-01-02T15-04-05")
-	baseName := filepath.Base(path)
-	backupPath := filepath.Join(b.backupDir, timestamp, baseName)
+	versions := b.index.Versions[path]
+	version := 1
+	if len(versions) > 0 {
+		version = versions[len(versions)-1].Version + 1
+	}
 
+	backupPath := b.newBackupPath(path)
 	if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
 		return "", err
 	}
 
-	if info.IsDir() {
-		if err := copyDir(path, backupPath); err != nil {
-			return "", err
-		}
-	} else {
-		if err := copyFile(path, backupPath); err != nil {
-			return "", err
-		}
+	// symlinks are kept as links, so a dangling one can be backed up too
+	if err := CopyPath(path, backupPath); err != nil {
+		return "", err
+	}
+
+	checksum, size, err := backupChecksum(backupPath)
+	if err != nil {
+		return "", err
 	}
 
-	b.index.Entries[path] = BackupEntry{
+	b.index.Versions[path] = append(versions, BackupEntry{
 		OriginalPath: path,
 		BackupPath:   backupPath,
 		BackedUpAt:   time.Now(),
 		IsDir:        info.IsDir(),
-	}
+		Version:      version,
+		Checksum:     checksum,
+		Size:         size,
+	})
 
 	if err := b.saveIndex(); err != nil {
 		return "", err
@@ -105,12 +147,41 @@ func (b *BackupManager) Backup(path string) (string, error) {
 	return backupPath, nil
 }
 
+// newBackupPath keeps the original's path below a timestamped directory so
+// files with the same name never collide
+func (b *BackupManager) newBackupPath(path string) string {
+	rel := strings.TrimPrefix(filepath.Clean(path), string(filepath.Separator))
+	if home, err := os.UserHomeDir(); err == nil {
+		if r, err := filepath.Rel(home, path); err == nil && !strings.HasPrefix(r, "..") {
+			rel = r
+		}
+	}
+
+	stamp := time.Now().Format("2006-01-02T15-04-05")
+	backupPath := filepath.Join(b.backupDir, stamp, rel)
+	for i := 2; pathExists(backupPath); i++ {
+		backupPath = filepath.Join(b.backupDir, fmt.Sprintf("%s.%d", stamp, i), rel)
+	}
+	return backupPath
+}
+
+// Restore puts the newest backup of originalPath back. The backup is kept
+// as a version until the retention policy removes it.
 func (b *BackupManager) Restore(originalPath string) error {
+	entry, ok := b.Latest(originalPath)
+	if !ok {
+		return fmt.Errorf("no backup found for %s", expandPath(originalPath))
+	}
+	return b.RestoreVersion(originalPath, entry.Version)
+}
+
+// RestoreVersion copies a backup version over originalPath and keeps it
+func (b *BackupManager) RestoreVersion(originalPath string, version int) error {
 	originalPath = expandPath(originalPath)
 
-	entry, ok := b.index.Entries[originalPath]
+	entry, ok := b.Version(originalPath, version)
 	if !ok {
-		return fmt.Errorf("no backup found for %s", originalPath)
+		return fmt.Errorf("no backup version %d for %s", version, originalPath)
 	}
 
 	if err := os.RemoveAll(originalPath); err != nil && !os.IsNotExist(err) {
@@ -121,49 +192,183 @@ func (b *BackupManager) Restore(originalPath string) error {
 		return err
 	}
 
-	if entry.IsDir {
-		if err := copyDir(entry.BackupPath, originalPath); err != nil {
-			return err
-		}
-	} else {
-		if err := copyFile(entry.BackupPath, originalPath); err != nil {
-			return err
+	return CopyPath(entry.BackupPath, originalPath)
+}
+
+func (b *BackupManager) HasBackup(originalPath string) bool {
+	originalPath = expandPath(originalPath)
+	return len(b.index.Versions[originalPath]) > 0
+}
+
+// Versions returns the backups of originalPath, oldest first
+func (b *BackupManager) Versions(originalPath string) []BackupEntry {
+	originalPath = expandPath(originalPath)
+	return append([]BackupEntry(nil), b.index.Versions[originalPath]...)
+}
+
+// Version returns one backup of originalPath
+func (b *BackupManager) Version(originalPath string, version int) (BackupEntry, bool) {
+	for _, entry := range b.Versions(originalPath) {
+		if entry.Version == version {
+			return entry, true
 		}
 	}
+	return BackupEntry{}, false
+}
 
-	delete(b.index.Entries, originalPath)
-	return b.saveIndex()
+// Original returns the oldest backup of originalPath: what was there before
+// dotts first replaced it. Retention never removes it.
+func (b *BackupManager) Original(originalPath string) (BackupEntry, bool) {
+	versions := b.Versions(originalPath)
+	if len(versions) == 0 {
+		return BackupEntry{}, false
+	}
+	return versions[0], true
 }
 
-func (b *BackupManager) HasBackup(originalPath string) bool {
-	originalPath = expandPath(originalPath)
-	_, ok := b.index.Entries[originalPath]
-	return ok
+// Latest returns the newest backup of originalPath
+func (b *BackupManager) Latest(originalPath string) (BackupEntry, bool) {
+	versions := b.Versions(originalPath)
+	if len(versions) == 0 {
+		return BackupEntry{}, false
+	}
+	return versions[len(versions)-1], true
 }
 
+// List returns every backup version, ordered by path and version
 func (b *BackupManager) List() []BackupEntry {
-	entries := make([]BackupEntry, 0, len(b.index.Entries))
-	for _, entry := range b.index.Entries {
-		entries = append(entries, entry)
+	var entries []BackupEntry
+	for _, versions := range b.index.Versions {
+		entries = append(entries, versions...)
 	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].OriginalPath != entries[j].OriginalPath {
+			return entries[i].OriginalPath < entries[j].OriginalPath
+		}
+		return entries[i].Version < entries[j].Version
+	})
 	return entries
 }
 
+// Missing returns index entries whose backup copy no longer exists on disk
+func (b *BackupManager) Missing() []BackupEntry {
+	var missing []BackupEntry
+	for _, entry := range b.List() {
+		if !pathExists(entry.BackupPath) {
+			missing = append(missing, entry)
+		}
+	}
+	return missing
+}
+
+// Verify checks a backup copy against the checksum taken when it was made
+func (b *BackupManager) Verify(entry BackupEntry) error {
+	if entry.Checksum == "" {
+		return nil
+	}
+	checksum, _, err := backupChecksum(entry.BackupPath)
+	if err != nil {
+		return err
+	}
+	if checksum != entry.Checksum {
+		return fmt.Errorf("checksum mismatch for %s", entry.BackupPath)
+	}
+	return nil
+}
+
+// Forget drops every index entry for originalPath without touching any files
+func (b *BackupManager) Forget(originalPath string) error {
+	originalPath = expandPath(originalPath)
+	delete(b.index.Versions, originalPath)
+	return b.saveIndex()
+}
+
+// ForgetVersion drops one index entry without touching any files
+func (b *BackupManager) ForgetVersion(originalPath string, version int) error {
+	originalPath = expandPath(originalPath)
+	b.dropVersion(originalPath, version)
+	return b.saveIndex()
+}
+
 func (b *BackupManager) Clean(olderThan time.Duration) error {
-	cutoff := time.Now().Add(-olderThan)
+	_, err := b.Prune(RetentionPolicy{MaxAge: olderThan}, nil, false)
+	return err
+}
 
-	for path, entry := range b.index.Entries {
-		if entry.BackedUpAt.Before(cutoff) {
-			if err := os.RemoveAll(entry.BackupPath); err != nil {
-				return err
+// Prune deletes the versions policy does not keep and returns them. The
+// first version of every path is the original from before dotts and is
+// always kept, as are all versions of the paths exempt reports true for.
+// With dryRun nothing is deleted.
+func (b *BackupManager) Prune(policy RetentionPolicy, exempt func(originalPath string) bool, dryRun bool) ([]BackupEntry, error) {
+	if policy.IsZero() {
+		return nil, nil
+	}
+	cutoff := time.Now().Add(-policy.MaxAge)
+
+	var pruned []BackupEntry
+	for path, versions := range b.index.Versions {
+		if exempt != nil && exempt(path) {
+			continue
+		}
+		// versions[0] is the original
+		for i := 1; i < len(versions); i++ {
+			entry := versions[i]
+			expired := policy.MaxAge > 0 && entry.BackedUpAt.Before(cutoff)
+			surplus := policy.KeepLast > 0 && i < len(versions)-policy.KeepLast
+			if expired || surplus {
+				pruned = append(pruned, entry)
 			}
-			delete(b.index.Entries, path)
+		}
+	}
+	sort.Slice(pruned, func(i, j int) bool {
+		if pruned[i].OriginalPath != pruned[j].OriginalPath {
+			return pruned[i].OriginalPath < pruned[j].OriginalPath
+		}
+		return pruned[i].Version < pruned[j].Version
+	})
+
+	if dryRun {
+		return pruned, nil
+	}
+	for _, entry := range pruned {
+		if err := b.remove(entry); err != nil {
+			return nil, err
+		}
+	}
+	return pruned, nil
+}
+
+// remove deletes a backup copy and its index entry
+func (b *BackupManager) remove(entry BackupEntry) error {
+	if err := os.RemoveAll(entry.BackupPath); err != nil {
+		return err
+	}
+	// the timestamped directories above it, once empty
+	for dir := filepath.Dir(entry.BackupPath); dir != b.backupDir && strings.HasPrefix(dir, b.backupDir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
 		}
 	}
 
+	b.dropVersion(entry.OriginalPath, entry.Version)
 	return b.saveIndex()
 }
 
+func (b *BackupManager) dropVersion(originalPath string, version int) {
+	versions := b.index.Versions[originalPath]
+	for i, entry := range versions {
+		if entry.Version == version {
+			versions = append(versions[:i:i], versions[i+1:]...)
+			break
+		}
+	}
+	if len(versions) == 0 {
+		delete(b.index.Versions, originalPath)
+	} else {
+		b.index.Versions[originalPath] = versions
+	}
+}
+
 func (b *BackupManager) saveIndex() error {
 	data, err := json.MarshalIndent(b.index, "", "  ")
 	if err != nil {
@@ -172,6 +377,69 @@ func (b *BackupManager) saveIndex() error {
 	return os.WriteFile(b.index.path, data, 0644)
 }
 
+// backupChecksum hashes a file, or a directory's relative paths and file
+// contents, and returns the total size. Symlinks are hashed by where they
+// point.
+func backupChecksum(path string) (string, int64, error) {
+	h := sha256.New()
+	var size int64
+
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			h.Write([]byte(rel + "/\n"))
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			h.Write([]byte(rel + " -> " + link + "\n"))
+			return nil
+		}
+
+		h.Write([]byte(rel + "\n"))
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		n, err := io.Copy(h, f)
+		size += n
+		return err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// ParseAge parses a duration that may also be given in days or weeks, such
+// as "30d" or "2w"
+func ParseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			days, err := strconv.Atoi(n)
+			if err != nil || days < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(days) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (expected e.g. 30d, 2w or 12h)", s)
+	}
+	return d, nil
+}
+
 func copyFile(src, dst string) error {
 	sourceFile, err := os.Open(src)
 	if err != nil {
@@ -193,24 +461,3 @@ func copyFile(src, dst string) error {
 	_, err = io.Copy(destFile, sourceFile)
 	return err
 }
-
-func copyDir(src, dst string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		relPath, err := filepath.Rel(src, path)
-		if err != nil {
-			return err
-		}
-
-		targetPath := filepath.Join(dst, relPath)
-
-		if info.IsDir() {
-			return os.MkdirAll(targetPath, info.Mode())
-		}
-
-		return copyFile(path, targetPath)
-	})
-}
@@ -0,0 +1,101 @@
+package linker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, path string)
+		check func(t *testing.T, path string)
+	}{
+		{
+			name: "file",
+			setup: func(t *testing.T, path string) {
+				writeTestFile(t, path, "original")
+			},
+			check: func(t *testing.T, path string) {
+				if got := readTestFile(t, path); got != "original" {
+					t.Errorf("content = %q, want original", got)
+				}
+			},
+		},
+		{
+			name: "live symlink",
+			setup: func(t *testing.T, path string) {
+				writeTestFile(t, path+".real", "real")
+				if err := os.Symlink(path+".real", path); err != nil {
+					t.Fatal(err)
+				}
+			},
+			check: func(t *testing.T, path string) {
+				if got, err := os.Readlink(path); err != nil || got != path+".real" {
+					t.Errorf("link = %q, %v, want a symlink to %s", got, err, path+".real")
+				}
+			},
+		},
+		{
+			name: "dangling symlink",
+			setup: func(t *testing.T, path string) {
+				if err := os.Symlink("/nonexistent", path); err != nil {
+					t.Fatal(err)
+				}
+			},
+			check: func(t *testing.T, path string) {
+				if got, err := os.Readlink(path); err != nil || got != "/nonexistent" {
+					t.Errorf("link = %q, %v, want a symlink to /nonexistent", got, err)
+				}
+			},
+		},
+		{
+			name: "directory with a symlink inside",
+			setup: func(t *testing.T, path string) {
+				writeTestFile(t, filepath.Join(path, "file"), "inside")
+				if err := os.Symlink("file", filepath.Join(path, "link")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			check: func(t *testing.T, path string) {
+				if got := readTestFile(t, filepath.Join(path, "file")); got != "inside" {
+					t.Errorf("file = %q, want inside", got)
+				}
+				if got, err := os.Readlink(filepath.Join(path, "link")); err != nil || got != "file" {
+					t.Errorf("link = %q, %v, want a symlink to file", got, err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBackupManager(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "target")
+			tt.setup(t, path)
+
+			if _, err := b.Backup(path); err != nil {
+				t.Fatalf("Backup() error = %v", err)
+			}
+			entry, ok := b.Latest(path)
+			if !ok {
+				t.Fatal("no backup recorded")
+			}
+			if err := b.Verify(entry); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+
+			if err := os.RemoveAll(path); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.RestoreVersion(path, entry.Version); err != nil {
+				t.Fatalf("RestoreVersion() error = %v", err)
+			}
+			tt.check(t, path)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package linker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Choices remembers how existing files at targets were handled, so later
+// applies do not ask again. Kept in <dataDir>/choices.json.
+type Choices struct {
+	path    string
+	Targets map[string]Resolution `json:"targets"`
+	pending bool                  // remembered during an apply, saved when it commits
+}
+
+func loadChoices(dataDir string) (*Choices, error) {
+	c := &Choices{
+		path:    filepath.Join(dataDir, "choices.json"),
+		Targets: make(map[string]Resolution),
+	}
+
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", c.path, err)
+	}
+	if c.Targets == nil {
+		c.Targets = make(map[string]Resolution)
+	}
+	return c, nil
+}
+
+// Get returns the remembered decision for target
+func (c *Choices) Get(target string) (Resolution, bool) {
+	r, ok := c.Targets[expandPath(target)]
+	return r, ok
+}
+
+// Set remembers r for target
+func (c *Choices) Set(target string, r Resolution) error {
+	c.Targets[expandPath(target)] = r
+	return c.save()
+}
+
+// remember records r for target without saving it; Commit saves it, and
+// Rollback drops it with the rest of the apply
+func (c *Choices) remember(target string, r Resolution) {
+	c.Targets[expandPath(target)] = r
+	c.pending = true
+}
+
+// Forget drops the remembered decision for target
+func (c *Choices) Forget(target string) error {
+	delete(c.Targets, expandPath(target))
+	return c.save()
+}
+
+func (c *Choices) save() error {
+	c.pending = false
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644)
+}
+
+// Incoming returns what the target would hold after linking: the rendered or
+// copied content, or the source for symlinks. Nil for directories.
+func (a *LinkAction) Incoming() ([]byte, error) {
+	if a.rendered != nil || a.IsDir {
+		return a.rendered, nil
+	}
+	return os.ReadFile(a.Source)
+}
+
+// CanAdopt reports whether the file at the target can be copied into the
+// repo in place of the source. Templates and encrypted files cannot.
+func (a *LinkAction) CanAdopt() bool {
+	return !a.IsDir && !a.IsTemplate && !a.IsEncrypted
+}
+
+// Incoming is LinkAction.Incoming for an action this linker planned. Plain
+// sources are read from where planning read them, which for a preview is
+// the checkout rather than the repo.
+func (s *SymlinkLinker) Incoming(action LinkAction) ([]byte, error) {
+	if action.rendered == nil && !action.IsDir {
+		action.Source = s.sourcePath(action.Source)
+	}
+	return action.Incoming()
+}
+
+// Asks reports whether executing action asks OnExisting about its target:
+// the file there would be backed up and no decision about it is remembered,
+// or reask is set.
+func (s *SymlinkLinker) Asks(action LinkAction, reask bool) bool {
+	if action.Action != ActionBackup || isSymlink(action.Target) {
+		return false
+	}
+	_, remembered := s.choices.Get(action.Target)
+	return !remembered || reask
+}
+
+// resolveExisting asks opts.OnExisting, or the remembered decision, what to
+// do with a file dotts would back up and replace. It returns true when the
+// file was kept. Adopting or merging updates the source, or for templates
+// and encrypted files the content written, and leaves the file to be backed
+// up and replaced as usual.
+func (s *SymlinkLinker) resolveExisting(action *LinkAction, entry *LinkEntry, opts LinkOptions, result *LinkResult) (bool, error) {
+	var local []byte
+	if !action.IsDir && !isDirPath(action.Target) {
+		var err error
+		if local, err = os.ReadFile(action.Target); err != nil {
+			return false, err
+		}
+	}
+
+	decision := Decision{}
+	if r, ok := s.choices.Get(action.Target); ok && !opts.Reask {
+		decision.Resolution = r
+	} else {
+		var err error
+		if decision, err = opts.OnExisting(*action, local); err != nil {
+			return false, err
+		}
+		if decision.Remember && (decision.Resolution == ResolveKeep || decision.Resolution == ResolveOverwrite) {
+			s.choices.remember(action.Target, decision.Resolution)
+		}
+	}
+
+	switch decision.Resolution {
+	case ResolveKeep:
+		result.Skipped = append(result.Skipped, action.Target)
+		return true, nil
+	case ResolveAdopt:
+		if !action.CanAdopt() || local == nil {
+			return false, fmt.Errorf("%s cannot be adopted into %s", action.Target, action.Source)
+		}
+		return false, s.updateSource(action, entry, local)
+	case ResolveMerge:
+		if local == nil {
+			return false, fmt.Errorf("%s is a directory and cannot be merged", action.Target)
+		}
+		if action.CanAdopt() {
+			return false, s.updateSource(action, entry, decision.Merged)
+		}
+		// the render stays as it is, so the next apply sees a local edit
+		action.rendered = decision.Merged
+		entry.TargetHash = contentHash(decision.Merged)
+		return false, nil
+	}
+	return false, nil
+}
+
+// updateSource writes content to the action's source in the config repo.
+// The old source is journaled, so a rollback puts it back.
+func (s *SymlinkLinker) updateSource(action *LinkAction, entry *LinkEntry, content []byte) error {
+	info, err := os.Stat(action.Source)
+	if err != nil {
+		return err
+	}
+	if err := s.journal.clear(action.Source); err != nil {
+		return err
+	}
+	if err := os.WriteFile(action.Source, content, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if action.rendered != nil {
+		action.rendered = content
+		entry.RenderedHash = contentHash(content)
+		entry.TargetHash = entry.RenderedHash
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package linker
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestAsks(t *testing.T) {
+	r := newTestRepo(t)
+	writeTestFile(t, r.target(".zshrc"), "local")
+	r.write("shell", ".zshrc", "zshrc")
+
+	action := r.plan("shell")[0]
+	if action.Action != ActionBackup {
+		t.Fatalf("Action = %s, want %s", action.Action, ActionBackup)
+	}
+	if !r.linker.Asks(action, false) {
+		t.Error("Asks() = false for an existing file without a remembered decision")
+	}
+
+	if err := r.linker.Choices().Set(r.target(".zshrc"), ResolveOverwrite); err != nil {
+		t.Fatal(err)
+	}
+	if r.linker.Asks(action, false) {
+		t.Error("Asks() = true for a remembered decision")
+	}
+	if !r.linker.Asks(action, true) {
+		t.Error("Asks() = false for a remembered decision with reask")
+	}
+
+	r.link("shell")
+	if action := r.plan("shell")[0]; r.linker.Asks(action, true) {
+		t.Errorf("Asks() = true for %s, which is already linked", action.Action)
+	}
+}
+
+func TestIncoming(t *testing.T) {
+	r := newTestRepo(t)
+	writeTestFile(t, r.target(".zshrc"), "local")
+	r.write("shell", ".zshrc", "zshrc")
+
+	checkout := t.TempDir()
+	writeTestFile(t, filepath.Join(checkout, "configs", "shell", ".zshrc"), "upstream")
+	r.linker.ReadSourcesFrom(checkout)
+
+	action := r.plan("shell")[0]
+	got, err := r.linker.Incoming(action)
+	if err != nil {
+		t.Fatalf("Incoming() error = %v", err)
+	}
+	if string(got) != "upstream" {
+		t.Errorf("Incoming() = %q, want the checkout's source", got)
+	}
+	if action.Source != filepath.Join(r.root, "configs", "shell", ".zshrc") {
+		t.Errorf("Source = %s, want the repo path", action.Source)
+	}
+}
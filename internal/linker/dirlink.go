@@ -0,0 +1,180 @@
+package linker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/crypt"
+)
+
+var errStopWalk = errors.New("stop walk")
+
+// linksWhole reports whether a config subdirectory is linked as one symlink
+// rather than file by file: it holds a LinkDirMarker or matches a link_dirs
+// glob. rel is slash separated and relative to the config directory.
+func linksWhole(dir, rel string, globs []string) (bool, error) {
+	if pathExists(filepath.Join(dir, config.LinkDirMarker)) {
+		return true, nil
+	}
+	for _, glob := range globs {
+		matched, err := path.Match(strings.Trim(glob, "/"), rel)
+		if err != nil {
+			return false, fmt.Errorf("link_dirs %q: %w", glob, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// wholeDirConflict returns why dir cannot be linked whole, or "" when it can.
+// Anything inside that needs per-file handling would be bypassed by a
+// directory symlink.
+func (s *SymlinkLinker) wholeDirConflict(configName, sourceRoot, dir string, ignore *config.Ignorer, opts LinkOptions) string {
+	var reason string
+
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sourceRoot, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rule, ok := ignore.Match(rel, info.IsDir()); ok && rule.Source != "default" {
+			reason = rel + " is ignored by " + rule.String()
+		} else if mode := opts.Modes.ModeFor(configName, rel); mode != ModeSymlink {
+			reason = fmt.Sprintf("%s uses %s mode", rel, mode)
+		} else if _, _, ok := config.SplitAlternate(info.Name()); ok {
+			reason = rel + " has alternates"
+		} else if info.IsDir() {
+			return nil
+		} else if crypt.IsEncrypted(info.Name()) {
+			reason = rel + " is encrypted"
+		} else if opts.Templates != nil && s.hasTemplates(p) {
+			reason = rel + " is a template"
+		}
+
+		if reason != "" {
+			return errStopWalk
+		}
+		return nil
+	})
+
+	return reason
+}
+
+// dirLinkAbove returns the managed whole-directory link that target would be
+// reached through. Links in split are being replaced and do not count.
+func (s *SymlinkLinker) dirLinkAbove(target string, split []string) (LinkEntry, bool) {
+	for dir := filepath.Dir(target); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if underAny(dir, split) {
+			return LinkEntry{}, false
+		}
+		if entry, ok := s.manifest.Get(dir); ok && entry.IsDir && isSymlink(dir) {
+			return entry, true
+		}
+	}
+	return LinkEntry{}, false
+}
+
+func insideDirLink(source, target string, owner LinkEntry) LinkAction {
+	return errorAction(LinkAction{Source: source, Target: target, IsDir: isDirPath(source)},
+		fmt.Errorf("inside %s, which is linked whole to %s", owner.Target, owner.Source))
+}
+
+// CheckDirLinks fails the actions that lie inside a directory another config
+// of the same plan links whole, since they would be written through that
+// link into its source. Configs are planned one at a time, so dirLinkAbove
+// only sees the directory links of earlier applies.
+func CheckDirLinks(actions []LinkAction) []LinkAction {
+	var dirs []LinkAction
+	for _, action := range actions {
+		if action.IsDir && linksDir(action.Action) {
+			dirs = append(dirs, action)
+		}
+	}
+
+	for i, action := range actions {
+		if action.Action == ActionError || action.Action == ActionIgnored {
+			continue
+		}
+		for _, dir := range dirs {
+			if action.Config != dir.Config && action.Target != dir.Target && Within(action.Target, dir.Target) {
+				failed := insideDirLink(action.Source, action.Target, LinkEntry{Source: dir.Source, Target: dir.Target})
+				failed.Config = action.Config
+				actions[i] = failed
+				break
+			}
+		}
+	}
+	return actions
+}
+
+// linksDir reports whether a directory action leaves a directory link at
+// its target
+func linksDir(kind ActionKind) bool {
+	switch kind {
+	case ActionCreate, ActionReplace, ActionBackup, ActionUnchanged:
+		return true
+	default:
+		return false
+	}
+}
+
+// onlyManagedLinks reports whether a real directory holds nothing but
+// unmodified links dotts created, so it can be replaced by a directory link
+func (s *SymlinkLinker) onlyManagedLinks(dir string) bool {
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		entry, ok := s.manifest.Get(p)
+		if !ok || !s.ownsTarget(entry) || (entry.tracksContent() && s.isModified(entry)) {
+			return errStopWalk
+		}
+		return nil
+	})
+	return err == nil
+}
+
+// split turns a whole-directory link into an empty real directory that
+// per-file links are then placed in
+func (s *SymlinkLinker) split(target string) error {
+	if !isSymlink(target) {
+		return fmt.Errorf("%s is no longer a directory link", target)
+	}
+	if err := s.journal.clear(target); err != nil {
+		return err
+	}
+	if err := s.journal.mkdirAll(target); err != nil {
+		return err
+	}
+	s.manifest.Remove(target)
+	return nil
+}
+
+// Within reports whether path is dir or below it
+func Within(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+func underAny(p string, dirs []string) bool {
+	for _, dir := range dirs {
+		if Within(p, dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package linker
+
+import "testing"
+
+func TestCheckDirLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []LinkAction
+		want    []ActionKind
+	}{
+		{
+			name: "file of another config inside a planned directory link",
+			actions: []LinkAction{
+				{Config: "nvim", Source: "/repo/configs/nvim/.config/nvim", Target: "/home/.config/nvim", IsDir: true, Action: ActionCreate},
+				{Config: "lsp", Source: "/repo/configs/lsp/.config/nvim/lsp.lua", Target: "/home/.config/nvim/lsp.lua", Action: ActionCreate},
+			},
+			want: []ActionKind{ActionCreate, ActionError},
+		},
+		{
+			name: "planned before the directory link",
+			actions: []LinkAction{
+				{Config: "lsp", Source: "/repo/configs/lsp/.config/nvim/lsp.lua", Target: "/home/.config/nvim/lsp.lua", Action: ActionCreate},
+				{Config: "nvim", Source: "/repo/configs/nvim/.config/nvim", Target: "/home/.config/nvim", IsDir: true, Action: ActionBackup},
+			},
+			want: []ActionKind{ActionError, ActionBackup},
+		},
+		{
+			name: "sibling with a shared prefix",
+			actions: []LinkAction{
+				{Config: "nvim", Source: "/repo/configs/nvim/.config/nvim", Target: "/home/.config/nvim", IsDir: true, Action: ActionCreate},
+				{Config: "lsp", Source: "/repo/configs/lsp/.config/nvim-lsp", Target: "/home/.config/nvim-lsp", Action: ActionCreate},
+			},
+			want: []ActionKind{ActionCreate, ActionCreate},
+		},
+		{
+			name: "directory that failed to plan",
+			actions: []LinkAction{
+				{Config: "nvim", Source: "/repo/configs/nvim/.config/nvim", Target: "/home/.config/nvim", IsDir: true, Action: ActionError},
+				{Config: "lsp", Source: "/repo/configs/lsp/.config/nvim/lsp.lua", Target: "/home/.config/nvim/lsp.lua", Action: ActionCreate},
+			},
+			want: []ActionKind{ActionError, ActionCreate},
+		},
+		{
+			name: "directory being split",
+			actions: []LinkAction{
+				{Config: "nvim", Source: "/repo/configs/nvim/.config/nvim", Target: "/home/.config/nvim", IsDir: true, Action: ActionSplit},
+				{Config: "lsp", Source: "/repo/configs/lsp/.config/nvim/lsp.lua", Target: "/home/.config/nvim/lsp.lua", Action: ActionCreate},
+			},
+			want: []ActionKind{ActionSplit, ActionCreate},
+		},
+		{
+			name: "ignored paths stay ignored",
+			actions: []LinkAction{
+				{Config: "nvim", Source: "/repo/configs/nvim/.config/nvim", Target: "/home/.config/nvim", IsDir: true, Action: ActionUnchanged},
+				{Config: "lsp", Source: "/repo/configs/lsp/.config/nvim/README.md", Target: "/home/.config/nvim/README.md", Action: ActionIgnored},
+			},
+			want: []ActionKind{ActionUnchanged, ActionIgnored},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs := make([]string, len(tt.actions))
+			for i, action := range tt.actions {
+				configs[i] = action.Config
+			}
+
+			got := CheckDirLinks(tt.actions)
+			for i, action := range got {
+				if action.Action != tt.want[i] {
+					t.Errorf("CheckDirLinks()[%d] = %s (%s), want %s", i, action.Action, action.Reason, tt.want[i])
+				}
+				if action.Config != configs[i] {
+					t.Errorf("CheckDirLinks()[%d] config = %q, want %q", i, action.Config, configs[i])
+				}
+			}
+		})
+	}
+}
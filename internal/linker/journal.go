@@ -0,0 +1,394 @@
+package linker
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// The journal of a running apply lives in <dataDir>/journal. Every change to
+// a target is written there before it is made, and the directory is removed
+// when the apply commits or rolls back, so finding one means a run crashed.
+const (
+	journalDir  = "journal"
+	journalFile = "journal.jsonl"
+)
+
+// Files restored as they were when the journal began
+var journalSnapshots = []string{"manifest.json", "backup-index.json"}
+
+// journalOp is a single journaled change and what is needed to undo it
+type journalOp struct {
+	Op    string      `json:"op"` // begin, create, replace, mkdir, chmod or backup
+	Path  string      `json:"path,omitempty"`
+	Stash string      `json:"stash,omitempty"` // where a replaced path was moved
+	Mode  os.FileMode `json:"mode,omitempty"`  // mode before a chmod
+	PID   int         `json:"pid,omitempty"`   // process that began the journal
+}
+
+type journal struct {
+	dir  string
+	file *os.File
+	ops  []journalOp
+}
+
+// beginJournal starts a journal, snapshotting the manifest and backup index
+func beginJournal(dataDir string) (*journal, error) {
+	dir := filepath.Join(dataDir, journalDir)
+	if pathExists(dir) {
+		return nil, fmt.Errorf("another apply is in progress (%s exists)", dir)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "stash"), 0700); err != nil {
+		return nil, err
+	}
+
+	for _, name := range journalSnapshots {
+		src := filepath.Join(dataDir, name)
+		if !pathExists(src) {
+			continue
+		}
+		if err := copyFile(src, filepath.Join(dir, name)); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	j := &journal{dir: dir, file: file}
+	if err := j.record(journalOp{Op: "begin", PID: os.Getpid()}); err != nil {
+		j.discard()
+		return nil, err
+	}
+	return j, nil
+}
+
+// record writes op to disk before the change it describes is made
+func (j *journal) record(op journalOp) error {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	j.ops = append(j.ops, op)
+	return nil
+}
+
+// clear empties path so it can be written. Whatever was there is moved into
+// the journal so a rollback can put it back. Without a journal it is deleted.
+func (j *journal) clear(path string) error {
+	if j == nil {
+		return os.RemoveAll(path)
+	}
+
+	if !pathExists(path) {
+		return j.record(journalOp{Op: "create", Path: path})
+	}
+
+	stash := filepath.Join(j.dir, "stash", strconv.Itoa(len(j.ops)))
+	if err := j.record(journalOp{Op: "replace", Path: path, Stash: stash}); err != nil {
+		return err
+	}
+	return moveAside(path, stash)
+}
+
+// mkdirAll creates dir and its missing parents, recording the topmost one
+func (j *journal) mkdirAll(dir string) error {
+	if j != nil {
+		if top := TopmostMissing(dir); top != "" {
+			if err := j.record(journalOp{Op: "mkdir", Path: top}); err != nil {
+				return err
+			}
+		}
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+func (j *journal) chmod(path string, mode os.FileMode) error {
+	if j != nil {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := j.record(journalOp{Op: "chmod", Path: path, Mode: info.Mode().Perm()}); err != nil {
+			return err
+		}
+	}
+	return os.Chmod(path, mode)
+}
+
+// backedUp records a backup copy so a rollback deletes it again
+func (j *journal) backedUp(backupPath string) error {
+	if j == nil {
+		return nil
+	}
+	return j.record(journalOp{Op: "backup", Path: backupPath})
+}
+
+// rollback undoes every recorded change, newest first, and restores the
+// manifest and backup index. It keeps going past failures so as much as
+// possible is restored; the changes it could not undo stay journaled for the
+// next attempt.
+func (j *journal) rollback(dataDir string) error {
+	var errs []error
+	var failed []journalOp
+	for i := len(j.ops) - 1; i >= 0; i-- {
+		if j.ops[i].Op == "begin" {
+			continue
+		}
+		if err := undo(j.ops[i]); err != nil {
+			errs = append(errs, err)
+			failed = append([]journalOp{j.ops[i]}, failed...)
+		}
+	}
+
+	for _, name := range journalSnapshots {
+		snapshot := filepath.Join(j.dir, name)
+		original := filepath.Join(dataDir, name)
+		var err error
+		if pathExists(snapshot) {
+			err = copyFile(snapshot, original)
+		} else {
+			err = os.Remove(original)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		if j.file != nil {
+			j.file.Close()
+		}
+		if err := writeJournal(j.dir, failed); err != nil {
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	}
+	return j.discard()
+}
+
+// writeJournal replaces the journal with ops, without an owning process
+func writeJournal(dir string, ops []journalOp) error {
+	var data []byte
+	for _, op := range append([]journalOp{{Op: "begin"}}, ops...) {
+		line, err := json.Marshal(op)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	tmp := filepath.Join(dir, journalFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, journalFile))
+}
+
+func undo(op journalOp) error {
+	switch op.Op {
+	case "create":
+		return os.RemoveAll(op.Path)
+	case "replace":
+		if !pathExists(op.Stash) {
+			// crashed before the original was moved, so it never left
+			return nil
+		}
+		if err := os.RemoveAll(op.Path); err != nil {
+			return err
+		}
+		// its parent may have been removed as an empty directory since
+		if err := os.MkdirAll(filepath.Dir(op.Path), 0755); err != nil {
+			return err
+		}
+		return moveAside(op.Stash, op.Path)
+	case "mkdir":
+		removeEmptyDirs(op.Path)
+		return nil
+	case "chmod":
+		err := os.Chmod(op.Path, op.Mode)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	case "backup":
+		if err := os.RemoveAll(op.Path); err != nil {
+			return err
+		}
+		// the directories above it up to the timestamped one, if nothing
+		// else was backed up into them
+		for dir := filepath.Dir(op.Path); os.Remove(dir) == nil; dir = filepath.Dir(dir) {
+			if filepath.Base(filepath.Dir(dir)) == "backups" {
+				break
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// discard removes the journal once its changes are final or undone
+func (j *journal) discard() error {
+	if j.file != nil {
+		j.file.Close()
+	}
+	return os.RemoveAll(j.dir)
+}
+
+// interruptedJournal reports whether dataDir holds the journal of a crashed run
+func interruptedJournal(dataDir string) bool {
+	dir := filepath.Join(dataDir, journalDir)
+	if !pathExists(dir) {
+		return false
+	}
+
+	j, err := readJournal(dir)
+	if err != nil {
+		return true
+	}
+	return len(j.ops) == 0 || j.ops[0].Op != "begin" || !processAlive(j.ops[0].PID)
+}
+
+// recoverJournal rolls back the journal of a crashed run. A journal whose
+// process is still running belongs to an apply in progress and is left alone.
+func recoverJournal(dataDir string) (bool, error) {
+	dir := filepath.Join(dataDir, journalDir)
+	if !pathExists(dir) {
+		return false, nil
+	}
+
+	j, err := readJournal(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read journal in %s: %w", dir, err)
+	}
+	if len(j.ops) > 0 && j.ops[0].Op == "begin" && processAlive(j.ops[0].PID) {
+		return false, nil
+	}
+
+	if err := j.rollback(dataDir); err != nil {
+		return false, fmt.Errorf("failed to roll back interrupted apply: %w", err)
+	}
+	return true, nil
+}
+
+func readJournal(dir string) (*journal, error) {
+	j := &journal{dir: dir}
+
+	f, err := os.Open(filepath.Join(dir, journalFile))
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var op journalOp
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			// a torn final line: its change was never started
+			break
+		}
+		j.ops = append(j.ops, op)
+	}
+	return j, scanner.Err()
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	if pid == os.Getpid() {
+		return true
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// moveAside renames src to dst, copying across filesystems. dst only
+// appears once it is complete.
+func moveAside(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	tmp := dst + ".tmp"
+	if err := CopyPath(src, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// CopyPath copies a file, directory or symlink without following symlinks
+func CopyPath(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, dst)
+	case info.IsDir():
+		if err := os.MkdirAll(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := CopyPath(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return copyFile(src, dst)
+	}
+}
+
+// removeEmptyDirs removes dir and the directories below it that are empty,
+// deepest first
+func removeEmptyDirs(dir string) {
+	var dirs []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+}
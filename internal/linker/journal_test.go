@@ -0,0 +1,504 @@
+package linker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// journalCase makes changes under root through j and checks that rolling
+// them back restores what setup created
+type journalCase struct {
+	name   string
+	setup  func(t *testing.T, root string)
+	change func(t *testing.T, j *journal, root string)
+	check  func(t *testing.T, root string)
+}
+
+var journalCases = []journalCase{
+	{
+		name: "created file is removed",
+		change: func(t *testing.T, j *journal, root string) {
+			path := filepath.Join(root, "new")
+			if err := j.clear(path); err != nil {
+				t.Fatal(err)
+			}
+			writeTestFile(t, path, "new")
+		},
+		check: func(t *testing.T, root string) {
+			if pathExists(filepath.Join(root, "new")) {
+				t.Error("created file was not removed")
+			}
+		},
+	},
+	{
+		name: "replaced file is restored",
+		setup: func(t *testing.T, root string) {
+			writeTestFile(t, filepath.Join(root, "file"), "original")
+		},
+		change: func(t *testing.T, j *journal, root string) {
+			path := filepath.Join(root, "file")
+			if err := j.clear(path); err != nil {
+				t.Fatal(err)
+			}
+			writeTestFile(t, path, "changed")
+		},
+		check: func(t *testing.T, root string) {
+			if got := readTestFile(t, filepath.Join(root, "file")); got != "original" {
+				t.Errorf("file = %q, want original", got)
+			}
+		},
+	},
+	{
+		name: "replaced directory is restored",
+		setup: func(t *testing.T, root string) {
+			writeTestFile(t, filepath.Join(root, "dir", "a"), "a")
+		},
+		change: func(t *testing.T, j *journal, root string) {
+			path := filepath.Join(root, "dir")
+			if err := j.clear(path); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink("/elsewhere", path); err != nil {
+				t.Fatal(err)
+			}
+		},
+		check: func(t *testing.T, root string) {
+			if got := readTestFile(t, filepath.Join(root, "dir", "a")); got != "a" {
+				t.Errorf("dir/a = %q, want a", got)
+			}
+		},
+	},
+	{
+		name: "replaced dangling symlink is restored as a symlink",
+		setup: func(t *testing.T, root string) {
+			if err := os.Symlink("/nonexistent", filepath.Join(root, "link")); err != nil {
+				t.Fatal(err)
+			}
+		},
+		change: func(t *testing.T, j *journal, root string) {
+			path := filepath.Join(root, "link")
+			if err := j.clear(path); err != nil {
+				t.Fatal(err)
+			}
+			writeTestFile(t, path, "file")
+		},
+		check: func(t *testing.T, root string) {
+			if got, err := os.Readlink(filepath.Join(root, "link")); err != nil || got != "/nonexistent" {
+				t.Errorf("link = %q, %v, want a symlink to /nonexistent", got, err)
+			}
+		},
+	},
+	{
+		name: "replaced file in a directory removed since is restored",
+		setup: func(t *testing.T, root string) {
+			writeTestFile(t, filepath.Join(root, "gone", "file"), "original")
+		},
+		change: func(t *testing.T, j *journal, root string) {
+			if err := j.clear(filepath.Join(root, "gone", "file")); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Remove(filepath.Join(root, "gone")); err != nil {
+				t.Fatal(err)
+			}
+		},
+		check: func(t *testing.T, root string) {
+			if got := readTestFile(t, filepath.Join(root, "gone", "file")); got != "original" {
+				t.Errorf("file = %q, want original", got)
+			}
+		},
+	},
+	{
+		name: "created directories are removed up to the existing parent",
+		setup: func(t *testing.T, root string) {
+			if err := os.Mkdir(filepath.Join(root, "kept"), 0755); err != nil {
+				t.Fatal(err)
+			}
+		},
+		change: func(t *testing.T, j *journal, root string) {
+			if err := j.mkdirAll(filepath.Join(root, "kept", "a", "b")); err != nil {
+				t.Fatal(err)
+			}
+		},
+		check: func(t *testing.T, root string) {
+			if pathExists(filepath.Join(root, "kept", "a")) {
+				t.Error("created directory was not removed")
+			}
+			if !pathExists(filepath.Join(root, "kept")) {
+				t.Error("existing directory was removed")
+			}
+		},
+	},
+	{
+		name: "permissions are restored",
+		setup: func(t *testing.T, root string) {
+			writeTestFile(t, filepath.Join(root, "file"), "x")
+		},
+		change: func(t *testing.T, j *journal, root string) {
+			if err := j.chmod(filepath.Join(root, "file"), 0600); err != nil {
+				t.Fatal(err)
+			}
+		},
+		check: func(t *testing.T, root string) {
+			info, err := os.Stat(filepath.Join(root, "file"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0644 {
+				t.Errorf("mode = %v, want 0644", info.Mode().Perm())
+			}
+		},
+	},
+	{
+		name: "backups are deleted with their empty directories",
+		setup: func(t *testing.T, root string) {
+			writeTestFile(t, filepath.Join(root, "backups", "1", "other"), "older backup")
+		},
+		change: func(t *testing.T, j *journal, root string) {
+			path := filepath.Join(root, "backups", "2", "home", "file")
+			if err := j.backedUp(path); err != nil {
+				t.Fatal(err)
+			}
+			writeTestFile(t, path, "backup")
+		},
+		check: func(t *testing.T, root string) {
+			if pathExists(filepath.Join(root, "backups", "2")) {
+				t.Error("backup directory was not removed")
+			}
+			if !pathExists(filepath.Join(root, "backups", "1", "other")) {
+				t.Error("older backup was removed")
+			}
+		},
+	},
+	{
+		name: "changes are undone newest first",
+		setup: func(t *testing.T, root string) {
+			writeTestFile(t, filepath.Join(root, "file"), "original")
+		},
+		change: func(t *testing.T, j *journal, root string) {
+			path := filepath.Join(root, "file")
+			for _, content := range []string{"first", "second"} {
+				if err := j.clear(path); err != nil {
+					t.Fatal(err)
+				}
+				writeTestFile(t, path, content)
+			}
+		},
+		check: func(t *testing.T, root string) {
+			if got := readTestFile(t, filepath.Join(root, "file")); got != "original" {
+				t.Errorf("file = %q, want original", got)
+			}
+		},
+	},
+}
+
+func TestJournalRollback(t *testing.T) {
+	for _, tt := range journalCases {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir, root := t.TempDir(), t.TempDir()
+			if tt.setup != nil {
+				tt.setup(t, root)
+			}
+
+			j, err := beginJournal(dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(t, j, root)
+			if err := j.rollback(dataDir); err != nil {
+				t.Fatalf("rollback() error = %v", err)
+			}
+
+			tt.check(t, root)
+			if pathExists(filepath.Join(dataDir, journalDir)) {
+				t.Error("journal was not removed after rollback")
+			}
+		})
+	}
+}
+
+// crash leaves j behind as a process that died mid-apply would
+func crash(t *testing.T, j *journal) {
+	t.Helper()
+	j.file.Close()
+	if err := writeJournal(j.dir, j.ops[1:]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoverJournal(t *testing.T) {
+	for _, tt := range journalCases {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir, root := t.TempDir(), t.TempDir()
+			if tt.setup != nil {
+				tt.setup(t, root)
+			}
+
+			j, err := beginJournal(dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.change(t, j, root)
+			crash(t, j)
+
+			recovered, err := recoverJournal(dataDir)
+			if err != nil {
+				t.Fatalf("recoverJournal() error = %v", err)
+			}
+			if !recovered {
+				t.Fatal("recoverJournal() did not recover the crashed apply")
+			}
+			tt.check(t, root)
+			if pathExists(filepath.Join(dataDir, journalDir)) {
+				t.Error("journal was not removed after recovery")
+			}
+		})
+	}
+}
+
+func TestRecoverJournalSnapshots(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing bool
+	}{
+		{name: "manifest is restored", existing: true},
+		{name: "manifest written during the apply is removed", existing: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			manifest := filepath.Join(dataDir, "manifest.json")
+			if tt.existing {
+				writeTestFile(t, manifest, "before")
+			}
+
+			j, err := beginJournal(dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeTestFile(t, manifest, "during")
+			crash(t, j)
+
+			if _, err := recoverJournal(dataDir); err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.existing && readTestFile(t, manifest) != "before":
+				t.Errorf("manifest = %q, want before", readTestFile(t, manifest))
+			case !tt.existing && pathExists(manifest):
+				t.Error("manifest written during the apply was kept")
+			}
+		})
+	}
+}
+
+func TestRecoverJournalLeaves(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, dataDir, file string)
+	}{
+		{
+			name:  "no journal",
+			setup: func(t *testing.T, dataDir, file string) {},
+		},
+		{
+			name: "a running apply",
+			setup: func(t *testing.T, dataDir, file string) {
+				j, err := beginJournal(dataDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { j.discard() })
+				if err := j.clear(file); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir, root := t.TempDir(), t.TempDir()
+			file := filepath.Join(root, "file")
+			tt.setup(t, dataDir, file)
+
+			recovered, err := recoverJournal(dataDir)
+			if err != nil {
+				t.Fatalf("recoverJournal() error = %v", err)
+			}
+			if recovered {
+				t.Error("recoverJournal() rolled back")
+			}
+		})
+	}
+}
+
+func TestOpenSymlinkLinkerLeavesCrashedApply(t *testing.T) {
+	dataDir, root := t.TempDir(), t.TempDir()
+	created := filepath.Join(root, "created")
+
+	j, err := beginJournal(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.clear(created); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, created, "x")
+	crash(t, j)
+
+	opened, err := OpenSymlinkLinker(dataDir, root)
+	if err != nil {
+		t.Fatalf("OpenSymlinkLinker() error = %v", err)
+	}
+	if !opened.Interrupted() {
+		t.Error("Interrupted() = false, want the crashed apply reported")
+	}
+	if !pathExists(created) || !pathExists(filepath.Join(dataDir, journalDir)) {
+		t.Fatal("OpenSymlinkLinker() rolled back the crashed apply")
+	}
+
+	recovered, err := NewSymlinkLinker(dataDir, root)
+	if err != nil {
+		t.Fatalf("NewSymlinkLinker() error = %v", err)
+	}
+	if !recovered.Recovered() || recovered.Interrupted() {
+		t.Errorf("NewSymlinkLinker() Recovered() = %v, Interrupted() = %v, want true, false", recovered.Recovered(), recovered.Interrupted())
+	}
+	if pathExists(created) {
+		t.Error("NewSymlinkLinker() did not roll back the crashed apply")
+	}
+}
+
+func TestRecoverJournalTornLine(t *testing.T) {
+	dataDir, root := t.TempDir(), t.TempDir()
+	created := filepath.Join(root, "created")
+
+	j, err := beginJournal(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.clear(created); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, created, "x")
+	crash(t, j)
+
+	f, err := os.OpenFile(filepath.Join(j.dir, journalFile), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"replace","pa`)
+	f.Close()
+
+	if _, err := recoverJournal(dataDir); err != nil {
+		t.Fatalf("recoverJournal() error = %v", err)
+	}
+	if pathExists(created) {
+		t.Error("change before the torn line was not undone")
+	}
+}
+
+func TestUndo(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    func(root string) journalOp
+		setup func(t *testing.T, root string)
+		check func(t *testing.T, root string)
+	}{
+		{
+			name: "replace whose original never moved",
+			op: func(root string) journalOp {
+				return journalOp{Op: "replace", Path: filepath.Join(root, "file"), Stash: filepath.Join(root, "stash")}
+			},
+			setup: func(t *testing.T, root string) {
+				writeTestFile(t, filepath.Join(root, "file"), "original")
+			},
+			check: func(t *testing.T, root string) {
+				if got := readTestFile(t, filepath.Join(root, "file")); got != "original" {
+					t.Errorf("file = %q, want original", got)
+				}
+			},
+		},
+		{
+			name: "chmod of a removed file",
+			op: func(root string) journalOp {
+				return journalOp{Op: "chmod", Path: filepath.Join(root, "gone"), Mode: 0644}
+			},
+		},
+		{
+			name: "mkdir that is no longer empty",
+			op: func(root string) journalOp {
+				return journalOp{Op: "mkdir", Path: filepath.Join(root, "dir")}
+			},
+			setup: func(t *testing.T, root string) {
+				writeTestFile(t, filepath.Join(root, "dir", "sub", "user-file"), "x")
+				if err := os.Mkdir(filepath.Join(root, "dir", "empty"), 0755); err != nil {
+					t.Fatal(err)
+				}
+			},
+			check: func(t *testing.T, root string) {
+				if !pathExists(filepath.Join(root, "dir", "sub", "user-file")) {
+					t.Error("file inside the created directory was removed")
+				}
+				if pathExists(filepath.Join(root, "dir", "empty")) {
+					t.Error("empty directory was kept")
+				}
+			},
+		},
+		{
+			name: "unknown op",
+			op: func(root string) journalOp {
+				return journalOp{Op: "begin"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if tt.setup != nil {
+				tt.setup(t, root)
+			}
+			if err := undo(tt.op(root)); err != nil {
+				t.Fatalf("undo() error = %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, root)
+			}
+		})
+	}
+}
+
+func TestBeginJournalRefusesConcurrentApply(t *testing.T) {
+	dataDir := t.TempDir()
+	j, err := beginJournal(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.discard()
+
+	if _, err := beginJournal(dataDir); err == nil {
+		t.Error("beginJournal() started a second journal")
+	}
+}
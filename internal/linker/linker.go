@@ -1,10 +1,15 @@
 package linker
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/crypt"
+	"github.com/arthur404dev/dotts/internal/template"
 )
 
 type Linker interface {
@@ -15,24 +20,125 @@ type Linker interface {
 }
 
 type LinkEntry struct {
-	Source     string    `json:"source"`
-	Target     string    `json:"target"`
-	CreatedAt  time.Time `json:"created_at"`
-	IsDir      bool      `json:"is_dir"`
-	IsTemplate bool      `json:"is_template,omitempty"`
+	Source      string    `json:"source"`
+	Target      string    `json:"target"`
+	CreatedAt   time.Time `json:"created_at"`
+	IsDir       bool      `json:"is_dir"`
+	IsTemplate  bool      `json:"is_template,omitempty"`
+	IsEncrypted bool      `json:"is_encrypted,omitempty"` // decrypted from a .age source
+	Variant     string    `json:"variant,omitempty"`      // alternate suffix chosen for this target
+	Mode        LinkMode  `json:"mode,omitempty"`         // copy or hardlink; empty for symlinks and rendered files
+
+	// For templates, encrypted files and copies: the hash of the last render
+	// and of the content left on disk. They differ when local edits were kept
+	// or merged.
+	RenderedHash string `json:"rendered_hash,omitempty"`
+	TargetHash   string `json:"target_hash,omitempty"`
+}
+
+// tracksContent reports whether the target is a real file tracked by content
+// hash rather than a symlink
+func (e LinkEntry) tracksContent() bool {
+	return e.IsTemplate || e.IsEncrypted || e.Mode != ""
+}
+
+// ActionKind describes what applying a link would do to its target
+type ActionKind string
+
+const (
+	ActionCreate    ActionKind = "create"    // target does not exist
+	ActionReplace   ActionKind = "replace"   // target is ours (or forced) and will be replaced
+	ActionBackup    ActionKind = "backup"    // target is foreign and will be backed up, then replaced
+	ActionUnchanged ActionKind = "unchanged" // target is already up to date
+	ActionSkip      ActionKind = "skip"      // target is foreign and will be left alone
+	ActionModified  ActionKind = "modified"  // rendered target was edited locally and needs a decision
+	ActionSplit     ActionKind = "split"     // a whole-directory link is replaced by a directory of per-file links
+	ActionPrune     ActionKind = "prune"     // managed target is no longer planned and will be removed
+	ActionIgnored   ActionKind = "ignored"   // source matches an ignore rule and is never linked
+	ActionError     ActionKind = "error"     // the link could not be planned
+)
+
+// LinkAction is a single planned change to a target path
+type LinkAction struct {
+	Config      string      `json:"config,omitempty"`
+	Source      string      `json:"source"`
+	Target      string      `json:"target"`
+	Action      ActionKind  `json:"action"`
+	Reason      string      `json:"reason,omitempty"`
+	IsDir       bool        `json:"is_dir,omitempty"`
+	IsTemplate  bool        `json:"is_template,omitempty"`
+	IsEncrypted bool        `json:"is_encrypted,omitempty"`
+	Variant     string      `json:"variant,omitempty"`
+	Mode        LinkMode    `json:"mode,omitempty"`
+	Perm        os.FileMode `json:"perm,omitempty"` // applied to the target when set
+	Warnings    []string    `json:"warnings,omitempty"`
+	Diff        string      `json:"diff,omitempty"` // never set for encrypted files
+
+	rendered []byte
+	err      error
 }
 
+// Changes returns true if executing the action modifies the filesystem
+func (a *LinkAction) Changes() bool {
+	switch a.Action {
+	case ActionCreate, ActionReplace, ActionBackup, ActionModified, ActionSplit, ActionPrune:
+		return true
+	default:
+		return false
+	}
+}
+
+// Rendered returns the rendered template or decrypted content, or nil for
+// plain links
+func (a *LinkAction) Rendered() []byte {
+	return a.rendered
+}
+
+func (a *LinkAction) tracksContent() bool {
+	return a.IsTemplate || a.IsEncrypted || a.Mode != ""
+}
+
+// Resolution is how a locally modified or pre-existing target is handled
+type Resolution string
+
+const (
+	ResolveOverwrite Resolution = "overwrite"
+	ResolveKeep      Resolution = "keep"
+	ResolveMerge     Resolution = "merge"
+	ResolveAdopt     Resolution = "adopt" // copy the local file into the repo
+)
+
+// ConflictResolver decides what happens to a locally modified target. For
+// ResolveMerge it also returns the merged content to write.
+type ConflictResolver func(action LinkAction, local []byte) (Resolution, []byte, error)
+
+// Decision is how an existing file at a target is handled. Remember keeps a
+// keep or overwrite decision for later applies.
+type Decision struct {
+	Resolution Resolution
+	Merged     []byte // content for ResolveMerge
+	Remember   bool
+}
+
+// ExistingResolver decides what happens to a file dotts does not manage at a
+// target it is about to replace. local is nil for directories.
+type ExistingResolver func(action LinkAction, local []byte) (Decision, error)
+
 type LinkStatus struct {
-	Links   []LinkEntry
-	Broken  []string
-	Foreign []string
+	Links    []LinkEntry
+	Broken   []string
+	Foreign  []string
+	Modified []string // rendered or copied targets edited since the last apply
+	Stale    []string // copied targets whose source changed since the last apply
 }
 
 type LinkResult struct {
-	Linked  []LinkEntry
-	Skipped []string
-	Backed  []string
-	Errors  []LinkError
+	Linked   []LinkEntry
+	Skipped  []string
+	Backed   []string
+	Pruned   []string
+	Restored []string // pruned targets whose original came back from backup
+	Errors   []LinkError
 }
 
 func (r *LinkResult) Success() bool {
@@ -72,11 +178,20 @@ const (
 type ProgressCallback func(progress LinkProgress)
 
 type LinkOptions struct {
-	DryRun         bool
-	Force          bool
-	Backup         bool
-	Progress       ProgressCallback
-	TemplateValues map[string]string
+	DryRun     bool
+	Force      bool
+	Backup     bool
+	Progress   ProgressCallback
+	Templates  *template.Engine          // renders template files; nil links them as-is
+	Alternates *config.AlternateResolver // picks between ##-suffixed variants
+	OnModified ConflictResolver          // decides about locally edited renders; nil leaves them alone
+	OnExisting ExistingResolver          // decides about existing files at targets; nil backs them up or replaces them
+	Reask      bool                      // asks OnExisting again about targets with a remembered decision
+	Decrypter  *crypt.Age                // decrypts .age files; nil fails them
+	Modes      ModeRules                 // per-config and per-path link modes; nil symlinks everything
+	Context    context.Context           // stops Execute before the next action once done; nil never stops
+
+	split []string // whole-directory links this plan turns into real directories
 }
 
 func DefaultLinkOptions() LinkOptions {
@@ -119,6 +234,14 @@ func readLink(path string) (string, error) {
 	return os.Readlink(path)
 }
 
+func isDirPath(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	return info.IsDir()
+}
+
 func isDir(path string) bool {
 	info, err := os.Stat(path)
 	if err != nil {
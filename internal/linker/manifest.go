@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 )
 
@@ -52,6 +53,18 @@ func (m *Manifest) Remove(target string) {
 	delete(m.entries, target)
 }
 
+// RemoveBelow drops the entries for every target inside dir
+func (m *Manifest) RemoveBelow(dir string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prefix := dir + string(filepath.Separator)
+	for target := range m.entries {
+		if strings.HasPrefix(target, prefix) {
+			delete(m.entries, target)
+		}
+	}
+}
+
 func (m *Manifest) HasEntry(target string) bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
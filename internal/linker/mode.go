@@ -0,0 +1,116 @@
+package linker
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// LinkMode is how a source file is placed at its target
+type LinkMode string
+
+const (
+	ModeSymlink  LinkMode = "symlink"  // target is a symlink to the source (default)
+	ModeCopy     LinkMode = "copy"     // target is a copy, tracked by content hash
+	ModeHardlink LinkMode = "hardlink" // target is a hard link to the source
+)
+
+// ParseLinkMode validates a mode name; "" selects symlink
+func ParseLinkMode(s string) (LinkMode, error) {
+	switch m := LinkMode(strings.ToLower(s)); m {
+	case "":
+		return ModeSymlink, nil
+	case ModeSymlink, ModeCopy, ModeHardlink:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown link mode %q (expected symlink, copy or hardlink)", s)
+	}
+}
+
+// ModeRules maps a config name, or a config-relative path or glob such as
+// "terminal/.config/kitty/*.conf", to a link mode
+type ModeRules map[string]LinkMode
+
+// ParseModeRules validates link modes declared in profiles
+func ParseModeRules(modes map[string]string) (ModeRules, error) {
+	rules := make(ModeRules, len(modes))
+	for key, value := range modes {
+		mode, err := ParseLinkMode(value)
+		if err != nil {
+			return nil, fmt.Errorf("link_modes %s: %w", key, err)
+		}
+		rules[strings.Trim(key, "/")] = mode
+	}
+	return rules, nil
+}
+
+// ModeFor returns the mode for rel (slash separated, relative to the config
+// directory). The most specific rule wins: a path or glob beats the config
+// rule, and longer patterns beat shorter ones. A pattern that names a
+// directory covers everything below it.
+func (r ModeRules) ModeFor(config, rel string) LinkMode {
+	mode := ModeSymlink
+	if m, ok := r[config]; ok {
+		mode = m
+	}
+
+	best := ""
+	for key, m := range r {
+		pattern, ok := strings.CutPrefix(key, config+"/")
+		if !ok || !matchPath(pattern, rel) {
+			continue
+		}
+		if best == "" || moreSpecific(pattern, best) {
+			mode, best = m, pattern
+		}
+	}
+
+	return mode
+}
+
+// WithConfigDefaults adds a config's own link_mode and link_modes beneath r;
+// rules already in r win
+func (r ModeRules) WithConfigDefaults(config string, mode string, modes map[string]string) (ModeRules, error) {
+	defaults := make(map[string]string, len(modes)+1)
+	if mode != "" {
+		defaults[config] = mode
+	}
+	for pattern, m := range modes {
+		defaults[config+"/"+strings.Trim(pattern, "/")] = m
+	}
+
+	merged, err := ParseModeRules(defaults)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", config, err)
+	}
+	for key, m := range r {
+		merged[key] = m
+	}
+	return merged, nil
+}
+
+// matchPath reports whether a slash-separated glob matches rel or one of its
+// parent directories
+func matchPath(pattern, rel string) bool {
+	if matched, _ := path.Match(pattern, rel); matched {
+		return true
+	}
+	return strings.HasPrefix(rel, pattern+"/")
+}
+
+// moreSpecific reports whether pattern is a more specific rule than other:
+// longer, or as long and a plain path where other is a glob. Remaining ties
+// go to the first in sort order, so the choice never depends on map order.
+func moreSpecific(pattern, other string) bool {
+	if len(pattern) != len(other) {
+		return len(pattern) > len(other)
+	}
+	if glob, otherGlob := isGlob(pattern), isGlob(other); glob != otherGlob {
+		return !glob
+	}
+	return pattern < other
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
@@ -0,0 +1,142 @@
+package linker
+
+import "testing"
+
+func TestParseLinkMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    LinkMode
+		wantErr bool
+	}{
+		{in: "", want: ModeSymlink},
+		{in: "symlink", want: ModeSymlink},
+		{in: "Copy", want: ModeCopy},
+		{in: "hardlink", want: ModeHardlink},
+		{in: "move", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLinkMode(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLinkMode(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLinkMode(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModeRulesModeFor(t *testing.T) {
+	rules, err := ParseModeRules(map[string]string{
+		"ssh":                           "copy",
+		"terminal/.config/kitty/":       "copy",
+		"terminal/.config/kitty/*.conf": "hardlink",
+		"terminal/.config/kitty/a.conf": "symlink",
+		"terminal/.config/kitty/b.conf": "copy",
+		"terminal/.config/kitty/?.c":    "hardlink",
+		"terminal/.config/kitty/a.c":    "copy",
+		"terminal/.config/*/theme":      "copy",
+		"shell/.zshrc":                  "copy",
+		"shellx":                        "hardlink",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		config string
+		rel    string
+		want   LinkMode
+	}{
+		{config: "other", rel: "file", want: ModeSymlink},
+		{config: "ssh", rel: "config", want: ModeCopy},
+		{config: "ssh", rel: ".ssh/known_hosts", want: ModeCopy},
+		{config: "terminal", rel: ".bashrc", want: ModeSymlink},
+		{config: "terminal", rel: ".config/kitty/themes/dark", want: ModeCopy},
+		{config: "terminal", rel: ".config/kitty/kitty.conf", want: ModeHardlink},
+		{config: "terminal", rel: ".config/kitty/a.conf", want: ModeSymlink},
+		{config: "terminal", rel: ".config/kitty/b.conf", want: ModeCopy},
+		{config: "terminal", rel: ".config/kitty/a.c", want: ModeCopy},
+		{config: "terminal", rel: ".config/kitty/b.c", want: ModeHardlink},
+		{config: "terminal", rel: ".config/alacritty/theme", want: ModeCopy},
+		{config: "shell", rel: ".zshrc", want: ModeCopy},
+		{config: "shell", rel: ".zshrc.local", want: ModeSymlink},
+		{config: "shellx", rel: ".zshrc", want: ModeHardlink},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.config+"/"+tt.rel, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				if got := rules.ModeFor(tt.config, tt.rel); got != tt.want {
+					t.Fatalf("ModeFor(%q, %q) = %q, want %q", tt.config, tt.rel, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestModeRulesWithConfigDefaults(t *testing.T) {
+	profile, err := ParseModeRules(map[string]string{
+		"terminal/.config/kitty": "hardlink",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		mode    string
+		modes   map[string]string
+		rel     string
+		want    LinkMode
+		wantErr bool
+	}{
+		{name: "config default", mode: "copy", rel: ".bashrc", want: ModeCopy},
+		{name: "config pattern", mode: "copy", modes: map[string]string{"/.local/bin/": "symlink"}, rel: ".local/bin/x", want: ModeSymlink},
+		{name: "profile wins over the config", mode: "copy", modes: map[string]string{".config/kitty": "symlink"}, rel: ".config/kitty/kitty.conf", want: ModeHardlink},
+		{name: "no defaults", rel: ".bashrc", want: ModeSymlink},
+		{name: "invalid mode", mode: "move", wantErr: true},
+		{name: "invalid pattern mode", modes: map[string]string{"x": "move"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := profile.WithConfigDefaults("terminal", tt.mode, tt.modes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithConfigDefaults() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := merged.ModeFor("terminal", tt.rel); got != tt.want {
+				t.Errorf("ModeFor(%q) = %q, want %q", tt.rel, got, tt.want)
+			}
+		})
+	}
+
+	if len(profile) != 1 {
+		t.Errorf("WithConfigDefaults changed the profile rules: %v", profile)
+	}
+}
+
+func TestMoreSpecific(t *testing.T) {
+	tests := []struct {
+		pattern, other string
+		want           bool
+	}{
+		{pattern: "a/b/c", other: "a/b", want: true},
+		{pattern: "a/b", other: "a/b/c", want: false},
+		{pattern: "a/x.sh", other: "a/*.sh", want: true},
+		{pattern: "a/*.sh", other: "a/x.sh", want: false},
+		{pattern: "a/?x.sh", other: "a/?y.sh", want: true},
+		{pattern: "a/?y.sh", other: "a/?x.sh", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := moreSpecific(tt.pattern, tt.other); got != tt.want {
+			t.Errorf("moreSpecific(%q, %q) = %v, want %v", tt.pattern, tt.other, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package linker
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PermRules maps config-relative paths and globs to file modes
+type PermRules map[string]os.FileMode
+
+// ParsePermRules validates octal modes such as "0600"
+func ParsePermRules(perms map[string]string) (PermRules, error) {
+	rules := make(PermRules, len(perms))
+	for pattern, value := range perms {
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil || mode > 0o777 {
+			return nil, fmt.Errorf("permissions %s: invalid mode %q (expected octal, e.g. 0600)", pattern, value)
+		}
+		rules[strings.Trim(pattern, "/")] = os.FileMode(mode)
+	}
+	return rules, nil
+}
+
+// PermFor returns the mode for rel from the most specific matching rule, or
+// 0 when no rule matches
+func (r PermRules) PermFor(rel string) os.FileMode {
+	var perm os.FileMode
+	best := ""
+	for pattern, mode := range r {
+		if matchPath(pattern, rel) && (best == "" || moreSpecific(pattern, best)) {
+			perm, best = mode, pattern
+		}
+	}
+	return perm
+}
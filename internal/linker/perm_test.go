@@ -0,0 +1,84 @@
+package linker
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParsePermRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		perms   map[string]string
+		want    PermRules
+		wantErr bool
+	}{
+		{name: "octal", perms: map[string]string{".ssh/": "0700", ".ssh/config": "600"}, want: PermRules{".ssh": 0700, ".ssh/config": 0600}},
+		{name: "leading slash", perms: map[string]string{"/bin/*": "0755"}, want: PermRules{"bin/*": 0755}},
+		{name: "not octal", perms: map[string]string{"x": "0800"}, wantErr: true},
+		{name: "symbolic", perms: map[string]string{"x": "u+x"}, wantErr: true},
+		{name: "special bits", perms: map[string]string{"x": "4755"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePermRules(tt.perms)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePermRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParsePermRules() = %v, want %v", got, tt.want)
+			}
+			for pattern, mode := range tt.want {
+				if got[pattern] != mode {
+					t.Errorf("rule %s = %v, want %v", pattern, got[pattern], mode)
+				}
+			}
+		})
+	}
+}
+
+func TestPermRulesPermFor(t *testing.T) {
+	rules, err := ParsePermRules(map[string]string{
+		".ssh":             "0700",
+		".ssh/*":           "0600",
+		".ssh/config.d":    "0750",
+		".ssh/id_*.pub":    "0644",
+		".local/bin/?.sh":  "0700",
+		".local/bin/a.sh":  "0755",
+		".local/bin/[bc]x": "0711",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rel  string
+		want os.FileMode
+	}{
+		{rel: ".bashrc", want: 0},
+		{rel: ".ssh", want: 0700},
+		{rel: ".ssh/config", want: 0600},
+		{rel: ".ssh/config.d", want: 0750},
+		{rel: ".ssh/config.d/work", want: 0750},
+		{rel: ".ssh/known/hosts", want: 0700},
+		{rel: ".ssh/id_ed25519", want: 0600},
+		{rel: ".ssh/id_ed25519.pub", want: 0644},
+		{rel: ".local/bin/a.sh", want: 0755},
+		{rel: ".local/bin/b.sh", want: 0700},
+		{rel: ".local/bin/bx", want: 0711},
+		{rel: ".local/bin/dx", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				if got := rules.PermFor(tt.rel); got != tt.want {
+					t.Fatalf("PermFor(%q) = %v, want %v", tt.rel, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package linker
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConfigOf returns the config an entry was linked from, or "" when its
+// source lies outside this repo's configs/ directory
+func (s *SymlinkLinker) ConfigOf(entry LinkEntry) string {
+	rel, err := filepath.Rel(filepath.Join(s.configRoot, "configs"), entry.Source)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
+}
+
+// PlanPrune returns a prune action for every managed target of a config in
+// scope that planned no longer links. Ignored paths count as no longer
+// linked; targets that failed to plan, and anything inside a directory that
+// is planned as one link, are kept. Entries from outside the repo are never
+// pruned.
+func (s *SymlinkLinker) PlanPrune(planned []LinkAction, inScope func(config string) bool) []LinkAction {
+	keep := make(map[string]bool, len(planned))
+	var dirs []string
+	configs := make(map[string]bool)
+	for _, action := range planned {
+		configs[action.Config] = true
+		if action.Action == ActionIgnored {
+			continue
+		}
+		keep[action.Target] = true
+		if action.IsDir && action.Action != ActionSplit {
+			dirs = append(dirs, action.Target)
+		}
+	}
+
+	var actions []LinkAction
+	for _, entry := range s.manifest.Entries() {
+		configName := s.ConfigOf(entry)
+		if configName == "" || !inScope(configName) {
+			continue
+		}
+		if keep[entry.Target] || underAny(filepath.Dir(entry.Target), dirs) {
+			continue
+		}
+
+		action := LinkAction{
+			Config: configName,
+			Source: entry.Source,
+			Target: entry.Target,
+			IsDir:  entry.IsDir,
+			Action: ActionPrune,
+		}
+		switch {
+		case !pathExists(s.sourcePath(entry.Source)):
+			action.Reason = "source was removed"
+		case !configs[configName]:
+			action.Reason = "config " + configName + " is no longer applied"
+		default:
+			action.Reason = "no longer linked"
+		}
+		switch {
+		case !s.ownsTarget(entry):
+			action.Reason += ", target was replaced and is left in place"
+		case entry.tracksContent() && s.isModified(entry):
+			action.Reason += ", modified locally and left in place"
+		case s.backup.HasBackup(entry.Target):
+			action.Reason += ", original restored from backup"
+		}
+		actions = append(actions, action)
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		if actions[i].Config != actions[j].Config {
+			return actions[i].Config < actions[j].Config
+		}
+		return actions[i].Target < actions[j].Target
+	})
+	return actions
+}
@@ -0,0 +1,248 @@
+package linker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testRepo is a config repo, a home directory and a linker between them
+type testRepo struct {
+	t      *testing.T
+	root   string
+	home   string
+	linker *SymlinkLinker
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	root := t.TempDir()
+	s, err := NewSymlinkLinker(t.TempDir(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testRepo{t: t, root: root, home: home, linker: s}
+}
+
+// write creates a file of config at rel, relative to the config directory
+func (r *testRepo) write(config, rel, content string) string {
+	path := filepath.Join(r.root, "configs", config, rel)
+	writeTestFile(r.t, path, content)
+	return path
+}
+
+// plan plans configs, in order
+func (r *testRepo) plan(configs ...string) []LinkAction {
+	r.t.Helper()
+	var actions []LinkAction
+	for _, name := range configs {
+		planned, err := r.linker.PlanConfig(name, DefaultLinkOptions())
+		if err != nil {
+			r.t.Fatalf("PlanConfig(%s) error = %v", name, err)
+		}
+		actions = append(actions, planned...)
+	}
+	return actions
+}
+
+// execute runs actions and fails the test on any error
+func (r *testRepo) execute(actions []LinkAction) *LinkResult {
+	r.t.Helper()
+	result := r.linker.Execute(actions, DefaultLinkOptions())
+	for _, e := range result.Errors {
+		r.t.Fatalf("Execute() error for %s: %v", e.Target, e.Err)
+	}
+	return result
+}
+
+// link plans and applies configs in one transaction
+func (r *testRepo) link(configs ...string) *LinkResult {
+	r.t.Helper()
+	if err := r.linker.Begin(); err != nil {
+		r.t.Fatal(err)
+	}
+	result := r.execute(r.plan(configs...))
+	if err := r.linker.Commit(); err != nil {
+		r.t.Fatal(err)
+	}
+	return result
+}
+
+func (r *testRepo) target(rel string) string {
+	return filepath.Join(r.home, rel)
+}
+
+func TestPlanPrune(t *testing.T) {
+	all := func(string) bool { return true }
+
+	tests := []struct {
+		name    string
+		setup   func(r *testRepo) []string // configs planned afterwards
+		inScope func(config string) bool   // nil for every config
+		want    map[string]string          // pruned target -> reason prefix
+	}{
+		{
+			name: "removed source",
+			setup: func(r *testRepo) []string {
+				r.write("shell", ".zshrc", "zshrc")
+				gone := r.write("shell", ".zprofile", "zprofile")
+				r.link("shell")
+				os.Remove(gone)
+				return []string{"shell"}
+			},
+			want: map[string]string{".zprofile": "source was removed"},
+		},
+		{
+			name: "dropped config",
+			setup: func(r *testRepo) []string {
+				r.write("shell", ".zshrc", "zshrc")
+				r.write("git", ".gitconfig", "gitconfig")
+				r.link("shell", "git")
+				return []string{"shell"}
+			},
+			want: map[string]string{".gitconfig": "config git is no longer applied"},
+		},
+		{
+			name: "dropped config out of scope",
+			setup: func(r *testRepo) []string {
+				r.write("shell", ".zshrc", "zshrc")
+				r.write("git", ".gitconfig", "gitconfig")
+				r.link("shell", "git")
+				return []string{"shell"}
+			},
+			inScope: func(config string) bool { return config == "shell" },
+			want:    map[string]string{},
+		},
+		{
+			name: "modified copy",
+			setup: func(r *testRepo) []string {
+				r.write("git", ".dotts.yaml", "link_mode: copy\n")
+				gone := r.write("git", ".gitconfig", "gitconfig")
+				r.link("git")
+				os.WriteFile(r.target(".gitconfig"), []byte("edited"), 0644)
+				os.Remove(gone)
+				return []string{"git"}
+			},
+			want: map[string]string{".gitconfig": "source was removed, modified locally and left in place"},
+		},
+		{
+			name: "files inside a directory now linked whole",
+			setup: func(r *testRepo) []string {
+				r.write("nvim", ".config/nvim/init.lua", "init")
+				r.write("nvim", ".config/nvim/lua/plugins.lua", "plugins")
+				r.link("nvim")
+				r.write("nvim", ".config/nvim/.dottslinkdir", "")
+				return []string{"nvim"}
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "directory link whose source was removed",
+			setup: func(r *testRepo) []string {
+				r.write("shell", ".zshrc", "zshrc")
+				dir := r.write("nvim", ".config/nvim/.dottslinkdir", "")
+				r.write("nvim", ".config/nvim/init.lua", "init")
+				r.link("shell", "nvim")
+				os.RemoveAll(filepath.Dir(filepath.Dir(filepath.Dir(dir))))
+				return []string{"shell", "nvim"}
+			},
+			want: map[string]string{".config/nvim": "source was removed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			configs := tt.setup(r)
+			inScope := all
+			if tt.inScope != nil {
+				inScope = tt.inScope
+			}
+
+			got := r.linker.PlanPrune(r.plan(configs...), inScope)
+			if len(got) != len(tt.want) {
+				t.Fatalf("PlanPrune() = %+v, want %d action(s)", got, len(tt.want))
+			}
+			for _, action := range got {
+				rel, _ := filepath.Rel(r.home, action.Target)
+				reason, ok := tt.want[rel]
+				if !ok {
+					t.Errorf("PlanPrune() pruned %s, want it kept", rel)
+					continue
+				}
+				if action.Action != ActionPrune || !strings.HasPrefix(action.Reason, reason) {
+					t.Errorf("PlanPrune() %s = %s %q, want prune %q", rel, action.Action, action.Reason, reason)
+				}
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	t.Run("removes the link and restores the original", func(t *testing.T) {
+		r := newTestRepo(t)
+		writeTestFile(t, r.target(".gitconfig"), "original")
+		r.write("git", ".gitconfig", "gitconfig")
+		r.link("git")
+
+		// a later file in place of the link is backed up on the next apply
+		os.Remove(r.target(".gitconfig"))
+		writeTestFile(t, r.target(".gitconfig"), "later")
+		r.link("git")
+		if n := len(r.linker.Backups().Versions(r.target(".gitconfig"))); n != 2 {
+			t.Fatalf("backups = %d, want 2", n)
+		}
+
+		result := r.execute(r.linker.PlanPrune(nil, func(string) bool { return true }))
+		if len(result.Restored) != 1 {
+			t.Fatalf("Restored = %v, want the gitconfig", result.Restored)
+		}
+		if isSymlink(r.target(".gitconfig")) {
+			t.Error("Prune() left the link in place")
+		}
+		if got := readTestFile(t, r.target(".gitconfig")); got != "original" {
+			t.Errorf("restored %q, want the original from before dotts", got)
+		}
+		if r.linker.Manifest().HasEntry(r.target(".gitconfig")) {
+			t.Error("Prune() kept the manifest entry")
+		}
+	})
+
+	t.Run("keeps a modified copy", func(t *testing.T) {
+		r := newTestRepo(t)
+		r.write("git", ".dotts.yaml", "link_mode: copy\n")
+		r.write("git", ".gitconfig", "gitconfig")
+		r.link("git")
+		os.WriteFile(r.target(".gitconfig"), []byte("edited"), 0644)
+
+		r.execute(r.linker.PlanPrune(nil, func(string) bool { return true }))
+		if got := readTestFile(t, r.target(".gitconfig")); got != "edited" {
+			t.Errorf("target = %q, want the local edit kept", got)
+		}
+		if r.linker.Manifest().HasEntry(r.target(".gitconfig")) {
+			t.Error("Prune() kept the manifest entry")
+		}
+	})
+
+	t.Run("removes a directory link", func(t *testing.T) {
+		r := newTestRepo(t)
+		r.write("nvim", ".config/nvim/.dottslinkdir", "")
+		r.write("nvim", ".config/nvim/init.lua", "init")
+		r.link("nvim")
+		if !isSymlink(r.target(".config/nvim")) {
+			t.Fatal("directory was not linked whole")
+		}
+
+		r.execute(r.linker.PlanPrune(nil, func(string) bool { return true }))
+		if pathExists(r.target(".config/nvim")) {
+			t.Error("Prune() left the directory link")
+		}
+		if !pathExists(filepath.Join(r.root, "configs/nvim/.config/nvim/init.lua")) {
+			t.Error("Prune() removed the source through the link")
+		}
+	})
+}
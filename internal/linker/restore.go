@@ -0,0 +1,97 @@
+package linker
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// PlanRestore plans putting entries back as they were recorded, for rolling
+// back to an earlier apply. Symlinks and hard links point at their source
+// again; copies, templates and encrypted files get the content returned by
+// content, either recorded or rendered afresh with Render. Managed targets
+// that are not in entries are left to PlanPrune.
+func (s *SymlinkLinker) PlanRestore(entries []LinkEntry, content func(target string) ([]byte, error)) []LinkAction {
+	opts := DefaultLinkOptions()
+
+	var actions []LinkAction
+	for _, entry := range entries {
+		action := LinkAction{
+			Source:      entry.Source,
+			Target:      entry.Target,
+			IsDir:       entry.IsDir,
+			IsTemplate:  entry.IsTemplate,
+			IsEncrypted: entry.IsEncrypted,
+			Variant:     entry.Variant,
+			Mode:        entry.Mode,
+		}
+
+		switch {
+		case !entry.tracksContent() || entry.Mode == ModeHardlink:
+			if !pathExists(entry.Source) {
+				action = errorAction(action, fmt.Errorf("source %s no longer exists", entry.Source))
+				break
+			}
+			mode := entry.Mode
+			if mode == "" {
+				mode = ModeSymlink
+			}
+			action = s.planLink(entry.Source, entry.Target, entry.IsDir, mode, opts)
+			action.Variant = entry.Variant
+		default:
+			data, err := content(entry.Target)
+			if err != nil {
+				action = errorAction(action, fmt.Errorf("cannot restore content: %w", err))
+				break
+			}
+			action.rendered = data
+			s.planRecorded(&action)
+		}
+
+		action.Config = s.ConfigOf(entry)
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// planRecorded decides how to write recorded content over the current target
+func (s *SymlinkLinker) planRecorded(action *LinkAction) {
+	if !pathExists(action.Target) {
+		action.Action = ActionCreate
+		s.addTemplateDiff(action, nil, nil)
+		return
+	}
+
+	current, _ := os.ReadFile(action.Target)
+	entry, managed := s.manifest.Get(action.Target)
+
+	switch {
+	case !isSymlink(action.Target) && bytes.Equal(current, action.rendered):
+		action.Action = ActionUnchanged
+		return
+	case managed && s.ownsTarget(entry) && !(entry.tracksContent() && s.isModified(entry)):
+		action.Action = ActionReplace
+		action.Reason = "restoring recorded content"
+	default:
+		action.Action = ActionBackup
+		action.Reason = "existing file"
+	}
+	s.addTemplateDiff(action, current, nil)
+}
+
+// Render renders a template or decrypts an encrypted file from the current
+// source of entry, for content that was not recorded
+func (s *SymlinkLinker) Render(entry LinkEntry, opts LinkOptions) ([]byte, error) {
+	source := s.sourcePath(entry.Source)
+	if !pathExists(source) {
+		return nil, fmt.Errorf("source %s no longer exists", entry.Source)
+	}
+
+	switch {
+	case entry.IsEncrypted:
+		return decrypt(source, opts.Decrypter)
+	case entry.IsTemplate && opts.Templates != nil:
+		return s.renderTemplate(source, opts.Templates)
+	}
+	return nil, fmt.Errorf("%s is not rendered from its source", entry.Target)
+}
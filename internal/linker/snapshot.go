@@ -0,0 +1,294 @@
+package linker
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The snapshot taken before the first apply lives in <dataDir>/snapshot.
+// home.tar.gz holds every target that existed, and snapshot.json lists all
+// targets, including the ones dotts was about to create.
+const (
+	snapshotDir     = "snapshot"
+	snapshotArchive = "home.tar.gz"
+	snapshotIndex   = "snapshot.json"
+)
+
+// Snapshot is the state of every target before dotts first touched it
+type Snapshot struct {
+	CreatedAt time.Time      `json:"created_at"`
+	Paths     []SnapshotPath `json:"paths"`
+	dir       string
+}
+
+type SnapshotPath struct {
+	Path    string `json:"path"`
+	Existed bool   `json:"existed"`
+	Created string `json:"created,omitempty"` // topmost missing parent, created by the apply
+}
+
+// HasSnapshot reports whether a snapshot was taken and not yet discarded
+func (s *SymlinkLinker) HasSnapshot() bool {
+	return pathExists(filepath.Join(s.dataDir, snapshotDir, snapshotIndex))
+}
+
+// Snapshot loads the snapshot, or returns nil when there is none
+func (s *SymlinkLinker) Snapshot() (*Snapshot, error) {
+	dir := filepath.Join(s.dataDir, snapshotDir)
+	data, err := os.ReadFile(filepath.Join(dir, snapshotIndex))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{dir: dir}
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	return snap, nil
+}
+
+// TakeSnapshot archives targets as they are now. The index is written last,
+// so a snapshot interrupted halfway does not count as taken.
+func (s *SymlinkLinker) TakeSnapshot(targets []string) (*Snapshot, error) {
+	dir := filepath.Join(s.dataDir, snapshotDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{CreatedAt: time.Now(), dir: dir}
+	var existing []string
+	for _, target := range uniqueTargets(targets) {
+		p := SnapshotPath{Path: target, Existed: pathExists(target)}
+		if p.Existed {
+			existing = append(existing, target)
+		} else {
+			p.Created = TopmostMissing(filepath.Dir(target))
+		}
+		snap.Paths = append(snap.Paths, p)
+	}
+
+	if err := writeArchive(filepath.Join(dir, snapshotArchive), outermost(existing)); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to archive targets: %w", err)
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, snapshotIndex), data, 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Get returns what the snapshot recorded for path
+func (snap *Snapshot) Get(path string) (SnapshotPath, bool) {
+	for _, p := range snap.Paths {
+		if p.Path == path {
+			return p, true
+		}
+	}
+	return SnapshotPath{}, false
+}
+
+// Discard deletes the snapshot
+func (snap *Snapshot) Discard() error {
+	return os.RemoveAll(snap.dir)
+}
+
+// extract writes path, and everything below it, back from the archive
+func (snap *Snapshot) extract(path string) error {
+	f, err := os.Open(filepath.Join(snap.dir, snapshotArchive))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	name := archiveName(path)
+	found := false
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Name != name && !strings.HasPrefix(hdr.Name, name+"/") {
+			continue
+		}
+		found = true
+
+		dst := string(filepath.Separator) + filepath.FromSlash(hdr.Name)
+		mode := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dst, mode); err != nil {
+				return err
+			}
+			if err := os.Chmod(dst, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := ensureParentDir(dst); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, dst); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := ensureParentDir(dst); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+			if err := os.Chtimes(dst, hdr.ModTime, hdr.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("%s is not in the snapshot", path)
+	}
+	return nil
+}
+
+func writeArchive(path string, roots []string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	for _, root := range roots {
+		err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				if link, err = os.Readlink(p); err != nil {
+					return err
+				}
+			}
+			hdr, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			hdr.Name = archiveName(p)
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			src, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			_, err = io.Copy(tw, src)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// archiveName is path inside the archive: absolute, without the leading slash
+func archiveName(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "/")
+}
+
+func uniqueTargets(targets []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, t := range targets {
+		t = filepath.Clean(t)
+		if !seen[t] {
+			seen[t] = true
+			result = append(result, t)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// outermost drops the paths inside another of paths, which the archive
+// already covers
+func outermost(paths []string) []string {
+	var result []string
+	for _, p := range paths {
+		inside := false
+		for _, other := range paths {
+			if other != p && underAny(p, []string{other}) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// TopmostMissing returns the highest ancestor of dir, or dir itself, that
+// does not exist yet
+func TopmostMissing(dir string) string {
+	top := ""
+	for p := dir; !pathExists(p); p = filepath.Dir(p) {
+		top = p
+		if p == filepath.Dir(p) {
+			break
+		}
+	}
+	return top
+}
@@ -0,0 +1,80 @@
+package linker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	r := newTestRepo(t)
+	zshrc := r.target(".zshrc")
+	nvim := r.target(".config/nvim")
+	missing := r.target(".local/share/app/app.conf")
+
+	writeTestFile(t, zshrc, "zshrc")
+	if err := os.Chmod(zshrc, 0600); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(nvim, "init.lua"), "init")
+	if err := os.Symlink("init.lua", filepath.Join(nvim, "alias.lua")); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.linker.HasSnapshot() {
+		t.Fatal("HasSnapshot() = true before any snapshot")
+	}
+	if _, err := r.linker.TakeSnapshot([]string{zshrc, nvim, filepath.Join(nvim, "init.lua"), missing, zshrc}); err != nil {
+		t.Fatalf("TakeSnapshot() error = %v", err)
+	}
+	if !r.linker.HasSnapshot() {
+		t.Fatal("HasSnapshot() = false after TakeSnapshot")
+	}
+
+	snap, err := r.linker.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if len(snap.Paths) != 4 {
+		t.Errorf("Snapshot() has %d paths, want 4 without duplicates", len(snap.Paths))
+	}
+	if p, ok := snap.Get(missing); !ok || p.Existed || p.Created != r.target(".local") {
+		t.Errorf("Get(%s) = %+v, %v, want missing with .local created", missing, p, ok)
+	}
+	if p, ok := snap.Get(zshrc); !ok || !p.Existed || p.Created != "" {
+		t.Errorf("Get(%s) = %+v, %v, want existing", zshrc, p, ok)
+	}
+
+	os.Remove(zshrc)
+	os.RemoveAll(nvim)
+	for _, path := range []string{zshrc, nvim} {
+		if err := snap.extract(path); err != nil {
+			t.Fatalf("extract(%s) error = %v", path, err)
+		}
+	}
+
+	if got := readTestFile(t, zshrc); got != "zshrc" {
+		t.Errorf("extracted .zshrc = %q, want %q", got, "zshrc")
+	}
+	if info, err := os.Stat(zshrc); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("extracted .zshrc mode = %v, want 0600", info.Mode().Perm())
+	}
+	if got := readTestFile(t, filepath.Join(nvim, "init.lua")); got != "init" {
+		t.Errorf("extracted init.lua = %q, want %q", got, "init")
+	}
+	if link, err := os.Readlink(filepath.Join(nvim, "alias.lua")); err != nil || link != "init.lua" {
+		t.Errorf("extracted alias.lua -> %q (%v), want init.lua", link, err)
+	}
+	if err := snap.extract(missing); err == nil {
+		t.Error("extract() of a path that did not exist succeeded")
+	}
+
+	if err := snap.Discard(); err != nil {
+		t.Fatalf("Discard() error = %v", err)
+	}
+	if r.linker.HasSnapshot() {
+		t.Error("HasSnapshot() = true after Discard")
+	}
+}
@@ -1,20 +1,54 @@
 package linker
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/crypt"
+	"github.com/arthur404dev/dotts/internal/diff"
 	"github.com/arthur404dev/dotts/internal/template"
 )
 
 type SymlinkLinker struct {
-	manifest   *Manifest
-	backup     *BackupManager
-	configRoot string
+	manifest    *Manifest
+	backup      *BackupManager
+	choices     *Choices
+	configRoot  string
+	sourceRoot  string // where planning reads sources; configRoot unless set by ReadSourcesFrom
+	dataDir     string
+	journal     *journal // set between Begin and Commit or Rollback
+	recovered   bool
+	interrupted bool
 }
 
+// NewSymlinkLinker loads the manifest and backups in dataDir, first rolling
+// back any apply that crashed before it committed
 func NewSymlinkLinker(dataDir, configRoot string) (*SymlinkLinker, error) {
+	recovered, err := recoverJournal(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := OpenSymlinkLinker(dataDir, configRoot)
+	if err != nil {
+		return nil, err
+	}
+	s.recovered = recovered
+	return s, nil
+}
+
+// OpenSymlinkLinker loads the manifest and backups in dataDir without
+// touching the disk. A crashed apply is left for NewSymlinkLinker to roll
+// back, so the linker is only fit for reading and planning.
+func OpenSymlinkLinker(dataDir, configRoot string) (*SymlinkLinker, error) {
 	manifest, err := LoadManifest(dataDir)
 	if err != nil {
 		return nil, err
@@ -25,30 +59,186 @@ func NewSymlinkLinker(dataDir, configRoot string) (*SymlinkLinker, error) {
 		return nil, err
 	}
 
+	choices, err := loadChoices(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
 	return &SymlinkLinker{
-		manifest:   manifest,
-		backup:     backup,
-		configRoot: configRoot,
+		manifest:    manifest,
+		backup:      backup,
+		choices:     choices,
+		configRoot:  configRoot,
+		sourceRoot:  configRoot,
+		dataDir:     dataDir,
+		interrupted: interruptedJournal(dataDir),
 	}, nil
 }
 
+// ReadSourcesFrom makes planning read sources from root, a checkout of the
+// config repo at another commit, as if it were the repo itself. Planned
+// actions keep the source paths of the repo, so they show what applying
+// that commit would do. Such plans are for display only.
+func (s *SymlinkLinker) ReadSourcesFrom(root string) {
+	s.sourceRoot = root
+}
+
+// repoPath maps a source read during planning to its path in the repo
+func (s *SymlinkLinker) repoPath(source string) string {
+	if s.sourceRoot == s.configRoot {
+		return source
+	}
+	rel, err := filepath.Rel(s.sourceRoot, source)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return source
+	}
+	return filepath.Join(s.configRoot, rel)
+}
+
+// sourcePath maps a source in the repo to where planning reads it
+func (s *SymlinkLinker) sourcePath(source string) string {
+	if s.sourceRoot == s.configRoot {
+		return source
+	}
+	rel, err := filepath.Rel(s.configRoot, source)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return source
+	}
+	return filepath.Join(s.sourceRoot, rel)
+}
+
+// Recovered reports whether a crashed apply was rolled back on load
+func (s *SymlinkLinker) Recovered() bool {
+	return s.recovered
+}
+
+// Interrupted reports whether a crashed apply was waiting to be rolled back
+// on load. The manifest and targets then still show its partial changes.
+func (s *SymlinkLinker) Interrupted() bool {
+	return s.interrupted
+}
+
+// Begin journals every following change to targets until Commit or Rollback
+func (s *SymlinkLinker) Begin() error {
+	j, err := beginJournal(s.dataDir)
+	if err != nil {
+		return err
+	}
+	s.journal = j
+	return nil
+}
+
+// Commit saves the manifest and the choices made during the apply, and
+// makes the journaled changes final
+func (s *SymlinkLinker) Commit() error {
+	if err := s.manifest.Save(); err != nil {
+		return err
+	}
+	if s.choices.pending {
+		if err := s.choices.save(); err != nil {
+			return err
+		}
+	}
+	if s.journal == nil {
+		return nil
+	}
+	err := s.journal.discard()
+	s.journal = nil
+	return err
+}
+
+// Rollback undoes the journaled changes and reloads the manifest, backup
+// index and remembered choices as they were at Begin
+func (s *SymlinkLinker) Rollback() error {
+	if s.journal == nil {
+		return nil
+	}
+	if err := s.journal.rollback(s.dataDir); err != nil {
+		return err
+	}
+	s.journal = nil
+
+	manifest, err := LoadManifest(s.dataDir)
+	if err != nil {
+		return err
+	}
+	backup, err := NewBackupManager(s.dataDir)
+	if err != nil {
+		return err
+	}
+	choices, err := loadChoices(s.dataDir)
+	if err != nil {
+		return err
+	}
+	s.manifest, s.backup, s.choices = manifest, backup, choices
+	return nil
+}
+
 func (s *SymlinkLinker) LinkConfig(configName string, opts LinkOptions) (*LinkResult, error) {
-	result := &LinkResult{}
+	actions, err := s.PlanConfig(configName, opts)
+	if err != nil {
+		return &LinkResult{}, err
+	}
+	return s.Execute(actions, opts), nil
+}
 
-	configPath := filepath.Join(s.configRoot, "configs", configName)
+// PlanConfig computes the link actions for a config without touching the filesystem
+func (s *SymlinkLinker) PlanConfig(configName string, opts LinkOptions) ([]LinkAction, error) {
+	configPath := filepath.Join(s.sourceRoot, "configs", configName)
 	if !pathExists(configPath) {
-		return result, nil
+		return nil, nil
 	}
 
-	return s.linkDirectory(configPath, opts)
+	actions, err := s.planDirectory(configName, configPath, opts)
+	for i := range actions {
+		actions[i].Config = configName
+		actions[i].Source = s.repoPath(actions[i].Source)
+	}
+	return actions, err
 }
 
-func (s *SymlinkLinker) linkDirectory(sourceRoot string, opts LinkOptions) (*LinkResult, error) {
-	result := &LinkResult{}
-	homeDir, err := os.UserHomeDir()
+// planDirectory plans every file of a config, honouring its .dotts.yaml
+func (s *SymlinkLinker) planDirectory(configName, sourceRoot string, opts LinkOptions) ([]LinkAction, error) {
+	alternates := opts.Alternates
+	if alternates == nil {
+		alternates = config.NewAlternateResolver(config.AlternateContext{})
+	}
+
+	meta, err := config.LoadConfigMeta(sourceRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	targetRoot, err := config.TargetRoot(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	if reason := config.Unsupported(meta, alternates); reason != "" {
+		return []LinkAction{{
+			Source: sourceRoot,
+			Target: targetRoot,
+			Action: ActionIgnored,
+			Reason: "config is " + reason,
+		}}, nil
+	}
+
+	opts.Modes, err = opts.Modes.WithConfigDefaults(configName, meta.LinkMode, meta.LinkModes)
 	if err != nil {
 		return nil, err
 	}
+	perms, err := ParsePermRules(meta.Permissions)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", configName, err)
+	}
+
+	ignore, err := config.LoadIgnore(s.sourceRoot, sourceRoot, alternates)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []LinkAction
+	resolved := make(map[string]bool)
 
 	err = filepath.Walk(sourceRoot, func(sourcePath string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -64,158 +254,604 @@ func (s *SymlinkLinker) linkDirectory(sourceRoot string, opts LinkOptions) (*Lin
 			return nil
 		}
 
-		targetPath := filepath.Join(homeDir, relPath)
+		targetPath := filepath.Join(targetRoot, relPath)
 
 		if info.IsDir() {
-			if s.shouldLinkAsDirectory(sourcePath, sourceRoot) {
-				if err := s.createLink(sourcePath, targetPath, true, opts, result); err != nil {
-					result.Errors = append(result.Errors, LinkError{
-						Source: sourcePath,
-						Target: targetPath,
-						Err:    err,
-					})
+			rel := filepath.ToSlash(relPath)
+			if rule, ok := ignore.Match(relPath, true); ok {
+				actions = append(actions, ignoredAction(sourcePath, targetPath, rule))
+				return filepath.SkipDir
+			}
+
+			if owner, ok := s.dirLinkAbove(targetPath, opts.split); ok {
+				actions = append(actions, insideDirLink(sourcePath, targetPath, owner))
+				return filepath.SkipDir
+			}
+
+			whole, err := linksWhole(sourcePath, rel, meta.LinkDirs)
+			if err != nil {
+				return fmt.Errorf("%s: %w", configName, err)
+			}
+			if whole {
+				action := LinkAction{Source: sourcePath, Target: targetPath, IsDir: true}
+				if reason := s.wholeDirConflict(configName, sourceRoot, sourcePath, ignore, opts); reason != "" {
+					action = errorAction(action, fmt.Errorf("cannot link directory whole: %s", reason))
+				} else {
+					action = s.planLink(sourcePath, targetPath, true, ModeSymlink, opts)
+					action.Perm = perms.PermFor(rel)
 				}
+				actions = append(actions, action)
 				return filepath.SkipDir
 			}
+
+			// linked whole by an earlier apply, now file by file
+			if entry, ok := s.manifest.Get(targetPath); ok && entry.IsDir && isSymlink(targetPath) {
+				actions = append(actions, LinkAction{
+					Source: sourcePath,
+					Target: targetPath,
+					IsDir:  true,
+					Action: ActionSplit,
+					Reason: "switching to per-file links",
+				})
+				opts.split = append(opts.split, targetPath)
+			}
 			return nil
 		}
 
-		if err := s.createLink(sourcePath, targetPath, false, opts, result); err != nil {
-			result.Errors = append(result.Errors, LinkError{
-				Source: sourcePath,
-				Target: targetPath,
-				Err:    err,
-			})
+		// All variants of a file map to the un-suffixed target; plan it once, from the winner.
+		base, _, _ := config.SplitAlternate(info.Name())
+		basePath := filepath.Join(filepath.Dir(sourcePath), base)
+		if resolved[basePath] {
+			return nil
+		}
+		resolved[basePath] = true
+
+		baseRel, err := filepath.Rel(sourceRoot, basePath)
+		if err != nil {
+			return err
+		}
+		baseTarget := filepath.Join(targetRoot, baseRel)
+		if crypt.IsEncrypted(base) {
+			baseTarget = strings.TrimSuffix(baseTarget, crypt.Ext)
 		}
 
+		if rule, ok := ignore.Match(baseRel, false); ok {
+			actions = append(actions, ignoredAction(basePath, baseTarget, rule))
+			return nil
+		}
+
+		if owner, ok := s.dirLinkAbove(baseTarget, opts.split); ok {
+			actions = append(actions, insideDirLink(basePath, baseTarget, owner))
+			return nil
+		}
+
+		winner, err := alternates.ResolveFile(basePath)
+		if err != nil {
+			actions = append(actions, errorAction(LinkAction{Source: basePath, Target: baseTarget}, err))
+			return nil
+		}
+		if !pathExists(winner) {
+			// only variants for other machines exist
+			return nil
+		}
+
+		mode := opts.Modes.ModeFor(configName, filepath.ToSlash(baseRel))
+		action := s.planLink(winner, baseTarget, false, mode, opts)
+		_, action.Variant, _ = config.SplitAlternate(filepath.Base(winner))
+		action.Perm = perms.PermFor(filepath.ToSlash(baseRel))
+		actions = append(actions, action)
 		return nil
 	})
 
-	return result, err
+	return actions, err
 }
 
-func (s *SymlinkLinker) shouldLinkAsDirectory(dirPath, sourceRoot string) bool {
-	relPath, err := filepath.Rel(sourceRoot, dirPath)
-	if err != nil {
-		return false
+// planLink decides what linking source to target in the given mode would do
+func (s *SymlinkLinker) planLink(source, target string, isDir bool, mode LinkMode, opts LinkOptions) LinkAction {
+	action := LinkAction{
+		Source: source,
+		Target: target,
+		IsDir:  isDir,
 	}
 
-	parts := filepath.SplitList(relPath)
-	if len(parts) == 0 {
-		return false
+	switch {
+	case !isDir && isEncryptedSource(source):
+		action.IsEncrypted = true
+		plaintext, err := decrypt(source, opts.Decrypter)
+		if err != nil {
+			return errorAction(action, err)
+		}
+		action.rendered = plaintext
+	case !isDir && opts.Templates != nil:
+		action.IsTemplate = s.hasTemplates(source)
 	}
 
-	depth := len(filepath.SplitList(relPath))
-	return depth >= 2
-}
+	if action.IsTemplate {
+		rendered, err := s.renderTemplate(source, opts.Templates)
+		if err != nil {
+			return errorAction(action, err)
+		}
+		action.rendered = rendered
+		action.Warnings = s.templateWarnings(source, opts.Templates)
+	}
 
-func (s *SymlinkLinker) createLink(source, target string, isDir bool, opts LinkOptions, result *LinkResult) error {
-	isTemplate := false
-	if !isDir && len(opts.TemplateValues) > 0 {
-		isTemplate = s.hasTemplates(source)
+	switch {
+	case action.IsTemplate || action.IsEncrypted:
+		if mode == ModeHardlink {
+			action.Warnings = append(action.Warnings, "rendered files cannot be hard linked, writing a copy")
+		}
+	case !isDir && mode != ModeSymlink:
+		content, err := os.ReadFile(source)
+		if err != nil {
+			return errorAction(action, err)
+		}
+		action.Mode = mode
+		action.rendered = content
 	}
 
-	if opts.DryRun {
-		result.Linked = append(result.Linked, LinkEntry{
-			Source:     source,
-			Target:     target,
-			CreatedAt:  time.Now(),
-			IsDir:      isDir,
-			IsTemplate: isTemplate,
-		})
-		return nil
+	if underAny(filepath.Dir(target), opts.split) {
+		// the directory link above is replaced by a real directory first
+		action.Action = ActionCreate
+		s.addTemplateDiff(&action, nil, opts.Templates)
+		return action
+	}
+
+	if action.Mode == ModeHardlink && sameFile(s.repoPath(source), target) {
+		// when reading another checkout, the linked file may differ from the planned one
+		if current, err := os.ReadFile(target); err == nil && bytes.Equal(current, action.rendered) {
+			action.Action = ActionUnchanged
+			return action
+		}
 	}
 
 	if isSymlink(target) {
 		existingSource, err := readLink(target)
-		if err == nil && existingSource == source && !isTemplate {
-			result.Skipped = append(result.Skipped, target)
-			return nil
+		switch {
+		case err == nil && existingSource == s.repoPath(source) && !action.tracksContent():
+			action.Action = ActionUnchanged
+		case s.manifest.HasEntry(target) || opts.Force:
+			action.Action = ActionReplace
+			action.Reason = "currently links to " + existingSource
+		case !action.tracksContent():
+			action.Action = ActionSkip
+			action.Reason = "foreign symlink to " + existingSource
+		case opts.Backup:
+			action.Action = ActionBackup
+			action.Reason = "foreign symlink to " + existingSource
+		default:
+			action.Action = ActionReplace
+			action.Reason = "foreign symlink to " + existingSource
 		}
+		s.addTemplateDiff(&action, nil, opts.Templates)
+		return action
+	}
 
-		if s.manifest.HasEntry(target) || opts.Force {
-			if err := os.Remove(target); err != nil {
-				return err
-			}
-		} else if !isTemplate {
-			result.Skipped = append(result.Skipped, target)
-			return nil
+	if !pathExists(target) {
+		action.Action = ActionCreate
+		s.addTemplateDiff(&action, nil, opts.Templates)
+		return action
+	}
+
+	entry, managed := s.manifest.Get(target)
+	ownedFile := managed && entry.tracksContent()
+
+	var current []byte
+	if (action.tracksContent() || ownedFile) && !isDir && !isDirPath(target) {
+		current, _ = os.ReadFile(target)
+	}
+
+	switch {
+	case action.tracksContent() && ownedFile:
+		planRendered(&action, entry, current, opts)
+		if action.Action == ActionUnchanged && action.Mode == ModeHardlink {
+			action.Action = ActionReplace
+			action.Reason = "hard link was broken"
 		}
+		if action.Action == ActionUnchanged {
+			return action
+		}
+	case ownedFile && !s.isModified(entry):
+		action.Action = ActionReplace
+		action.Reason = "switching to symlink"
+	case isDir && isDirPath(target) && s.onlyManagedLinks(target):
+		action.Action = ActionReplace
+		action.Reason = "replacing per-file links"
+	case s.keepsExisting(target, opts):
+		action.Action = ActionSkip
+		action.Reason = "existing file kept (remembered)"
+	case opts.Backup:
+		action.Action = ActionBackup
+		action.Reason = "existing file"
+	default:
+		action.Action = ActionReplace
+		action.Reason = "existing file"
 	}
 
-	if pathExists(target) {
-		if opts.Backup {
-			backupPath, err := s.backup.Backup(target)
-			if err != nil {
+	s.addTemplateDiff(&action, current, opts.Templates)
+	return action
+}
+
+// planRendered compares a managed template or copy target with its last
+// render to tell local edits apart from upstream changes.
+func planRendered(action *LinkAction, entry LinkEntry, current []byte, opts LinkOptions) {
+	currentHash := contentHash(current)
+	renderedHash := contentHash(action.rendered)
+
+	written := entry.TargetHash
+	if written == "" {
+		written = entry.RenderedHash
+	}
+
+	changed := "rendered output changed"
+	if action.Mode != "" {
+		changed = "source changed"
+	}
+
+	switch {
+	case written == "":
+		// recorded before hashes were tracked
+		if bytes.Equal(current, action.rendered) {
+			action.Action = ActionUnchanged
+		} else {
+			action.Action = ActionReplace
+			action.Reason = changed
+		}
+	case currentHash == renderedHash && currentHash == written:
+		action.Action = ActionUnchanged
+	case currentHash != written && opts.Force:
+		action.Action = ActionReplace
+		action.Reason = "overwriting local changes"
+	case currentHash != written && currentHash == renderedHash:
+		action.Action = ActionReplace
+		action.Reason = "local changes match the new render"
+	case currentHash != written:
+		action.Action = ActionModified
+		action.Reason = "modified locally since last apply"
+	case renderedHash == entry.RenderedHash:
+		// local edits were kept and the template has not changed since
+		action.Action = ActionUnchanged
+	case written != entry.RenderedHash && !opts.Force:
+		action.Action = ActionModified
+		action.Reason = changed + ", local changes were kept"
+	default:
+		action.Action = ActionReplace
+		action.Reason = changed
+	}
+}
+
+// addTemplateDiff previews a render or copy; resolved secrets are masked
+func (s *SymlinkLinker) addTemplateDiff(action *LinkAction, current []byte, engine *template.Engine) {
+	if !action.IsTemplate && action.Mode == "" {
+		return
+	}
+	action.Diff = diff.Unified(action.Target, action.Target+" (rendered)", string(current), string(action.rendered))
+	if engine != nil {
+		action.Diff = engine.Redact(action.Diff)
+	}
+}
+
+func ignoredAction(source, target string, rule *config.IgnoreRule) LinkAction {
+	return LinkAction{
+		Source: source,
+		Target: target,
+		Action: ActionIgnored,
+		Reason: "ignored by " + rule.String(),
+	}
+}
+
+func errorAction(action LinkAction, err error) LinkAction {
+	action.Action = ActionError
+	action.Reason = err.Error()
+	action.err = err
+	return action
+}
+
+// Execute performs planned actions and records them in the manifest
+func (s *SymlinkLinker) Execute(actions []LinkAction, opts LinkOptions) *LinkResult {
+	result := &LinkResult{}
+
+	for i, action := range actions {
+		if opts.Context != nil && opts.Context.Err() != nil {
+			result.Errors = append(result.Errors, LinkError{
+				Source: action.Source,
+				Target: action.Target,
+				Err:    opts.Context.Err(),
+			})
+			break
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(LinkProgress{
+				Source:  action.Source,
+				Target:  action.Target,
+				Current: i + 1,
+				Total:   len(actions),
+				Status:  LinkRunning,
+			})
+		}
+
+		status := LinkSuccess
+		if err := s.execute(action, opts, result); err != nil {
+			status = LinkFailed
+			result.Errors = append(result.Errors, LinkError{
+				Source: action.Source,
+				Target: action.Target,
+				Err:    err,
+			})
+		} else if !action.Changes() {
+			status = LinkSkipped
+		} else if action.Action == ActionBackup && opts.Backup {
+			status = LinkBackedUp
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(LinkProgress{
+				Source:  action.Source,
+				Target:  action.Target,
+				Current: i + 1,
+				Total:   len(actions),
+				Status:  status,
+				Message: action.Reason,
+			})
+		}
+	}
+
+	return result
+}
+
+func (s *SymlinkLinker) execute(action LinkAction, opts LinkOptions, result *LinkResult) error {
+	switch action.Action {
+	case ActionError:
+		return action.err
+	case ActionIgnored:
+		return nil
+	case ActionSplit:
+		if opts.DryRun {
+			return nil
+		}
+		return s.split(action.Target)
+	case ActionPrune:
+		if opts.DryRun {
+			result.Pruned = append(result.Pruned, action.Target)
+			return nil
+		}
+		restored, err := s.Prune(action.Target)
+		if err != nil {
+			return err
+		}
+		result.Pruned = append(result.Pruned, action.Target)
+		if restored {
+			result.Restored = append(result.Restored, action.Target)
+		}
+		return nil
+	case ActionUnchanged, ActionSkip:
+		if action.Action == ActionUnchanged && !opts.DryRun {
+			if action.tracksContent() {
+				s.recordRenderHash(action)
+			}
+			if err := s.applyPerm(action); err != nil {
 				return err
 			}
-			result.Backed = append(result.Backed, backupPath)
 		}
+		result.Skipped = append(result.Skipped, action.Target)
+		return nil
+	}
+
+	entry := LinkEntry{
+		Source:      action.Source,
+		Target:      action.Target,
+		CreatedAt:   time.Now(),
+		IsDir:       action.IsDir,
+		IsTemplate:  action.IsTemplate,
+		IsEncrypted: action.IsEncrypted,
+		Variant:     action.Variant,
+		Mode:        action.Mode,
+	}
+	if action.tracksContent() {
+		entry.RenderedHash = contentHash(action.rendered)
+		entry.TargetHash = entry.RenderedHash
+	}
+
+	if opts.DryRun {
+		result.Linked = append(result.Linked, entry)
+		return nil
+	}
+
+	if action.Action == ActionModified {
+		done, err := s.resolveModified(action, &entry, opts, result)
+		if done || err != nil {
+			return err
+		}
+	}
 
-		if err := os.RemoveAll(target); err != nil {
+	if action.Action == ActionBackup && opts.OnExisting != nil && !isSymlink(action.Target) {
+		kept, err := s.resolveExisting(&action, &entry, opts, result)
+		if kept || err != nil {
 			return err
 		}
 	}
 
-	if err := ensureParentDir(target); err != nil {
+	if action.Action == ActionBackup && opts.Backup {
+		backupPath, err := s.backup.Backup(action.Target)
+		if err != nil {
+			return err
+		}
+		if err := s.journal.backedUp(backupPath); err != nil {
+			return err
+		}
+		result.Backed = append(result.Backed, backupPath)
+	}
+
+	// parents first, so a rollback empties them before removing them
+	if err := s.journal.mkdirAll(filepath.Dir(action.Target)); err != nil {
+		return err
+	}
+
+	if err := s.journal.clear(action.Target); err != nil {
 		return err
 	}
+	if action.IsDir && action.Action != ActionCreate {
+		// per-file links that lived inside went with the directory
+		s.manifest.RemoveBelow(action.Target)
+	}
 
-	if isTemplate {
-		if err := s.applyTemplate(source, target, opts.TemplateValues); err != nil {
+	switch {
+	case action.IsEncrypted:
+		if err := os.WriteFile(action.Target, action.rendered, 0600); err != nil {
 			return err
 		}
-	} else {
-		if err := os.Symlink(source, target); err != nil {
+	case action.IsTemplate, action.Mode == ModeCopy:
+		if err := s.writeRendered(action.Source, action.Target, action.rendered); err != nil {
+			return err
+		}
+	case action.Mode == ModeHardlink:
+		if err := os.Link(action.Source, action.Target); err != nil {
+			return fmt.Errorf("failed to hard link (use copy mode across filesystems): %w", err)
+		}
+	default:
+		if err := os.Symlink(action.Source, action.Target); err != nil {
 			return err
 		}
 	}
 
-	entry := LinkEntry{
-		Source:     source,
-		Target:     target,
-		CreatedAt:  time.Now(),
-		IsDir:      isDir,
-		IsTemplate: isTemplate,
+	if err := s.applyPerm(action); err != nil {
+		return err
 	}
+
 	result.Linked = append(result.Linked, entry)
 	s.manifest.Add(entry)
 
 	return nil
 }
 
+// resolveModified asks opts.OnModified what to do with a locally edited
+// target. It returns true when the target was handled (kept or merged) and
+// false when it should be overwritten. Without a resolver nothing changes.
+func (s *SymlinkLinker) resolveModified(action LinkAction, entry *LinkEntry, opts LinkOptions, result *LinkResult) (bool, error) {
+	local, err := os.ReadFile(action.Target)
+	if err != nil {
+		return false, err
+	}
+
+	if opts.OnModified == nil {
+		// leave it alone and keep reporting it until someone decides
+		result.Skipped = append(result.Skipped, action.Target)
+		return true, nil
+	}
+
+	resolution, merged, err := opts.OnModified(action, local)
+	if err != nil {
+		return false, err
+	}
+
+	switch resolution {
+	case ResolveOverwrite:
+		return false, nil
+	case ResolveMerge:
+		info, err := os.Stat(action.Target)
+		if err != nil {
+			return false, err
+		}
+		if err := s.journal.clear(action.Target); err != nil {
+			return false, err
+		}
+		if err := os.WriteFile(action.Target, merged, info.Mode()); err != nil {
+			return false, err
+		}
+		entry.TargetHash = contentHash(merged)
+		result.Linked = append(result.Linked, *entry)
+	default:
+		entry.TargetHash = contentHash(local)
+		result.Skipped = append(result.Skipped, action.Target)
+	}
+
+	s.manifest.Add(*entry)
+	return true, nil
+}
+
 func (s *SymlinkLinker) hasTemplates(path string) bool {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return false
 	}
-	return template.HasPlaceholdersBytes(content)
+	return template.IsTemplate(content)
 }
 
-func (s *SymlinkLinker) applyTemplate(source, target string, values map[string]string) error {
+// applyPerm sets the mode declared in the config's permissions. For symlinks
+// this changes the source in the config repo.
+func (s *SymlinkLinker) applyPerm(action LinkAction) error {
+	if action.Perm == 0 {
+		return nil
+	}
+	info, err := os.Stat(action.Target)
+	if err != nil || info.Mode().Perm() == action.Perm {
+		return err
+	}
+	return s.journal.chmod(action.Target, action.Perm)
+}
+
+// isEncryptedSource reports whether source, or the alternate it is a variant
+// of, is an encrypted file
+func isEncryptedSource(source string) bool {
+	base, _, _ := config.SplitAlternate(filepath.Base(source))
+	return crypt.IsEncrypted(base)
+}
+
+func decrypt(source string, decrypter *crypt.Age) ([]byte, error) {
+	if decrypter == nil {
+		return nil, fmt.Errorf("no decryption identity configured")
+	}
+	return decrypter.Decrypt(source)
+}
+
+func (s *SymlinkLinker) renderTemplate(source string, engine *template.Engine) ([]byte, error) {
 	content, err := os.ReadFile(source)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	processed := template.ApplyBytes(content, values)
+	return engine.Render(source, content)
+}
+
+// templateWarnings reports unresolved values under the warn policy
+func (s *SymlinkLinker) templateWarnings(source string, engine *template.Engine) []string {
+	if engine.MissingKeyPolicy() != template.MissingWarn {
+		return nil
+	}
 
-	info, err := os.Stat(source)
+	content, err := os.ReadFile(source)
 	if err != nil {
-		return err
+		return nil
+	}
+	missing, err := engine.Check(source, content)
+	if err != nil {
+		return nil
 	}
 
-	return os.WriteFile(target, processed, info.Mode())
+	var warnings []string
+	for _, m := range missing {
+		warnings = append(warnings, fmt.Sprintf("missing value %s (line %d)", m.Key, m.Line))
+	}
+	return warnings
+}
+
+// writeRendered writes with the source's mode, or 0644 once the source is
+// gone (restoring an earlier generation)
+func (s *SymlinkLinker) writeRendered(source, target string, rendered []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(source); err == nil {
+		mode = info.Mode()
+	}
+
+	return os.WriteFile(target, rendered, mode)
 }
 
 func (s *SymlinkLinker) Link(source, target string) error {
 	source = expandPath(source)
 	target = expandPath(target)
 
-	result := &LinkResult{}
-	isDir := isDir(source)
-
-	return s.createLink(source, target, isDir, DefaultLinkOptions(), result)
+	action := s.planLink(source, target, isDir(source), ModeSymlink, DefaultLinkOptions())
+	return s.execute(action, DefaultLinkOptions(), &LinkResult{})
 }
 
 func (s *SymlinkLinker) Unlink(target string) error {
@@ -229,7 +865,7 @@ func (s *SymlinkLinker) Unlink(target string) error {
 		return nil
 	}
 
-	if err := os.Remove(target); err != nil {
+	if err := s.journal.clear(target); err != nil {
 		return err
 	}
 
@@ -260,6 +896,21 @@ func (s *SymlinkLinker) Status() (*LinkStatus, error) {
 			continue
 		}
 
+		if entry.tracksContent() {
+			if isSymlink(entry.Target) {
+				status.Foreign = append(status.Foreign, entry.Target)
+				continue
+			}
+			switch {
+			case s.isModified(entry):
+				status.Modified = append(status.Modified, entry.Target)
+			case isStale(entry):
+				status.Stale = append(status.Stale, entry.Target)
+			}
+			status.Links = append(status.Links, entry)
+			continue
+		}
+
 		if !isSymlink(entry.Target) {
 			status.Foreign = append(status.Foreign, entry.Target)
 			continue
@@ -271,12 +922,190 @@ func (s *SymlinkLinker) Status() (*LinkStatus, error) {
 			continue
 		}
 
+		if _, err := os.Stat(entry.Target); err != nil {
+			status.Broken = append(status.Broken, entry.Target)
+			continue
+		}
+
 		status.Links = append(status.Links, entry)
 	}
 
+	sort.Slice(status.Links, func(i, j int) bool {
+		return status.Links[i].Target < status.Links[j].Target
+	})
+	sort.Strings(status.Broken)
+	sort.Strings(status.Foreign)
+	sort.Strings(status.Modified)
+	sort.Strings(status.Stale)
+
 	return status, nil
 }
 
+// recordRenderHash fills in hashes for rendered entries written before they were tracked
+func (s *SymlinkLinker) recordRenderHash(action LinkAction) {
+	entry, ok := s.manifest.Get(action.Target)
+	if !ok || entry.RenderedHash != "" {
+		return
+	}
+	entry.RenderedHash = contentHash(action.rendered)
+	entry.TargetHash = entry.RenderedHash
+	s.manifest.Add(entry)
+}
+
+// isModified reports whether a rendered target differs from what dotts last wrote
+func (s *SymlinkLinker) isModified(entry LinkEntry) bool {
+	if entry.Mode == ModeHardlink && sameFile(entry.Source, entry.Target) {
+		return false
+	}
+
+	written := entry.TargetHash
+	if written == "" {
+		written = entry.RenderedHash
+	}
+	if written == "" {
+		return false
+	}
+
+	content, err := os.ReadFile(entry.Target)
+	return err == nil && contentHash(content) != written
+}
+
+// isStale reports whether the source of a copy or hard link changed since it
+// was placed. Templates need rendering to tell and are checked by plan instead.
+func isStale(entry LinkEntry) bool {
+	if entry.Mode == "" || entry.RenderedHash == "" {
+		return false
+	}
+	if entry.Mode == ModeHardlink && sameFile(entry.Source, entry.Target) {
+		return false
+	}
+
+	content, err := os.ReadFile(entry.Source)
+	return err == nil && contentHash(content) != entry.RenderedHash
+}
+
+// sameFile reports whether both paths are the same inode, i.e. an intact hard link
+func sameFile(a, b string) bool {
+	ai, err := os.Lstat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Lstat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Relink recreates the link for a managed target, backing up anything that now occupies it.
+func (s *SymlinkLinker) Relink(target string, opts LinkOptions) error {
+	target = expandPath(target)
+
+	entry, ok := s.manifest.Get(target)
+	if !ok {
+		return fmt.Errorf("%s is not managed by dotts", target)
+	}
+
+	if !pathExists(entry.Source) {
+		return fmt.Errorf("source %s no longer exists", entry.Source)
+	}
+
+	opts.Force = true
+	mode := entry.Mode
+	if mode == "" {
+		mode = ModeSymlink
+	}
+	action := s.planLink(entry.Source, entry.Target, entry.IsDir, mode, opts)
+	return s.execute(action, opts, &LinkResult{})
+}
+
+// Prune stops managing target: our link is removed, the original is restored
+// from backup when one exists, and the manifest entry is dropped. Like
+// Uninstall it restores the oldest backup, the file from before dotts, not
+// whatever a later apply replaced.
+func (s *SymlinkLinker) Prune(target string) (restored bool, err error) {
+	target = expandPath(target)
+
+	entry, ok := s.manifest.Get(target)
+	if !ok {
+		return false, nil
+	}
+
+	// locally edited copies are kept rather than thrown away
+	if s.ownsTarget(entry) && !(entry.tracksContent() && s.isModified(entry)) {
+		if err := s.journal.clear(target); err != nil {
+			return false, err
+		}
+	}
+
+	if original, ok := s.backup.Original(target); ok && !pathExists(target) {
+		// journaled so a rollback removes the restored copy again
+		if err := s.journal.clear(target); err != nil {
+			return false, err
+		}
+		if err := s.backup.RestoreVersion(target, original.Version); err != nil {
+			return false, err
+		}
+		restored = true
+	}
+
+	s.manifest.Remove(target)
+	return restored, nil
+}
+
+// ownsTarget reports whether the file at the entry's target is still the one dotts created.
+func (s *SymlinkLinker) ownsTarget(entry LinkEntry) bool {
+	if isSymlink(entry.Target) {
+		actualSource, err := readLink(entry.Target)
+		return err == nil && actualSource == entry.Source
+	}
+	return entry.tracksContent() && pathExists(entry.Target)
+}
+
+// keepsExisting reports whether the user chose to always keep the file at
+// target. --force and asking again override it.
+func (s *SymlinkLinker) keepsExisting(target string, opts LinkOptions) bool {
+	r, ok := s.choices.Get(target)
+	return ok && r == ResolveKeep && !opts.Force && !opts.Reask
+}
+
+// Choices returns the remembered decisions about existing files
+func (s *SymlinkLinker) Choices() *Choices {
+	return s.choices
+}
+
+func (s *SymlinkLinker) Backups() *BackupManager {
+	return s.backup
+}
+
+// PruneBackups applies policy to the backups of paths dotts no longer
+// manages. Backups of managed targets, and of directories holding or held
+// by them, are what prune and uninstall restore, so they are all kept.
+func (s *SymlinkLinker) PruneBackups(policy RetentionPolicy, dryRun bool) ([]BackupEntry, error) {
+	var targets []string
+	for _, entry := range s.manifest.Entries() {
+		targets = append(targets, entry.Target)
+	}
+	referenced := func(path string) bool {
+		if underAny(path, targets) {
+			return true
+		}
+		for _, target := range targets {
+			if underAny(target, []string{path}) {
+				return true
+			}
+		}
+		return false
+	}
+	return s.backup.Prune(policy, referenced, dryRun)
+}
+
+func (s *SymlinkLinker) Manifest() *Manifest {
+	return s.manifest
+}
+
 func (s *SymlinkLinker) Save() error {
 	return s.manifest.Save()
 }
@@ -284,3 +1113,45 @@ func (s *SymlinkLinker) Save() error {
 func (s *SymlinkLinker) Restore(target string) error {
 	return s.backup.Restore(target)
 }
+
+// RestoreBackup puts a backup version back at target and stops managing it.
+// Whatever is there and is not a link or unedited copy dotts made is backed
+// up first, so the restore can itself be undone. Returns that backup's path.
+func (s *SymlinkLinker) RestoreBackup(target string, version int) (string, error) {
+	target = expandPath(target)
+
+	backup, ok := s.backup.Version(target, version)
+	if !ok {
+		return "", fmt.Errorf("no backup version %d for %s", version, target)
+	}
+	if err := s.backup.Verify(backup); err != nil {
+		return "", err
+	}
+
+	var backupPath string
+	entry, managed := s.manifest.Get(target)
+	owned := managed && s.ownsTarget(entry) && !(entry.tracksContent() && s.isModified(entry))
+	if pathExists(target) && !owned {
+		var err error
+		if backupPath, err = s.backup.Backup(target); err != nil {
+			return "", err
+		}
+		if err := s.journal.backedUp(backupPath); err != nil {
+			return "", err
+		}
+	}
+
+	if err := s.journal.mkdirAll(filepath.Dir(target)); err != nil {
+		return "", err
+	}
+	if err := s.journal.clear(target); err != nil {
+		return "", err
+	}
+	if err := s.backup.RestoreVersion(target, version); err != nil {
+		return "", err
+	}
+
+	s.manifest.Remove(target)
+	s.manifest.RemoveBelow(target)
+	return backupPath, nil
+}
@@ -0,0 +1,50 @@
+package linker
+
+import (
+	"os"
+	"sort"
+)
+
+// Edited returns the managed templates, copies and decrypted files whose
+// target no longer matches the last render or copy of their source: local
+// edits, kept or merged ones included. Intact hard links share the source
+// and are never edited apart from it.
+func (s *SymlinkLinker) Edited() []LinkEntry {
+	var edited []LinkEntry
+	for _, entry := range s.manifest.Entries() {
+		if !entry.tracksContent() || entry.IsDir || entry.RenderedHash == "" {
+			continue
+		}
+		if entry.Mode == ModeHardlink && sameFile(entry.Source, entry.Target) {
+			continue
+		}
+		if isSymlink(entry.Target) || isDirPath(entry.Target) {
+			continue
+		}
+		content, err := os.ReadFile(entry.Target)
+		if err == nil && !entry.IsLastRender(content) {
+			edited = append(edited, entry)
+		}
+	}
+
+	sort.Slice(edited, func(i, j int) bool { return edited[i].Target < edited[j].Target })
+	return edited
+}
+
+// Synced records that the source of target now renders or copies to
+// content, so the target no longer counts as edited. Save writes it out.
+func (s *SymlinkLinker) Synced(target string, content []byte) {
+	entry, ok := s.manifest.Get(target)
+	if !ok {
+		return
+	}
+	entry.RenderedHash = contentHash(content)
+	entry.TargetHash = entry.RenderedHash
+	s.manifest.Add(entry)
+}
+
+// IsLastRender reports whether content is what dotts last rendered or
+// copied to the entry's target
+func (e LinkEntry) IsLastRender(content []byte) bool {
+	return contentHash(content) == e.RenderedHash
+}
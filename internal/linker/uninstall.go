@@ -0,0 +1,134 @@
+package linker
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// UninstallResult lists what Uninstall changed
+type UninstallResult struct {
+	Removed  []string // links and files dotts placed
+	Restored []string // originals put back from the snapshot or a backup
+	BackedUp []string // backups of edited copies and content dotts did not write
+}
+
+// Uninstall removes every managed link and written file and puts back what
+// was there before dotts: from the first-apply snapshot when it has the path,
+// otherwise from the oldest backup. Targets that did not exist are left
+// absent, and the directories created for them are removed once empty.
+// Edited copies and anything dotts did not write are backed up before they
+// go. Callers journal it with Begin and Commit.
+func (s *SymlinkLinker) Uninstall() (*UninstallResult, error) {
+	snap, err := s.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UninstallResult{}
+	entries := s.manifest.Entries()
+
+	present := make(map[string]bool)
+	for _, entry := range entries {
+		if !pathExists(entry.Target) {
+			continue
+		}
+		present[entry.Target] = true
+
+		if s.ownsTarget(entry) && !(entry.tracksContent() && s.isModified(entry)) {
+			continue
+		}
+		backupPath, err := s.backup.Backup(entry.Target)
+		if err != nil {
+			return result, err
+		}
+		if err := s.journal.backedUp(backupPath); err != nil {
+			return result, err
+		}
+		result.BackedUp = append(result.BackedUp, backupPath)
+	}
+
+	if err := s.UnlinkAll(); err != nil {
+		return result, err
+	}
+	for _, entry := range entries {
+		// copies, rendered templates and whatever replaced a link
+		if s.manifest.HasEntry(entry.Target) {
+			if err := s.journal.clear(entry.Target); err != nil {
+				return result, err
+			}
+			s.manifest.Remove(entry.Target)
+		}
+		if present[entry.Target] {
+			result.Removed = append(result.Removed, entry.Target)
+		}
+	}
+
+	targets := make(map[string]bool)
+	for _, entry := range entries {
+		targets[entry.Target] = true
+	}
+	if snap != nil {
+		for _, p := range snap.Paths {
+			targets[p.Path] = true
+		}
+	}
+	sorted := make([]string, 0, len(targets))
+	for target := range targets {
+		sorted = append(sorted, target)
+	}
+	// parents first, so a restored directory brings its contents along
+	sort.Strings(sorted)
+
+	var created []string
+	for _, target := range sorted {
+		if pathExists(target) {
+			continue
+		}
+
+		var recorded SnapshotPath
+		var inSnapshot bool
+		if snap != nil {
+			recorded, inSnapshot = snap.Get(target)
+		}
+
+		switch {
+		case inSnapshot && recorded.Existed:
+			if err := s.restoreFrom(target, snap.extract); err != nil {
+				return result, err
+			}
+		case inSnapshot:
+			if recorded.Created != "" {
+				created = append(created, recorded.Created)
+			}
+			continue
+		case s.backup.HasBackup(target):
+			original, _ := s.backup.Original(target)
+			restore := func(string) error { return s.backup.RestoreVersion(target, original.Version) }
+			if err := s.restoreFrom(target, restore); err != nil {
+				return result, err
+			}
+		default:
+			continue
+		}
+		result.Restored = append(result.Restored, target)
+	}
+
+	// deepest first, and only the ones nothing else has moved into
+	sort.Sort(sort.Reverse(sort.StringSlice(created)))
+	for _, dir := range created {
+		removeEmptyDirs(dir)
+	}
+
+	return result, nil
+}
+
+// restoreFrom journals target's creation and writes it with restore
+func (s *SymlinkLinker) restoreFrom(target string, restore func(string) error) error {
+	if err := s.journal.mkdirAll(filepath.Dir(target)); err != nil {
+		return err
+	}
+	if err := s.journal.clear(target); err != nil {
+		return err
+	}
+	return restore(target)
+}
@@ -0,0 +1,129 @@
+package linker
+
+import (
+	"os"
+	"testing"
+)
+
+// firstApply snapshots what linking configs changes, as the first apply
+// does, then links them
+func (r *testRepo) firstApply(configs ...string) {
+	r.t.Helper()
+	var targets []string
+	for _, action := range r.plan(configs...) {
+		if action.Changes() {
+			targets = append(targets, action.Target)
+		}
+	}
+	if _, err := r.linker.TakeSnapshot(targets); err != nil {
+		r.t.Fatal(err)
+	}
+	r.link(configs...)
+}
+
+// uninstall runs Uninstall in a transaction
+func (r *testRepo) uninstall() *UninstallResult {
+	r.t.Helper()
+	if err := r.linker.Begin(); err != nil {
+		r.t.Fatal(err)
+	}
+	result, err := r.linker.Uninstall()
+	if err != nil {
+		r.t.Fatalf("Uninstall() error = %v", err)
+	}
+	if err := r.linker.Commit(); err != nil {
+		r.t.Fatal(err)
+	}
+	return result
+}
+
+func TestUninstall(t *testing.T) {
+	t.Run("restores originals from the snapshot", func(t *testing.T) {
+		r := newTestRepo(t)
+		writeTestFile(t, r.target(".zshrc"), "original")
+		r.write("shell", ".zshrc", "zshrc")
+		r.firstApply("shell")
+
+		// a backup made after the snapshot does not win over it
+		os.Remove(r.target(".zshrc"))
+		writeTestFile(t, r.target(".zshrc"), "later")
+		r.link("shell")
+
+		result := r.uninstall()
+		if got := readTestFile(t, r.target(".zshrc")); got != "original" {
+			t.Errorf(".zshrc = %q, want the original", got)
+		}
+		if len(result.Restored) != 1 || result.Restored[0] != r.target(".zshrc") {
+			t.Errorf("Restored = %v, want .zshrc", result.Restored)
+		}
+		if r.linker.Manifest().Count() != 0 {
+			t.Errorf("manifest has %d entries after uninstall", r.linker.Manifest().Count())
+		}
+	})
+
+	t.Run("removes the directories dotts created", func(t *testing.T) {
+		r := newTestRepo(t)
+		r.write("app", ".config/app/app.conf", "conf")
+		r.write("app", ".local/share/app/data", "data")
+		writeTestFile(t, r.target(".local/keep"), "")
+		r.firstApply("app")
+
+		// moved in after the apply, so .config stays
+		writeTestFile(t, r.target(".config/other/other.conf"), "other")
+
+		result := r.uninstall()
+		if len(result.Removed) != 2 {
+			t.Errorf("Removed = %v, want both files", result.Removed)
+		}
+		if pathExists(r.target(".config/app")) {
+			t.Error(".config/app was created by dotts and is still there")
+		}
+		if !pathExists(r.target(".config/other/other.conf")) {
+			t.Error("uninstall removed a file dotts did not create")
+		}
+		if pathExists(r.target(".local/share")) {
+			t.Error(".local/share was created by dotts and is still there")
+		}
+		if !pathExists(r.target(".local/keep")) {
+			t.Error("uninstall removed a directory that existed before dotts")
+		}
+	})
+
+	t.Run("falls back to the oldest backup", func(t *testing.T) {
+		r := newTestRepo(t)
+		writeTestFile(t, r.target(".gitconfig"), "original")
+		r.write("git", ".gitconfig", "gitconfig")
+		r.link("git")
+
+		os.Remove(r.target(".gitconfig"))
+		writeTestFile(t, r.target(".gitconfig"), "later")
+		r.link("git")
+
+		r.uninstall()
+		if isSymlink(r.target(".gitconfig")) {
+			t.Fatal("uninstall left the link in place")
+		}
+		if got := readTestFile(t, r.target(".gitconfig")); got != "original" {
+			t.Errorf(".gitconfig = %q, want the oldest backup", got)
+		}
+	})
+
+	t.Run("backs up edited copies", func(t *testing.T) {
+		r := newTestRepo(t)
+		r.write("git", ".dotts.yaml", "link_mode: copy\n")
+		r.write("git", ".gitconfig", "gitconfig")
+		r.firstApply("git")
+		os.WriteFile(r.target(".gitconfig"), []byte("edited"), 0644)
+
+		result := r.uninstall()
+		if pathExists(r.target(".gitconfig")) {
+			t.Error("uninstall left a copy where nothing existed before")
+		}
+		if len(result.BackedUp) != 1 {
+			t.Fatalf("BackedUp = %v, want the edited copy", result.BackedUp)
+		}
+		if got := readTestFile(t, result.BackedUp[0]); got != "edited" {
+			t.Errorf("backup = %q, want the edit", got)
+		}
+	})
+}
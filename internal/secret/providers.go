@@ -0,0 +1,135 @@
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/arthur404dev/dotts/internal/personal"
+)
+
+// envProvider reads environment variables: env/GITHUB_TOKEN
+type envProvider struct{}
+
+func (envProvider) Name() string { return "env" }
+
+func (envProvider) Get(path string) (string, error) {
+	value, ok := os.LookupEnv(path)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", path)
+	}
+	return value, nil
+}
+
+// DefaultStoreDir is the local file store, next to personal.yaml
+func DefaultStoreDir() string {
+	return filepath.Join(filepath.Dir(personal.GetPath()), "secrets")
+}
+
+// FileProvider reads one secret per file from a local directory:
+// file/github/token reads <dir>/github/token
+type FileProvider struct {
+	dir string
+}
+
+// NewFileProvider reads from dir; a leading ~ is expanded
+func NewFileProvider(dir string) *FileProvider {
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+		}
+	}
+	return &FileProvider{dir: dir}
+}
+
+func (p *FileProvider) Name() string { return "file" }
+
+func (p *FileProvider) Get(path string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s escapes the secret store", path)
+	}
+
+	data, err := os.ReadFile(filepath.Join(p.dir, clean))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("not found in %s", p.dir)
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// passProvider reads the first line of a pass entry: pass/github/token
+type passProvider struct{}
+
+func (passProvider) Name() string { return "pass" }
+
+func (passProvider) Get(path string) (string, error) {
+	out, err := run([]string{"pass", "show", path})
+	if err != nil {
+		return "", err
+	}
+	first, _, _ := strings.Cut(out, "\n")
+	return first, nil
+}
+
+// CommandProvider runs an executable and reads the secret from stdout.
+// "{path}" in the arguments is replaced with the reference path, or the path
+// is appended when no argument contains it.
+type CommandProvider struct {
+	name string
+	argv []string
+}
+
+func NewCommandProvider(name string, argv []string) *CommandProvider {
+	return &CommandProvider{name: name, argv: argv}
+}
+
+func (p *CommandProvider) Name() string { return p.name }
+
+func (p *CommandProvider) Get(path string) (string, error) {
+	if len(p.argv) == 0 {
+		return "", errors.New("no command configured")
+	}
+
+	argv := make([]string, 0, len(p.argv)+1)
+	substituted := false
+	for _, arg := range p.argv {
+		if strings.Contains(arg, "{path}") {
+			substituted = true
+		}
+		argv = append(argv, strings.ReplaceAll(arg, "{path}", path))
+	}
+	if !substituted {
+		argv = append(argv, path)
+	}
+
+	out, err := run(argv)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(out, "\r\n"), nil
+}
+
+// run executes argv and returns stdout. Output is never included in errors.
+func run(argv []string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Stdin = os.Stdin
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("%s failed: %w", argv[0], err)
+		}
+		return "", fmt.Errorf("%s failed: %s", argv[0], msg)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,195 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeScript puts an executable shell script named name on a fresh PATH
+func writeScript(t *testing.T, name, body string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return path
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("DOTTS_TEST_TOKEN", "s3cret")
+	t.Setenv("DOTTS_TEST_EMPTY", "")
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "DOTTS_TEST_TOKEN", want: "s3cret"},
+		{path: "DOTTS_TEST_EMPTY", want: ""},
+		{path: "DOTTS_TEST_UNSET", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := envProvider{}.Get(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Get(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "github"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "github", "token"), []byte("ghp_abc\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "multi"), []byte("line one\nline two\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(dir), "outside"), []byte("leak"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr string
+	}{
+		{name: "nested", path: "github/token", want: "ghp_abc"},
+		{name: "keeps inner newlines", path: "multi", want: "line one\nline two"},
+		{name: "cleaned", path: "github/../github/token", want: "ghp_abc"},
+		{name: "missing", path: "github/nope", wantErr: "not found"},
+		{name: "parent", path: "../outside", wantErr: "escapes"},
+		{name: "absolute", path: "/etc/passwd", wantErr: "escapes"},
+	}
+
+	p := NewFileProvider(dir)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Get(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Get(%q) error = %v, want it to mention %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get(%q) error = %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("Get(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFileProviderExpandsHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{dir: "~", want: home},
+		{dir: "~/secrets", want: filepath.Join(home, "secrets")},
+		{dir: "/srv/secrets", want: "/srv/secrets"},
+		{dir: "~other/secrets", want: "~other/secrets"},
+	}
+
+	for _, tt := range tests {
+		if got := NewFileProvider(tt.dir).dir; got != tt.want {
+			t.Errorf("NewFileProvider(%q).dir = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestPassProvider(t *testing.T) {
+	writeScript(t, "pass", `
+case "$2" in
+  github/token) printf 'ghp_pass\nuser: me\n' ;;
+  *) echo "Error: $2 is not in the password store." >&2; exit 1 ;;
+esac`)
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{path: "github/token", want: "ghp_pass"},
+		{path: "missing", wantErr: "not in the password store"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := passProvider{}.Get(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Get(%q) error = %v, want it to mention %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get(%q) error = %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("Get(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandProvider(t *testing.T) {
+	script := writeScript(t, "show-secret", `
+if [ "$1" = "fail" ]; then echo "vault sealed" >&2; exit 2; fi
+if [ "$1" = "quiet" ]; then echo "leaked value"; exit 3; fi
+printf '%s|' "$@"; echo`)
+
+	tests := []struct {
+		name    string
+		argv    []string
+		path    string
+		want    string
+		wantErr string
+	}{
+		{name: "appends path", argv: []string{script, "get"}, path: "a/b", want: "get|a/b|"},
+		{name: "substitutes path", argv: []string{script, "--item={path}", "raw"}, path: "a/b", want: "--item=a/b|raw|"},
+		{name: "substitutes every placeholder", argv: []string{script, "{path}", "{path}"}, path: "x", want: "x|x|"},
+		{name: "reports stderr", argv: []string{script, "fail"}, path: "x", wantErr: "vault sealed"},
+		{name: "hides stdout on failure", argv: []string{script, "quiet"}, path: "x", wantErr: "exit status 3"},
+		{name: "no command", path: "x", wantErr: "no command configured"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewCommandProvider("cmd", tt.argv).Get(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Get(%q) error = %v, want it to mention %q", tt.path, err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), "leaked value") {
+					t.Errorf("error %q includes the command output", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get(%q) error = %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("Get(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package secret
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces secret values wherever output is shown to the user
+const Mask = "********"
+
+// SecretProvider resolves secret paths for one backend
+type SecretProvider interface {
+	// Name is the prefix used in references, e.g. "pass" in pass/github/token
+	Name() string
+
+	// Get returns the secret stored at path
+	Get(path string) (string, error)
+}
+
+// Registry routes "provider/path" references to providers and caches the
+// values for the rest of the run. Values are never persisted.
+type Registry struct {
+	providers map[string]SecretProvider
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+func NewRegistry() *Registry {
+	r := &Registry{
+		providers: make(map[string]SecretProvider),
+		cache:     make(map[string]string),
+	}
+	r.registerDefaults()
+	return r
+}
+
+func (r *Registry) registerDefaults() {
+	r.Register(envProvider{})
+	r.Register(NewFileProvider(DefaultStoreDir()))
+	r.Register(passProvider{})
+}
+
+// Register adds a provider, replacing any existing one with the same name
+func (r *Registry) Register(p SecretProvider) {
+	r.providers[p.Name()] = p
+}
+
+// Providers returns the registered provider names, sorted
+func (r *Registry) Providers() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve looks up a "provider/path" reference
+func (r *Registry) Resolve(ref string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if value, ok := r.cache[ref]; ok {
+		return value, nil
+	}
+
+	name, path, ok := strings.Cut(ref, "/")
+	if !ok || path == "" {
+		return "", fmt.Errorf("invalid secret reference %q, expected provider/path", ref)
+	}
+
+	provider, ok := r.providers[name]
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %q (available: %s)", name, strings.Join(r.Providers(), ", "))
+	}
+
+	value, err := provider.Get(path)
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %w", ref, err)
+	}
+
+	r.cache[ref] = value
+	return value, nil
+}
+
+// Redact masks every secret resolved so far in s. Longer values are masked
+// first, so a secret that starts with a shorter one is masked whole.
+func (r *Registry) Redact(s string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	values := make([]string, 0, len(r.cache))
+	for _, value := range r.cache {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	pairs := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		pairs = append(pairs, value, Mask)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
@@ -0,0 +1,133 @@
+package secret
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// countingProvider serves fixed values and counts lookups
+type countingProvider struct {
+	values map[string]string
+	calls  map[string]int
+}
+
+func (p *countingProvider) Name() string { return "fake" }
+
+func (p *countingProvider) Get(path string) (string, error) {
+	p.calls[path]++
+	value, ok := p.values[path]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return value, nil
+}
+
+func newTestRegistry() (*Registry, *countingProvider) {
+	fake := &countingProvider{
+		values: map[string]string{"token": "abc123", "empty": "", "long": "abc123456"},
+		calls:  make(map[string]int),
+	}
+	r := NewRegistry()
+	r.Register(fake)
+	return r, fake
+}
+
+func TestRegistryResolve(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{ref: "fake/token", want: "abc123"},
+		{ref: "fake/empty", want: ""},
+		{ref: "fake/missing", wantErr: "secret fake/missing: not found"},
+		{ref: "nope/token", wantErr: `unknown secret provider "nope"`},
+		{ref: "fake", wantErr: "expected provider/path"},
+		{ref: "fake/", wantErr: "expected provider/path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			r, _ := newTestRegistry()
+			got, err := r.Resolve(tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve(%q) error = %v, want it to mention %q", tt.ref, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q) error = %v", tt.ref, err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryCachesValues(t *testing.T) {
+	r, fake := newTestRegistry()
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.Resolve("fake/token"); err != nil {
+			t.Fatal(err)
+		}
+		r.Resolve("fake/missing")
+	}
+
+	if got := fake.calls["token"]; got != 1 {
+		t.Errorf("resolved values are looked up %d times, want 1", got)
+	}
+	if got := fake.calls["missing"]; got != 3 {
+		t.Errorf("failed lookups are retried %d times, want 3", got)
+	}
+}
+
+func TestRegistryProviders(t *testing.T) {
+	r, _ := newTestRegistry()
+	r.Register(NewCommandProvider("bw", []string{"bw", "get", "password"}))
+
+	want := "bw, env, fake, file, pass"
+	if got := strings.Join(r.Providers(), ", "); got != want {
+		t.Errorf("Providers() = %s, want %s", got, want)
+	}
+}
+
+func TestRegistryRedact(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolved []string
+		in       string
+		want     string
+	}{
+		{name: "nothing resolved", in: "token=abc123", want: "token=abc123"},
+		{name: "resolved value", resolved: []string{"fake/token"}, in: "token=abc123 again abc123", want: "token=" + Mask + " again " + Mask},
+		{name: "empty value is not masked", resolved: []string{"fake/empty"}, in: "token=abc123", want: "token=abc123"},
+		{name: "failed lookups are not masked", resolved: []string{"fake/missing"}, in: "missing", want: "missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestRegistry()
+			for _, ref := range tt.resolved {
+				r.Resolve(ref)
+			}
+			if got := r.Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryRedactLeavesNoPartOfASecret(t *testing.T) {
+	r, _ := newTestRegistry()
+	r.Resolve("fake/token")
+	r.Resolve("fake/long")
+
+	got := r.Redact("key=abc123456")
+	if strings.Contains(got, "456") || strings.Contains(got, "abc") {
+		t.Errorf("Redact left part of a secret: %q", got)
+	}
+}
@@ -37,6 +37,7 @@ type State struct {
 	Settings     map[string]any `json:"settings"`
 	Features     []string       `json:"features"`
 	LastApply    time.Time      `json:"last_apply"`
+	Generation   int            `json:"generation,omitempty"` // generation currently in place
 	paths        *Paths
 }
 
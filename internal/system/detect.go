@@ -35,6 +35,15 @@ const (
 	ArchUnknown Arch = "unknown"
 )
 
+// Environment is a runtime class the machine is running under
+type Environment string
+
+const (
+	EnvWSL       Environment = "wsl"
+	EnvContainer Environment = "container"
+	EnvVM        Environment = "vm"
+)
+
 type PackageManager string
 
 const (
@@ -60,6 +69,7 @@ type SystemInfo struct {
 	HasHomebrew    bool
 	HasGit         bool
 	HasCurl        bool
+	Environments   []Environment
 }
 
 func Detect() (*SystemInfo, error) {
@@ -72,6 +82,7 @@ func Detect() (*SystemInfo, error) {
 	}
 
 	info.Distro = detectDistro(info.OS)
+	info.Environments = detectEnvironments(info.OS)
 	info.PackageManager = detectPackageManager(info.OS, info.Distro)
 	info.HasHomebrew = commandExists("brew")
 
@@ -161,6 +172,76 @@ func detectDistro(osType OS) Distro {
 	return DistroUnknown
 }
 
+func detectEnvironments(osType OS) []Environment {
+	var envs []Environment
+
+	if osType == OSLinux {
+		release, _ := os.ReadFile("/proc/sys/kernel/osrelease")
+		if os.Getenv("WSL_DISTRO_NAME") != "" || strings.Contains(strings.ToLower(string(release)), "microsoft") {
+			envs = append(envs, EnvWSL)
+		}
+		if isContainer() {
+			envs = append(envs, EnvContainer)
+		}
+	}
+
+	if isVM(osType) {
+		envs = append(envs, EnvVM)
+	}
+
+	return envs
+}
+
+func isContainer() bool {
+	if os.Getenv("container") != "" {
+		return true
+	}
+	for _, marker := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	cgroup, err := os.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	content := string(cgroup)
+	return strings.Contains(content, "docker") || strings.Contains(content, "kubepods") ||
+		strings.Contains(content, "containerd") || strings.Contains(content, "lxc")
+}
+
+func isVM(osType OS) bool {
+	if osType == OSDarwin {
+		out, err := exec.Command("sysctl", "-n", "kern.hv_vmm_present").Output()
+		return err == nil && strings.TrimSpace(string(out)) == "1"
+	}
+
+	var vendor []byte
+	for _, f := range []string{"/sys/class/dmi/id/sys_vendor", "/sys/class/dmi/id/product_name"} {
+		data, _ := os.ReadFile(f)
+		vendor = append(vendor, data...)
+	}
+	content := strings.ToLower(string(vendor))
+	for _, hv := range []string{"qemu", "kvm", "vmware", "virtualbox", "parallels", "xen", "bochs", "virtual machine"} {
+		if strings.Contains(content, hv) {
+			return true
+		}
+	}
+
+	cpuinfo, err := os.ReadFile("/proc/cpuinfo")
+	return err == nil && strings.Contains(string(cpuinfo), " hypervisor")
+}
+
+// HasEnvironment reports whether the machine runs under the given environment class
+func (s *SystemInfo) HasEnvironment(env Environment) bool {
+	for _, e := range s.Environments {
+		if e == env {
+			return true
+		}
+	}
+	return false
+}
+
 func detectPackageManager(osType OS, distro Distro) PackageManager {
 	if osType == OSDarwin {
 		if commandExists("brew") {
@@ -0,0 +1,248 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/arthur404dev/dotts/internal/secret"
+)
+
+// Delimiters for full template actions. They differ from Go's default "{{ }}"
+// so configs that already use braces (helm charts, shell, editors) stay untouched.
+const (
+	LeftDelim  = "<<%"
+	RightDelim = "%>>"
+)
+
+// System holds the detected facts exposed as .system
+type System struct {
+	OS       string
+	Distro   string
+	Arch     string
+	Hostname string
+	User     string
+	Home     string
+}
+
+// Machine identifies the resolved machine exposed as .machine
+type Machine struct {
+	Name     string
+	Profile  string
+	Profiles []string
+}
+
+// Context is everything a template can see
+type Context struct {
+	Settings map[string]any
+	System   System
+	Features []string
+	Personal map[string]any
+	Machine  Machine
+}
+
+// Engine renders files that use <<% %>> actions and/or <<dotts:key>> placeholders
+type Engine struct {
+	ctx     *Context
+	data    map[string]any
+	values  map[string]string
+	policy  MissingKeyPolicy
+	secrets *secret.Registry
+}
+
+func NewEngine(ctx *Context) *Engine {
+	if ctx == nil {
+		ctx = &Context{}
+	}
+
+	e := &Engine{ctx: ctx, policy: DefaultMissingKeyPolicy}
+	e.data = map[string]any{
+		"settings": orEmpty(ctx.Settings),
+		"system": map[string]any{
+			"os":       ctx.System.OS,
+			"distro":   ctx.System.Distro,
+			"arch":     ctx.System.Arch,
+			"hostname": ctx.System.Hostname,
+			"user":     ctx.System.User,
+			"home":     ctx.System.Home,
+		},
+		"features": ctx.Features,
+		"personal": orEmpty(ctx.Personal),
+		"machine": map[string]any{
+			"name":     ctx.Machine.Name,
+			"profile":  ctx.Machine.Profile,
+			"profiles": ctx.Machine.Profiles,
+		},
+	}
+
+	// Placeholders see personal values at the top level (user.name) as they
+	// always have, plus every other namespace by its full path.
+	e.values = make(map[string]string)
+	flatten("", orEmpty(ctx.Personal), e.values)
+	for _, ns := range []string{"settings", "system", "machine"} {
+		flatten(ns, e.data[ns].(map[string]any), e.values)
+	}
+
+	return e
+}
+
+// Data returns the root object templates are executed against
+func (e *Engine) Data() map[string]any {
+	return e.data
+}
+
+// Values returns the flat key/value map used for <<dotts:key>> placeholders
+func (e *Engine) Values() map[string]string {
+	return e.values
+}
+
+// SetMissingKeyPolicy changes how unresolved values are handled
+func (e *Engine) SetMissingKeyPolicy(policy MissingKeyPolicy) {
+	e.policy = policy
+}
+
+// MissingKeyPolicy returns the policy used by Render
+func (e *Engine) MissingKeyPolicy() MissingKeyPolicy {
+	return e.policy
+}
+
+// SetSecrets enables <<dotts:secret:provider/path>> placeholders and the
+// secret template function
+func (e *Engine) SetSecrets(secrets *secret.Registry) {
+	e.secrets = secrets
+}
+
+// Redact masks resolved secret values in text shown to the user, such as diffs
+func (e *Engine) Redact(s string) string {
+	if e.secrets == nil {
+		return s
+	}
+	return e.secrets.Redact(s)
+}
+
+func (e *Engine) resolveSecret(ref string) (string, error) {
+	if e.secrets == nil {
+		return "", fmt.Errorf("secrets are not available")
+	}
+	return e.secrets.Resolve(ref)
+}
+
+// noValue is what text/template prints for a missing map key
+var noValue = []byte("<no value>")
+
+// Render executes template actions and then substitutes placeholders.
+// name is used in error messages and should be the source path.
+func (e *Engine) Render(name string, content []byte) ([]byte, error) {
+	if e.policy == MissingError {
+		missing, err := e.Check(name, content)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			return nil, &MissingKeysError{Name: name, Missing: missing}
+		}
+	}
+
+	out := content
+
+	if HasActions(content) {
+		tmpl, err := e.parse(name, content)
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, e.data); err != nil {
+			return nil, fmt.Errorf("failed to render template: %w", err)
+		}
+		out = buf.Bytes()
+
+		if !bytes.Contains(content, noValue) {
+			out = bytes.ReplaceAll(out, noValue, nil)
+		}
+	}
+
+	rendered, err := e.substituteSecrets(substitute(string(out), e.values, e.policy != MissingEmpty))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(rendered), nil
+}
+
+// substituteSecrets resolves secret placeholders. Unresolvable ones follow
+// the missing key policy.
+func (e *Engine) substituteSecrets(content string) (string, error) {
+	var firstErr error
+	out := secretRegex.ReplaceAllStringFunc(content, func(match string) string {
+		ref := secretRegex.FindStringSubmatch(match)[1]
+		value, err := e.resolveSecret(ref)
+		if err == nil {
+			return value
+		}
+
+		switch e.policy {
+		case MissingError:
+			if firstErr == nil {
+				firstErr = err
+			}
+		case MissingEmpty:
+			return ""
+		}
+		return match
+	})
+	return out, firstErr
+}
+
+// HasActions reports whether content uses <<% %>> template actions
+func HasActions(content []byte) bool {
+	return bytes.Contains(content, []byte(LeftDelim))
+}
+
+// IsTemplate reports whether content needs rendering
+func IsTemplate(content []byte) bool {
+	return HasActions(content) || HasPlaceholdersBytes(content)
+}
+
+// flatten writes nested maps as dotted keys, e.g. settings.theme
+func flatten(prefix string, m map[string]any, out map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		switch v := m[k].(type) {
+		case map[string]any:
+			flatten(key, v, out)
+		case map[any]any:
+			flatten(key, stringKeys(v), out)
+		case []string:
+			out[key] = strings.Join(v, ",")
+		case nil:
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+func stringKeys(m map[any]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[fmt.Sprint(k)] = v
+	}
+	return out
+}
+
+func orEmpty(m map[string]any) map[string]any {
+	if m == nil {
+		return map[string]any{}
+	}
+	return m
+}
@@ -0,0 +1,110 @@
+package template
+
+import "testing"
+
+func TestEngineRender(t *testing.T) {
+	e := NewEngine(&Context{
+		Settings: map[string]any{"theme": "dark", "font": map[string]any{"size": 12}},
+		System:   System{OS: "linux", Distro: "arch", Hostname: "box"},
+		Features: []string{"wayland", "dev"},
+		Personal: map[string]any{"user": map[string]any{"name": "Ada"}},
+		Machine:  Machine{Name: "laptop", Profile: "base"},
+	})
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "plain text", content: "set -g mouse on\n", want: "set -g mouse on\n"},
+		{name: "field", content: "theme = <<% .settings.theme %>>", want: "theme = dark"},
+		{name: "nested field", content: "size = <<% .settings.font.size %>>", want: "size = 12"},
+		{name: "system and machine", content: "<<% .system.hostname %>>/<<% .machine.name %>>", want: "box/laptop"},
+		{
+			name:    "conditional with trimming",
+			content: "a\n<<%- if hasFeature \"wayland\" %>>\nwayland\n<<%- else %>>\nx11\n<<%- end %>>\n",
+			want:    "a\nwayland\n",
+		},
+		{name: "range", content: "<<% range .features %>>[<<% . %>>]<<% end %>>", want: "[wayland][dev]"},
+		{name: "go braces left alone", content: "{{ .Values.image }} <<% .settings.theme %>>", want: "{{ .Values.image }} dark"},
+
+		{name: "upper", content: `<<% upper .settings.theme %>>`, want: "DARK"},
+		{name: "default", content: `<<% default "mono" .settings.missing %>>`, want: "mono"},
+		{name: "default unused", content: `<<% .settings.theme | default "light" %>>`, want: "dark"},
+		{name: "join", content: `<<% join "," .features %>>`, want: "wayland,dev"},
+		{name: "setting", content: `<<% setting "font.size" %>>`, want: "12"},
+		{name: "isOS", content: `<<% if isOS "Linux" %>>yes<<% end %>>`, want: "yes"},
+		{name: "isDistro", content: `<<% if isDistro "debian" %>>yes<<% else %>>no<<% end %>>`, want: "no"},
+		{name: "ternary", content: `<<% ternary "on" "off" (hasFeature "dev") %>>`, want: "on"},
+		{name: "quote", content: `<<% quote .personal.user.name %>>`, want: `"Ada"`},
+		{name: "replace", content: `<<% replace "a" "4" "banana" %>>`, want: "b4n4n4"},
+		{name: "nindent", content: `x:<<% nindent 2 "a\nb" %>>`, want: "x:\n  a\n  b"},
+		{name: "toJson", content: `<<% toJson .settings.font %>>`, want: `{"size":12}`},
+		{name: "dict and keys", content: `<<% keys (dict "b" 1 "a" 2) | join " " %>>`, want: "a b"},
+
+		{name: "placeholder", content: "name = <<dotts:user.name>>", want: "name = Ada"},
+		{name: "namespaced placeholder", content: "<<dotts:settings.theme>> <<dotts:machine.name>>", want: "dark laptop"},
+		{
+			name:    "actions and placeholders together",
+			content: "<<% if eq .settings.theme \"dark\" %>>name = <<dotts:user.name>><<% end %>>",
+			want:    "name = Ada",
+		},
+		{
+			name:    "placeholder written by an action",
+			content: `<<% printf "<<dotts:%s>>" "user.name" %>>`,
+			want:    "Ada",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.Render("test", []byte(tt.content))
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineRenderErrors(t *testing.T) {
+	e := NewEngine(nil)
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "unclosed action", content: "<<% if true %>>"},
+		{name: "unknown function", content: "<<% nope %>>"},
+		{name: "failing helper", content: `<<% dict "odd" %>>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := e.Render("test", []byte(tt.content)); err == nil {
+				t.Errorf("Render(%q) error = nil, want an error", tt.content)
+			}
+		})
+	}
+}
+
+func TestIsTemplate(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{content: "plain", want: false},
+		{content: "{{ .go }}", want: false},
+		{content: "<<% .settings.theme %>>", want: true},
+		{content: "<<dotts:user.name>>", want: true},
+		{content: "<<dotts:secret:pass/github>>", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := IsTemplate([]byte(tt.content)); got != tt.want {
+			t.Errorf("IsTemplate(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	gotemplate "text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/arthur404dev/dotts/internal/config"
+)
+
+// funcs is the helper library available to every template
+func (e *Engine) funcs() gotemplate.FuncMap {
+	return gotemplate.FuncMap{
+		// strings
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"repeat":     func(n int, s string) string { return strings.Repeat(s, n) },
+		"quote":      func(v any) string { return fmt.Sprintf("%q", toString(v)) },
+		"squote":     func(v any) string { return "'" + toString(v) + "'" },
+		"indent":     indent,
+		"nindent":    func(n int, s string) string { return "\n" + indent(n, s) },
+
+		// values
+		"default":  defaultValue,
+		"coalesce": coalesce,
+		"empty":    isEmpty,
+		"ternary": func(yes, no any, cond bool) any {
+			if cond {
+				return yes
+			}
+			return no
+		},
+		"toString": toString,
+		"toJson":   toJSON,
+		"toYaml":   toYAML,
+
+		// collections
+		"list": func(items ...any) []any { return items },
+		"dict": dict,
+		"has":  has,
+		"keys": keys,
+
+		// machine
+		"hasFeature": func(name string) bool { return has(name, e.ctx.Features) },
+		"setting":    func(key string) any { v, _ := config.LookupSetting(e.ctx.Settings, key); return v },
+		"isOS":       func(name string) bool { return strings.EqualFold(e.ctx.System.OS, name) },
+		"isDistro":   func(name string) bool { return strings.EqualFold(e.ctx.System.Distro, name) },
+		"env":        os.Getenv,
+		"exists":     func(path string) bool { _, err := os.Stat(expandHome(path)); return err == nil },
+		"lookPath":   func(name string) bool { _, err := exec.LookPath(name); return err == nil },
+		"expandHome": expandHome,
+		"secret":     e.resolveSecret,
+	}
+}
+
+func join(sep string, v any) string {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return toString(v)
+	}
+	parts := make([]string, rv.Len())
+	for i := range parts {
+		parts[i] = toString(rv.Index(i).Interface())
+	}
+	return strings.Join(parts, sep)
+}
+
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func defaultValue(def, v any) any {
+	if isEmpty(v) {
+		return def
+	}
+	return v
+}
+
+func coalesce(values ...any) any {
+	for _, v := range values {
+		if !isEmpty(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+func isEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func toString(v any) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func toYAML(v any) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict needs an even number of arguments")
+	}
+	m := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		m[toString(pairs[i])] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// has reports whether list contains item
+func has(item any, list any) bool {
+	rv := reflect.ValueOf(list)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return false
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if toString(rv.Index(i).Interface()) == toString(item) {
+			return true
+		}
+	}
+	return false
+}
+
+func keys(m any) []string {
+	rv := reflect.ValueOf(m)
+	if rv.Kind() != reflect.Map {
+		return nil
+	}
+	out := make([]string, 0, rv.Len())
+	for _, k := range rv.MapKeys() {
+		out = append(out, toString(k.Interface()))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
@@ -0,0 +1,222 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	gotemplate "text/template"
+	"text/template/parse"
+)
+
+// MissingKeyPolicy controls what happens when a template references a value
+// that is not set
+type MissingKeyPolicy string
+
+const (
+	MissingError MissingKeyPolicy = "error" // fail the render
+	MissingWarn  MissingKeyPolicy = "warn"  // keep the placeholder and report it
+	MissingKeep  MissingKeyPolicy = "keep"  // keep the placeholder silently
+	MissingEmpty MissingKeyPolicy = "empty" // substitute an empty string
+)
+
+// DefaultMissingKeyPolicy refuses to write files with unresolved values
+const DefaultMissingKeyPolicy = MissingError
+
+// ParseMissingKeyPolicy validates a policy name; "" selects the default
+func ParseMissingKeyPolicy(s string) (MissingKeyPolicy, error) {
+	switch p := MissingKeyPolicy(strings.ToLower(s)); p {
+	case "":
+		return DefaultMissingKeyPolicy, nil
+	case MissingError, MissingWarn, MissingKeep, MissingEmpty:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown missing key policy %q (expected error, warn, keep or empty)", s)
+	}
+}
+
+// MissingKey is an unresolved reference in a template
+type MissingKey struct {
+	Key    string
+	Line   int
+	Reason string // why a secret could not be resolved
+}
+
+func (m MissingKey) String() string {
+	return fmt.Sprintf("line %d: %s", m.Line, m.Key)
+}
+
+// MissingKeysError is returned by Render under MissingError
+type MissingKeysError struct {
+	Name    string
+	Missing []MissingKey
+}
+
+func (e *MissingKeysError) Error() string {
+	keys := make([]string, len(e.Missing))
+	for i, m := range e.Missing {
+		keys[i] = fmt.Sprintf("%s (line %d)", m.Key, m.Line)
+	}
+	return "missing template values: " + strings.Join(keys, ", ")
+}
+
+// optionalFuncs mark a pipeline as handling absent values itself
+var optionalFuncs = map[string]bool{
+	"default":  true,
+	"coalesce": true,
+	"empty":    true,
+	"ternary":  true,
+}
+
+// Check lists every placeholder and printed .field in content that has no
+// value. Fields only used as if/with conditions or passed through default
+// and similar helpers are optional and not reported.
+func (e *Engine) Check(name string, content []byte) ([]MissingKey, error) {
+	var missing []MissingKey
+
+	if HasActions(content) {
+		tmpl, err := e.parse(name, content)
+		if err != nil {
+			return nil, err
+		}
+		w := &fieldWalker{engine: e, content: content}
+		w.walk(tmpl.Tree.Root, true)
+		missing = append(missing, w.missing...)
+	}
+
+	for _, loc := range placeholderRegex.FindAllSubmatchIndex(content, -1) {
+		key := string(content[loc[2]:loc[3]])
+		if val, ok := e.values[key]; !ok || val == "" {
+			missing = append(missing, MissingKey{Key: key, Line: lineAt(content, loc[0])})
+		}
+	}
+
+	for _, loc := range secretRegex.FindAllSubmatchIndex(content, -1) {
+		ref := string(content[loc[2]:loc[3]])
+		if _, err := e.resolveSecret(ref); err != nil {
+			missing = append(missing, MissingKey{
+				Key:    "secret:" + ref,
+				Line:   lineAt(content, loc[0]),
+				Reason: err.Error(),
+			})
+		}
+	}
+
+	sort.SliceStable(missing, func(i, j int) bool {
+		return missing[i].Line < missing[j].Line
+	})
+	return missing, nil
+}
+
+func (e *Engine) parse(name string, content []byte) (*gotemplate.Template, error) {
+	tmpl, err := gotemplate.New(name).
+		Delims(LeftDelim, RightDelim).
+		Funcs(e.funcs()).
+		Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+type fieldWalker struct {
+	engine  *Engine
+	content []byte
+	missing []MissingKey
+}
+
+// walk visits the tree; rootDot is false inside range/with where "." no
+// longer refers to the template data.
+func (w *fieldWalker) walk(node parse.Node, rootDot bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.walk(child, rootDot)
+		}
+	case *parse.ActionNode:
+		w.pipe(n.Pipe, rootDot)
+	case *parse.IfNode:
+		w.walk(n.List, rootDot)
+		w.walk(n.ElseList, rootDot)
+	case *parse.WithNode:
+		w.walk(n.List, false)
+		w.walk(n.ElseList, rootDot)
+	case *parse.RangeNode:
+		w.walk(n.List, false)
+		w.walk(n.ElseList, rootDot)
+	}
+}
+
+func (w *fieldWalker) pipe(pipe *parse.PipeNode, rootDot bool) {
+	if pipe == nil || len(pipe.Decl) > 0 {
+		return
+	}
+	for _, cmd := range pipe.Cmds {
+		if len(cmd.Args) > 0 {
+			if id, ok := cmd.Args[0].(*parse.IdentifierNode); ok && optionalFuncs[id.Ident] {
+				return
+			}
+		}
+	}
+
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			switch a := arg.(type) {
+			case *parse.FieldNode:
+				if rootDot {
+					w.check(a.Ident, a.Position())
+				}
+			case *parse.VariableNode:
+				if len(a.Ident) > 1 && a.Ident[0] == "$" {
+					w.check(a.Ident[1:], a.Position())
+				}
+			case *parse.PipeNode:
+				w.pipe(a, rootDot)
+			}
+		}
+	}
+}
+
+func (w *fieldWalker) check(path []string, pos parse.Pos) {
+	if w.engine.hasPath(path) {
+		return
+	}
+	w.missing = append(w.missing, MissingKey{
+		Key:  strings.Join(path, "."),
+		Line: lineAt(w.content, int(pos)),
+	})
+}
+
+// hasPath reports whether a dotted field path resolves to a value
+func (e *Engine) hasPath(path []string) bool {
+	var current any = e.data
+	for _, key := range path {
+		switch m := current.(type) {
+		case map[string]any:
+			v, ok := m[key]
+			if !ok {
+				return false
+			}
+			current = v
+		case map[any]any:
+			v, ok := m[key]
+			if !ok {
+				return false
+			}
+			current = v
+		default:
+			return false
+		}
+	}
+	return current != nil
+}
+
+func lineAt(content []byte, offset int) int {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
@@ -0,0 +1,142 @@
+package template
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/arthur404dev/dotts/internal/secret"
+)
+
+func newTestEngine(policy MissingKeyPolicy) *Engine {
+	e := NewEngine(&Context{
+		Settings: map[string]any{"theme": "dark", "font": map[string]any{"size": 12}},
+		System:   System{OS: "linux", Hostname: "box"},
+		Personal: map[string]any{"user": map[string]any{"name": "Ada", "email": ""}},
+	})
+	e.SetMissingKeyPolicy(policy)
+
+	secrets := secret.NewRegistry()
+	secrets.Register(secret.NewCommandProvider("test", []string{"echo"}))
+	e.SetSecrets(secrets)
+	return e
+}
+
+func TestParseMissingKeyPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    MissingKeyPolicy
+		wantErr bool
+	}{
+		{in: "", want: MissingError},
+		{in: "error", want: MissingError},
+		{in: "WARN", want: MissingWarn},
+		{in: "keep", want: MissingKeep},
+		{in: "empty", want: MissingEmpty},
+		{in: "ignore", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMissingKeyPolicy(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMissingKeyPolicy(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMissingKeyPolicy(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{name: "all set", content: "<<dotts:user.name>> <<% .settings.theme %>> <<% .system.os %>>"},
+		{name: "missing placeholder", content: "a\n<<dotts:user.phone>>", want: []string{"line 2: user.phone"}},
+		{name: "empty placeholder counts as missing", content: "<<dotts:user.email>>", want: []string{"line 1: user.email"}},
+		{name: "nested setting", content: "<<% .settings.font.size %>> <<% .settings.font.face %>>", want: []string{"line 1: settings.font.face"}},
+		{name: "missing field", content: "x\ny\n<<% .settings.editor %>>", want: []string{"line 3: settings.editor"}},
+		{name: "condition is optional", content: "<<% if .settings.editor %>>e<<% end %>>"},
+		{name: "default is optional", content: `<<% .settings.editor | default "vim" %>>`},
+		{name: "field inside with is relative", content: "<<% with .settings.font %>><<% .face %>><<% end %>>"},
+		{name: "printed inside if", content: "<<% if true %>><<% .personal.user.phone %>><<% end %>>", want: []string{"line 1: personal.user.phone"}},
+		{name: "resolvable secret", content: "<<dotts:secret:test/token>>"},
+		{name: "unknown secret provider", content: "\n<<dotts:secret:nope/token>>", want: []string{"line 2: secret:nope/token"}},
+		{name: "sorted by line", content: "<<% .settings.b %>>\n<<dotts:user.a>>\n<<% .settings.c %>>", want: []string{"line 1: settings.b", "line 2: user.a", "line 3: settings.c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, err := newTestEngine(MissingError).Check("test", []byte(tt.content))
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			var got []string
+			for _, m := range missing {
+				got = append(got, m.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineRenderMissingKeyPolicy(t *testing.T) {
+	const content = "name=<<dotts:user.name>> phone=<<dotts:user.phone>> editor=<<% .settings.editor %>> key=<<dotts:secret:nope/key>>"
+
+	tests := []struct {
+		policy  MissingKeyPolicy
+		want    string
+		wantErr bool
+	}{
+		{policy: MissingError, wantErr: true},
+		{policy: MissingWarn, want: "name=Ada phone=<<dotts:user.phone>> editor= key=<<dotts:secret:nope/key>>"},
+		{policy: MissingKeep, want: "name=Ada phone=<<dotts:user.phone>> editor= key=<<dotts:secret:nope/key>>"},
+		{policy: MissingEmpty, want: "name=Ada phone= editor= key="},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			got, err := newTestEngine(tt.policy).Render("test", []byte(content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineRenderMissingKeysError(t *testing.T) {
+	_, err := newTestEngine(MissingError).Render("src/rc", []byte("<<dotts:user.phone>>\n<<% .settings.editor %>>"))
+
+	var missingErr *MissingKeysError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("Render() error = %v, want a MissingKeysError", err)
+	}
+	if missingErr.Name != "src/rc" || len(missingErr.Missing) != 2 {
+		t.Errorf("MissingKeysError = %+v, want both keys of src/rc", missingErr)
+	}
+	want := "missing template values: user.phone (line 1), settings.editor (line 2)"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestEngineRenderKeepsLiteralNoValue(t *testing.T) {
+	const content = "<no value> <<% .settings.editor %>>"
+
+	got, err := newTestEngine(MissingWarn).Render("test", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "<no value> <no value>" {
+		t.Errorf("Render() = %q, want the literal text kept", got)
+	}
+}
@@ -6,7 +6,16 @@ import (
 
 var placeholderRegex = regexp.MustCompile(`<<dotts:([a-z][a-z0-9_.]*)>>`)
 
+// secretRegex matches <<dotts:secret:provider/path>>
+var secretRegex = regexp.MustCompile(`<<dotts:secret:([a-z][a-z0-9_-]*/[^<>\s]+)>>`)
+
 func Apply(content string, values map[string]string) string {
+	return substitute(content, values, true)
+}
+
+// substitute replaces placeholders, leaving unknown ones verbatim when
+// keepMissing is set and removing them otherwise
+func substitute(content string, values map[string]string, keepMissing bool) string {
 	return placeholderRegex.ReplaceAllStringFunc(content, func(match string) string {
 		submatch := placeholderRegex.FindStringSubmatch(match)
 		if len(submatch) < 2 {
@@ -18,7 +27,10 @@ func Apply(content string, values map[string]string) string {
 			return val
 		}
 
-		return match
+		if keepMissing {
+			return match
+		}
+		return ""
 	})
 }
 
@@ -27,11 +39,31 @@ func ApplyBytes(content []byte, values map[string]string) []byte {
 }
 
 func HasPlaceholders(content string) bool {
-	return placeholderRegex.MatchString(content)
+	return placeholderRegex.MatchString(content) || secretRegex.MatchString(content)
 }
 
 func HasPlaceholdersBytes(content []byte) bool {
-	return placeholderRegex.Match(content)
+	return placeholderRegex.Match(content) || secretRegex.Match(content)
+}
+
+// actionRegex matches one <<% %>> template action
+var actionRegex = regexp.MustCompile(`(?s)<<%.*?%>>`)
+
+// secretFuncRegex matches a call of the secret template function
+var secretFuncRegex = regexp.MustCompile(`\bsecret\b`)
+
+// UsesSecrets reports whether rendering content may resolve a secret, through
+// a secret placeholder or the secret function. It errs on the side of yes.
+func UsesSecrets(content []byte) bool {
+	if secretRegex.Match(content) {
+		return true
+	}
+	for _, action := range actionRegex.FindAll(content, -1) {
+		if secretFuncRegex.Match(action) {
+			return true
+		}
+	}
+	return false
 }
 
 func ExtractPlaceholders(content string) []string {
@@ -0,0 +1,26 @@
+package template
+
+import "testing"
+
+func TestUsesSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "placeholder", content: "token = <<dotts:secret:op/github/token>>\n", want: true},
+		{name: "function", content: `token = <<% secret "pass/github" %>>`, want: true},
+		{name: "function in pipeline", content: `<<%- "pass/github" | secret | printf "%s" -%>>`, want: true},
+		{name: "plain placeholders", content: "name = <<dotts:name>>\n", want: false},
+		{name: "word outside actions", content: "# secret keys live elsewhere\n<<% .machine.name %>>", want: false},
+		{name: "setting named like it", content: "<<% .settings.secretive %>>", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UsesSecrets([]byte(tt.content)); got != tt.want {
+				t.Errorf("UsesSecrets(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
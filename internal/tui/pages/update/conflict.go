@@ -0,0 +1,95 @@
+package update
+
+import (
+	"os"
+	"strings"
+
+	"github.com/arthur404dev/dotts/internal/diff"
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/pkg/vetru/components/input"
+	"github.com/arthur404dev/dotts/pkg/vetru/theme"
+)
+
+// conflict is an existing file the update would back up and replace, and
+// what the user chose to do with it
+type conflict struct {
+	action   linker.LinkAction
+	local    []byte // nil for directories
+	incoming []byte
+	diff     string
+	decision *linker.Decision // nil until decided
+}
+
+func newConflict(lnk *linker.SymlinkLinker, action linker.LinkAction) (*conflict, error) {
+	c := &conflict{action: action}
+	info, err := os.Stat(action.Target)
+	if err != nil {
+		return nil, err
+	}
+	if action.IsDir || info.IsDir() {
+		return c, nil
+	}
+
+	if c.local, err = os.ReadFile(action.Target); err != nil {
+		return nil, err
+	}
+	if c.incoming, err = lnk.Incoming(action); err != nil {
+		return nil, err
+	}
+
+	c.diff = action.Diff
+	if c.diff == "" {
+		c.diff = diff.Unified(action.Target, action.Source, string(c.local), string(c.incoming))
+	}
+	return c, nil
+}
+
+// options lists what can be done with the file, as 'dotts apply' offers
+func (c *conflict) options() []input.SelectItem {
+	items := []input.SelectItem{
+		{ID: string(linker.ResolveOverwrite), Label: "Overwrite", Description: "The local file is backed up"},
+		{ID: string(linker.ResolveKeep), Label: "Keep the local file"},
+	}
+	if c.local != nil && c.action.CanAdopt() {
+		items = append(items, input.SelectItem{ID: string(linker.ResolveAdopt), Label: "Adopt", Description: "Copy the local file into the repo"})
+	}
+	if c.local != nil {
+		items = append(items, input.SelectItem{ID: string(linker.ResolveMerge), Label: "Merge in $EDITOR"})
+	}
+	return items
+}
+
+// label describes the decision for the review list
+func (c *conflict) label() string {
+	if c.decision == nil {
+		return "undecided"
+	}
+	label := map[linker.Resolution]string{
+		linker.ResolveOverwrite: "overwrite",
+		linker.ResolveKeep:      "keep",
+		linker.ResolveAdopt:     "adopt",
+		linker.ResolveMerge:     "merge",
+	}[c.decision.Resolution]
+	if c.decision.Remember {
+		label += ", remembered"
+	}
+	return label
+}
+
+// renderDiff colors a unified diff like 'dotts apply --dry-run' prints it
+func renderDiff(t *theme.Theme, d string) string {
+	lines := strings.Split(strings.TrimRight(d, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = t.S().Muted.Render(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = t.S().Success.Render(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = t.S().Error.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = t.S().Info.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
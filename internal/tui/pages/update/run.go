@@ -0,0 +1,214 @@
+package update
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/internal/config"
+	"github.com/arthur404dev/dotts/internal/linker"
+	"github.com/arthur404dev/dotts/internal/state"
+	"github.com/arthur404dev/dotts/internal/system"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// planned is an update waiting to be confirmed: the plan made from the
+// fetched commit and the existing files it would replace
+type planned struct {
+	state     *state.State
+	source    *config.Source
+	applier   *apply.Applier
+	plan      *apply.Plan
+	opts      apply.ApplyOptions
+	from, to  string // config repo commits before and after the update
+	conflicts []*conflict
+}
+
+type plannedMsg struct {
+	planned *planned
+	err     error
+}
+
+type appliedMsg struct {
+	result *apply.ApplyResult
+	err    error
+}
+
+// planUpdate fetches the config repo and plans applying its upstream commit,
+// as 'dotts update' does before asking to confirm
+func planUpdate() tea.Msg {
+	p, err := newPlanned()
+	return plannedMsg{planned: p, err: err}
+}
+
+func newPlanned() (*planned, error) {
+	st, err := state.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+	if !st.IsInitialized() {
+		return nil, errors.New("dotts is not initialized on this system, run 'dotts init' first")
+	}
+
+	machineName := st.Machine.Name
+	if machineName == "" {
+		machineName = st.Machine.Profile
+	}
+	if machineName == "" {
+		return nil, errors.New("no machine recorded in state, run 'dotts init' first")
+	}
+
+	sysInfo, err := system.Detect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect system: %w", err)
+	}
+
+	configPath := st.ConfigSource.Path
+	if configPath == "" {
+		configPath = state.GetPaths().ConfigRepo
+	}
+	source := &config.Source{
+		URL:     st.ConfigSource.URL,
+		Path:    configPath,
+		Branch:  st.ConfigSource.Branch,
+		IsLocal: st.ConfigSource.Type == state.SourceTypeLocal,
+	}
+
+	applier, err := apply.New(sysInfo, configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize applier: %w", err)
+	}
+
+	if err := source.Fetch(); err != nil {
+		return nil, err
+	}
+
+	current, _ := source.GetCurrentCommit()
+	to, err := source.GetUpstreamCommit()
+	if err != nil {
+		to = current
+	}
+
+	// plan from a checkout of the upstream commit, so nothing changes before
+	// the update is confirmed
+	preview := applier
+	if to != "" && to != current {
+		checkout, err := source.Checkout(to)
+		if err != nil {
+			return nil, err
+		}
+		defer source.RemoveCheckout(checkout)
+
+		preview, err = apply.NewPreview(sysInfo, configPath, checkout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize applier: %w", err)
+		}
+	}
+
+	opts := apply.ApplyOptions{
+		MachineName: machineName,
+		Lifecycle:   apply.LifecycleUpdate,
+	}
+	plan, err := preview.Plan(context.Background(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan update: %w", err)
+	}
+
+	p := &planned{
+		state:   st,
+		source:  source,
+		applier: applier,
+		plan:    plan,
+		opts:    opts,
+		from:    current,
+		to:      to,
+	}
+
+	// read both sides now, while the checkout still exists
+	for _, action := range preview.Conflicts(plan, opts) {
+		c, err := newConflict(preview.GetLinker(), action)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", action.Target, err)
+		}
+		p.conflicts = append(p.conflicts, c)
+	}
+
+	return p, nil
+}
+
+// resolve answers OnExisting with the decisions made on the page. Files
+// nobody decided about are backed up and replaced, as without a prompt.
+func (p *planned) resolve(action linker.LinkAction, local []byte) (linker.Decision, error) {
+	for _, c := range p.conflicts {
+		if c.action.Target == action.Target && c.decision != nil {
+			return *c.decision, nil
+		}
+	}
+	return linker.Decision{Resolution: linker.ResolveOverwrite}, nil
+}
+
+// applyCommand runs a confirmed update with the terminal released, so the
+// progress output of the apply and any package manager prompts reach the user
+type applyCommand struct {
+	planned *planned
+	result  *apply.ApplyResult
+
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (c *applyCommand) SetStdin(r io.Reader)  { c.stdin = r }
+func (c *applyCommand) SetStdout(w io.Writer) { c.stdout = w }
+func (c *applyCommand) SetStderr(io.Writer)   {}
+
+func (c *applyCommand) Run() error {
+	p := c.planned
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// move to exactly the planned commit, even if the upstream moved on
+	if p.to != "" && p.to != p.from {
+		if err := p.source.FastForward(p.to); err != nil {
+			return err
+		}
+	}
+	if commit, err := p.source.GetCurrentCommit(); err == nil {
+		p.state.UpdateLastPull(commit)
+		if err := p.state.Save(); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+	}
+
+	opts := p.opts
+	opts.OnExisting = p.resolve
+	c.result = p.applier.Execute(ctx, p.plan, opts)
+
+	if c.result.Success() {
+		p.state.UpdateLastApply()
+		if c.result.Generation > 0 {
+			p.state.Generation = c.result.Generation
+		}
+		if err := p.state.Save(); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+	}
+
+	fmt.Fprint(c.stdout, "\nPress enter to return to dotts")
+	bufio.NewReader(c.stdin).ReadString('\n')
+	return nil
+}
+
+// applyUpdate executes p outside the TUI and reports the result
+func applyUpdate(p *planned) tea.Cmd {
+	c := &applyCommand{planned: p, stdin: os.Stdin, stdout: os.Stdout}
+	return tea.Exec(c, func(err error) tea.Msg {
+		return appliedMsg{result: c.result, err: err}
+	})
+}
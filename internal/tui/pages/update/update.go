@@ -1,43 +1,398 @@
 package update
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/arthur404dev/dotts/internal/apply"
+	"github.com/arthur404dev/dotts/internal/diff"
+	"github.com/arthur404dev/dotts/internal/linker"
 	"github.com/arthur404dev/dotts/internal/tui/app"
 	"github.com/arthur404dev/dotts/pkg/vetru/components"
+	"github.com/arthur404dev/dotts/pkg/vetru/components/input"
+	"github.com/arthur404dev/dotts/pkg/vetru/keys"
 	"github.com/arthur404dev/dotts/pkg/vetru/messages"
 	"github.com/arthur404dev/dotts/pkg/vetru/theme"
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
+type stage int
+
+const (
+	stageIdle stage = iota
+	stagePlanning
+	stageReview
+	stageConflict
+	stageApplying
+	stageDone
+)
+
+// mergedMsg reports that the editor merging a conflict at path exited
+type mergedMsg struct {
+	path string
+	err  error
+}
+
+// Update fetches the config repo, shows what updating would change and asks
+// about existing files at targets before applying it
 type Update struct {
 	theme  *theme.Theme
 	width  int
 	height int
+
+	stage   stage
+	spinner *components.Spinner
+	planned *planned
+	result  *apply.ApplyResult
+	err     error
+
+	cursor   int       // selected conflict in the review
+	current  *conflict // conflict being decided
+	walking  bool      // deciding every undecided conflict before applying
+	problem  string    // why the last decision failed, e.g. an unfinished merge
+	options  *input.Select
+	remember *components.Checkbox
+	diffView *components.Scrollable
 }
 
 func New(t *theme.Theme) *Update {
-	return &Update{theme: t}
+	return &Update{
+		theme:    t,
+		spinner:  components.NewSpinner(t).SetLabel("Fetching config source..."),
+		remember: components.NewCheckbox(t, "Remember this choice"),
+		diffView: components.NewScrollable(t),
+	}
+}
+
+func (u *Update) ID() messages.PageID { return app.PageUpdate }
+func (u *Update) Title() string       { return "Update" }
+func (u *Update) SetSize(w, h int)    { u.width, u.height = w, h }
+func (u *Update) Blur()               {}
+func (u *Update) Init() tea.Cmd       { return nil }
+
+// Focus checks for updates the first time the page is shown
+func (u *Update) Focus() tea.Cmd {
+	if u.stage == stageIdle {
+		return u.check()
+	}
+	return nil
+}
+
+func (u *Update) check() tea.Cmd {
+	u.stage = stagePlanning
+	u.planned, u.result, u.err = nil, nil, nil
+	u.cursor = 0
+	return tea.Batch(u.spinner.Init(), planUpdate)
+}
+
+func (u *Update) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case plannedMsg:
+		u.planned, u.err = msg.planned, msg.err
+		u.stage = stageReview
+		if msg.err != nil {
+			u.stage = stageDone
+		}
+		return u, nil
+
+	case appliedMsg:
+		u.result, u.err = msg.result, msg.err
+		u.stage = stageDone
+		return u, nil
+
+	case mergedMsg:
+		return u.merged(msg)
+
+	case tea.KeyMsg:
+		switch u.stage {
+		case stageReview:
+			return u.handleReviewKey(msg)
+		case stageConflict:
+			return u.handleConflictKey(msg)
+		case stageDone:
+			if msg.String() == "r" {
+				return u, u.check()
+			}
+		}
+		return u, nil
+	}
+
+	if u.stage == stagePlanning {
+		var cmd tea.Cmd
+		u.spinner, cmd = u.spinner.Update(msg)
+		return u, cmd
+	}
+	return u, nil
+}
+
+func (u *Update) handleReviewKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	conflicts := u.planned.conflicts
+
+	switch {
+	case key.Matches(msg, keys.ListUp):
+		if u.cursor > 0 {
+			u.cursor--
+		}
+	case key.Matches(msg, keys.ListDown):
+		if u.cursor < len(conflicts)-1 {
+			u.cursor++
+		}
+	case key.Matches(msg, keys.Confirm):
+		if len(conflicts) > 0 {
+			u.walking = false
+			return u, u.open(conflicts[u.cursor])
+		}
+	case msg.String() == "a":
+		if u.planned.plan.IsEmpty() {
+			return u, nil
+		}
+		u.walking = true
+		return u, u.next()
+	case msg.String() == "r":
+		return u, u.check()
+	}
+	return u, nil
+}
+
+func (u *Update) handleConflictKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Escape):
+		u.walking = false
+		u.stage = stageReview
+	case key.Matches(msg, keys.Confirm):
+		return u.decide()
+	case msg.String() == " ":
+		u.remember.Toggle()
+	case key.Matches(msg, keys.ScrollUp):
+		u.diffView.ScrollUp(u.diffView.VisibleLines() / 2)
+	case key.Matches(msg, keys.ScrollDown):
+		u.diffView.ScrollDown(u.diffView.VisibleLines() / 2)
+	default:
+		var cmd tea.Cmd
+		u.options, cmd = u.options.Update(msg)
+		return u, cmd
+	}
+	return u, nil
+}
+
+// open shows c to decide about
+func (u *Update) open(c *conflict) tea.Cmd {
+	u.stage = stageConflict
+	u.current = c
+	u.problem = ""
+
+	u.options = input.NewSelect(u.theme, "How should dotts handle it?", c.options())
+	if c.decision != nil {
+		u.options.SetCursorByID(string(c.decision.Resolution))
+	}
+	u.remember.SetChecked(c.decision != nil && c.decision.Remember)
+
+	content := u.theme.S().Muted.Render("Same content as the repo")
+	switch {
+	case c.local == nil:
+		content = u.theme.S().Muted.Render("A directory is in the way")
+	case c.diff != "":
+		content = renderDiff(u.theme, c.diff)
+	}
+	u.diffView.SetContent(content)
+	u.diffView.GotoTop()
+
+	return u.options.Focus()
+}
+
+// decide records the highlighted option for the current conflict. Merging
+// opens the editor first.
+func (u *Update) decide() (tea.Model, tea.Cmd) {
+	c := u.current
+	resolution := linker.Resolution(u.options.SelectedID())
+
+	if resolution == linker.ResolveMerge {
+		path, err := diff.WriteMerge(c.action.Target, c.local, c.incoming, "repo")
+		if err != nil {
+			u.problem = err.Error()
+			return u, nil
+		}
+		return u, tea.ExecProcess(diff.Editor(path), func(err error) tea.Msg {
+			return mergedMsg{path: path, err: err}
+		})
+	}
+
+	remember := u.remember.Checked() && (resolution == linker.ResolveKeep || resolution == linker.ResolveOverwrite)
+	c.decision = &linker.Decision{Resolution: resolution, Remember: remember}
+	return u, u.next()
+}
+
+func (u *Update) merged(msg mergedMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		os.Remove(msg.path)
+		u.problem = fmt.Sprintf("editor failed: %v", msg.err)
+		return u, nil
+	}
+
+	merged, err := diff.ReadMerge(msg.path)
+	if err != nil {
+		u.problem = err.Error()
+		return u, nil
+	}
+	u.current.decision = &linker.Decision{Resolution: linker.ResolveMerge, Merged: merged}
+	return u, u.next()
+}
+
+// next moves on after a decision: to the next undecided conflict, or to
+// applying the update once every conflict is decided
+func (u *Update) next() tea.Cmd {
+	if !u.walking {
+		u.stage = stageReview
+		return nil
+	}
+	for _, c := range u.planned.conflicts {
+		if c.decision == nil {
+			return u.open(c)
+		}
+	}
+	u.walking = false
+	u.stage = stageApplying
+	return applyUpdate(u.planned)
 }
 
-func (u *Update) ID() messages.PageID                     { return app.PageUpdate }
-func (u *Update) Title() string                           { return "Update" }
-func (u *Update) SetSize(w, h int)                        { u.width, u.height = w, h }
-func (u *Update) Focus() tea.Cmd                          { return nil }
-func (u *Update) Blur()                                   {}
-func (u *Update) Init() tea.Cmd                           { return nil }
-func (u *Update) Update(msg tea.Msg) (tea.Model, tea.Cmd) { return u, nil }
+// conflictRows is the height of a conflict view without its diff
+const conflictRows = 14
 
 func (u *Update) View() string {
 	t := u.theme
-	title := t.S().Title.Render("Update")
-	content := lipgloss.JoinVertical(lipgloss.Center,
-		title,
+	container := components.NewPageContainer(t).
+		SetSize(u.width, u.height).
+		SetHeader(t.S().Title.Render("Update")).
+		SetContentAlign(lipgloss.Left)
+
+	var hints string
+	switch u.stage {
+	case stageReview:
+		hints = "[r] check again"
+		if !u.planned.plan.IsEmpty() {
+			hints = "[a] apply  " + hints
+		}
+		if len(u.planned.conflicts) > 0 {
+			hints = "[↑/↓] select  [enter] decide  " + hints
+		}
+	case stageConflict:
+		hints = "[↑/↓] select  [space] remember  [pgup/pgdn] scroll  [enter] confirm  [esc] back"
+	case stageDone:
+		hints = "[r] check again"
+	}
+	if hints != "" {
+		container.SetFooter(t.S().Muted.Render(hints))
+	}
+
+	var content string
+	switch u.stage {
+	case stagePlanning:
+		content = u.spinner.View()
+	case stageReview:
+		content = u.renderReview()
+	case stageConflict:
+		u.diffView.SetSize(container.InnerWidth(), max(3, container.ContentHeight()-conflictRows))
+		content = u.renderConflict()
+	case stageApplying:
+		content = t.S().Muted.Render("Applying...")
+	case stageDone:
+		content = u.renderDone()
+	}
+	return container.SetContent(content).View()
+}
+
+func (u *Update) renderReview() string {
+	t := u.theme
+	p := u.planned
+
+	source := "Config source is at " + p.from
+	if p.to != "" && p.to != p.from {
+		source = fmt.Sprintf("Changes %s..%s", p.from, p.to)
+	}
+	rows := []string{t.S().Subtitle.Render(source), ""}
+
+	if p.plan.IsEmpty() {
+		rows = append(rows, t.S().Success.Render(theme.Icons.Success+" Everything is up to date"))
+		return lipgloss.JoinVertical(lipgloss.Left, rows...)
+	}
+
+	summary := components.NewKeyValue(t).
+		Add("Packages to install", fmt.Sprint(p.plan.PackageCount())).
+		Add("Link changes", fmt.Sprint(len(p.plan.LinkChanges()))).
+		Add("Existing files", fmt.Sprint(len(p.conflicts)))
+	rows = append(rows, summary.View())
+
+	for _, w := range p.plan.Warnings {
+		rows = append(rows, t.S().Warning.Render(theme.Icons.Warning+" "+w))
+	}
+	for _, a := range p.plan.LinkErrors() {
+		rows = append(rows, t.S().Error.Render(theme.Icons.Error+" "+a.Target+": "+a.Reason))
+	}
+
+	if len(p.conflicts) > 0 {
+		rows = append(rows, "", t.S().Text.Render("These files exist and would be replaced:"))
+	}
+	for i, c := range p.conflicts {
+		prefix, style := "  ", t.S().Muted
+		if i == u.cursor {
+			prefix, style = theme.Icons.ArrowRight+" ", t.S().Focused
+		}
+		status := t.S().Warning.Render(c.label())
+		if c.decision != nil {
+			status = t.S().Subtle.Render(c.label())
+		}
+		rows = append(rows, style.Render(prefix+c.action.Target)+"  "+status)
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+func (u *Update) renderConflict() string {
+	t := u.theme
+	c := u.current
+
+	rows := []string{
+		t.S().Warning.Render(c.action.Target + " already exists"),
+		t.S().Subtle.Render("from " + c.action.Source),
+		"",
+		u.diffView.View(),
 		"",
-		t.S().Muted.Render("Coming soon..."),
-	)
+		u.options.View(),
+		u.remember.View(),
+	}
+	if u.problem != "" {
+		rows = append(rows, "", t.S().Error.Render(theme.Icons.Error+" "+u.problem))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
 
-	return components.NewPageContainer(t).
-		SetSize(u.width, u.height).
-		SetContent(content).
-		View()
+func (u *Update) renderDone() string {
+	t := u.theme
+
+	if u.err != nil {
+		return components.ErrorAlert(t, u.err.Error()).SetWidth(min(u.width, 80)).View()
+	}
+	if u.result == nil {
+		return t.S().Muted.Render("Nothing was applied")
+	}
+	if u.result.Success() {
+		message := "Update complete!"
+		if u.result.Generation > 0 {
+			message += fmt.Sprintf(" Recorded generation %d.", u.result.Generation)
+		}
+		return components.SuccessAlert(t, message).SetWidth(min(u.width, 80)).View()
+	}
+
+	rows := []string{
+		components.WarningAlert(t, fmt.Sprintf("Completed with %d error(s)", len(u.result.Errors))).SetWidth(min(u.width, 80)).View(),
+	}
+	if u.result.RolledBack {
+		rows = append(rows, t.S().Muted.Render("Link changes were rolled back"))
+	}
+	for _, e := range u.result.Errors {
+		rows = append(rows, t.S().Error.Render(theme.Icons.Error+" "+e.Error()))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
 }
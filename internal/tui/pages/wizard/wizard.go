@@ -3,6 +3,7 @@ package wizard
 import (
 	"os"
 
+	"github.com/arthur404dev/dotts/internal/config"
 	"github.com/arthur404dev/dotts/internal/setup"
 	"github.com/arthur404dev/dotts/internal/tui/app"
 	"github.com/arthur404dev/dotts/pkg/vetru/components"
@@ -80,6 +81,7 @@ type Wizard struct {
 	setupInProgress bool
 	setupResult     *messages.SetupCompleteMsg
 	setupError      error
+	configs         []config.ConfigInfo // configs of the set up repo, with their descriptions
 }
 
 func New(t *theme.Theme) *Wizard {
@@ -234,6 +236,7 @@ func (w *Wizard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if msg.Success {
 			w.step = StepComplete
 			w.setupError = nil
+			w.configs, _ = config.NewLoader(msg.ConfigPath).ListConfigInfo()
 		} else {
 			w.setupError = msg.Error
 			w.step = StepSummary
@@ -1204,6 +1207,18 @@ func (w *Wizard) renderCompleteStep() string {
 			details = append(details, t.S().Info.Render(theme.Icons.Info+" Copied from:"))
 			details = append(details, t.S().Muted.PaddingLeft(2).Render(w.setupResult.CopiedFrom))
 		}
+
+		if len(w.configs) > 0 {
+			details = append(details, "")
+			details = append(details, t.S().Info.Render(theme.Icons.Info+" Available configs:"))
+			for _, c := range w.configs {
+				line := t.S().Text.Render(c.Name)
+				if c.Meta.Description != "" {
+					line += t.S().Muted.Render(" - " + c.Meta.Description)
+				}
+				details = append(details, lipgloss.NewStyle().PaddingLeft(2).Render(line))
+			}
+		}
 	}
 
 	boxWidth := 60
@@ -214,6 +214,19 @@ func validateConfig(loader *config.Loader) error {
 		fmt.Println(styles.Success(fmt.Sprintf("Found %d machine config(s)", len(machines))))
 	}
 
+	configs, err := loader.ListConfigInfo()
+	if err != nil {
+		return fmt.Errorf("failed to list configs: %w", err)
+	}
+	if len(configs) > 0 {
+		fmt.Println(styles.Success(fmt.Sprintf("Found %d config(s)", len(configs))))
+		for _, c := range configs {
+			if c.Meta.Description != "" {
+				fmt.Println(styles.Mute(fmt.Sprintf("  %s: %s", c.Name, c.Meta.Description)))
+			}
+		}
+	}
+
 	return nil
 }
 
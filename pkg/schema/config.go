@@ -1,13 +1,79 @@
 package schema
 
 type RepoConfig struct {
-	Name            string   `yaml:"name"`
-	Author          string   `yaml:"author,omitempty"`
-	Description     string   `yaml:"description,omitempty"`
-	Version         string   `yaml:"version,omitempty"`
-	DefaultMachine  string   `yaml:"default_machine,omitempty"`
-	Features        []string `yaml:"features,omitempty"`
-	MinDottsVersion string   `yaml:"min_dotts_version,omitempty"`
+	Name            string     `yaml:"name"`
+	Author          string     `yaml:"author,omitempty"`
+	Description     string     `yaml:"description,omitempty"`
+	Version         string     `yaml:"version,omitempty"`
+	DefaultMachine  string     `yaml:"default_machine,omitempty"`
+	Features        []string   `yaml:"features,omitempty"`
+	MinDottsVersion string     `yaml:"min_dotts_version,omitempty"`
+	Alternates      Alternates `yaml:"alternates,omitempty"`
+	Templates       Templates  `yaml:"templates,omitempty"`
+	Encryption      Encryption `yaml:"encryption,omitempty"`
+	Backups         Backups    `yaml:"backups,omitempty"`
+	History         History    `yaml:"history,omitempty"`
+}
+
+// Backups is the retention policy for backups of replaced files, applied
+// after every apply. Both limits are optional; without them backups are
+// kept forever.
+type Backups struct {
+	// KeepLast keeps only the newest n versions of each path
+	KeepLast int `yaml:"keep_last,omitempty"`
+
+	// MaxAge removes versions older than this, e.g. "30d", "2w" or "72h"
+	MaxAge string `yaml:"max_age,omitempty"`
+}
+
+// History is the retention policy for generations, applied after every
+// apply and rollback. Both limits are optional; without them generations
+// are kept forever. The newest generation is always kept.
+type History struct {
+	// KeepLast keeps only the newest n generations
+	KeepLast int `yaml:"keep_last,omitempty"`
+
+	// MaxAge removes generations older than this, e.g. "30d", "2w" or "72h"
+	MaxAge string `yaml:"max_age,omitempty"`
+}
+
+// Encryption configures age-encrypted (.age) files in configs/
+type Encryption struct {
+	// Identity is the local age identity used to decrypt; defaults to
+	// ~/.config/dotts/identity.txt
+	Identity string `yaml:"identity,omitempty"`
+
+	// Recipients are the public keys new files are encrypted to; defaults to
+	// the identity's own public key
+	Recipients []string `yaml:"recipients,omitempty"`
+}
+
+// Templates configures template rendering
+type Templates struct {
+	// MissingKeys is what happens to unresolved values: error (default),
+	// warn, keep or empty
+	MissingKeys string `yaml:"missing_keys,omitempty"`
+
+	// Secrets configures <<dotts:secret:provider/path>> lookups
+	Secrets Secrets `yaml:"secrets,omitempty"`
+}
+
+// Secrets configures secret providers. env, file and pass are always
+// available; Commands adds providers that run an executable.
+type Secrets struct {
+	// StoreDir overrides the directory read by the file provider
+	StoreDir string `yaml:"store_dir,omitempty"`
+
+	// Commands maps a provider name to a command line. "{path}" is replaced
+	// with the reference path, otherwise the path is appended.
+	Commands map[string][]string `yaml:"commands,omitempty"`
+}
+
+// Alternates tunes how ##-suffixed variants are chosen
+type Alternates struct {
+	// Scores overrides the weight of predicate keys (hostname, user, profile,
+	// setting, feature, distro, env, arch, os, default)
+	Scores map[string]int `yaml:"scores,omitempty"`
 }
 
 func (c *RepoConfig) HasFeature(feature string) bool {
@@ -0,0 +1,42 @@
+package schema
+
+// ConfigMeta is the optional configs/<name>/.dotts.yaml
+type ConfigMeta struct {
+	Description string `yaml:"description,omitempty"`
+
+	// Target is the directory the config mirrors into: $HOME (default),
+	// $XDG_CONFIG_HOME, an absolute path, or a path relative to $HOME
+	Target string `yaml:"target,omitempty"`
+
+	// LinkMode is symlink, copy or hardlink for the whole config; LinkModes
+	// overrides it for paths and globs inside the config. Profile link_modes
+	// win for the same path.
+	LinkMode  string            `yaml:"link_mode,omitempty"`
+	LinkModes map[string]string `yaml:"link_modes,omitempty"`
+
+	Requires ConfigRequires `yaml:"requires,omitempty"`
+
+	// OS and Distro limit the config to matching machines; empty means any
+	OS     []string `yaml:"os,omitempty"`
+	Distro []string `yaml:"distro,omitempty"`
+
+	// Permissions maps paths and globs inside the config to octal modes
+	Permissions map[string]string `yaml:"permissions,omitempty"`
+
+	// LinkDirs lists directories, as paths or globs inside the config, that
+	// are symlinked whole instead of file by file
+	LinkDirs []string `yaml:"link_dirs,omitempty"`
+
+	Hooks ConfigHooks `yaml:"hooks,omitempty"`
+}
+
+// ConfigRequires lists what a config needs on the machine
+type ConfigRequires struct {
+	Packages *PackageManifest `yaml:"packages,omitempty"` // installed with the machine's packages
+	Binaries []string         `yaml:"binaries,omitempty"` // expected on PATH
+}
+
+// ConfigHooks are shell commands run from the config directory
+type ConfigHooks struct {
+	PostLink []string `yaml:"post_link,omitempty"` // after any of the config's links changed
+}
@@ -31,6 +31,23 @@ func (p *PersonalConfig) ToMap() map[string]string {
 	return m
 }
 
+// ToData returns personal values as nested maps for templates: user.* plus
+// any overrides.
+func (p *PersonalConfig) ToData() map[string]any {
+	data := make(map[string]any, len(p.Overrides)+1)
+	for k, v := range p.Overrides {
+		data[k] = v
+	}
+
+	user := make(map[string]any)
+	for k, v := range p.ToMap() {
+		user[k[len("user."):]] = v
+	}
+	data["user"] = user
+
+	return data
+}
+
 func (p *PersonalConfig) IsComplete() bool {
 	return p.User.Name != "" && p.User.Email != ""
 }
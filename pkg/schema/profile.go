@@ -8,6 +8,10 @@ type Profile struct {
 	Packages    []string       `yaml:"packages,omitempty"`
 	Settings    map[string]any `yaml:"settings,omitempty"`
 	Scripts     ProfileScripts `yaml:"scripts,omitempty"`
+
+	// LinkModes sets symlink, copy or hardlink for a whole config ("flatpak")
+	// or for paths and globs inside one ("terminal/.config/kitty/*.conf")
+	LinkModes map[string]string `yaml:"link_modes,omitempty"`
 }
 
 type ProfileScripts struct {